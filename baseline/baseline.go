@@ -0,0 +1,80 @@
+package baseline
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/Clever/analytics-monitor/config"
+)
+
+// Sources that a baseline can be learned from
+const (
+	// SourceHistory learns from the most recent timestamps recorded by
+	// previous runs in the history store
+	SourceHistory = "history"
+	// SourceTimestamps learns from the distinct hours of the table's own
+	// timestamp column, when it holds load times
+	SourceTimestamps = "timestamps"
+)
+
+// MinIntervals is the number of update intervals needed to learn a baseline
+const MinIntervals = 3
+
+// ErrTooFewSamples is returned when there aren't MinIntervals intervals
+// between the observations to learn from
+var ErrTooFewSamples = errors.New("too few updates to learn a baseline")
+
+// Baseline is a table's learned update cadence and the threshold derived
+// from it
+type Baseline struct {
+	// Cadence is the configured percentile of intervals between updates
+	Cadence time.Duration
+	// Threshold is Cadence times the configured multiplier, rounded up
+	// to whole hours since latency is measured in hours
+	Threshold time.Duration
+	// Intervals is the number of intervals Cadence was learned from
+	Intervals int
+	Source    string
+}
+
+// Learn derives a baseline from the times a table was observed to update.
+// Repeated observations of the same time are counted once.
+func Learn(observations []time.Time, source string, auto config.AutoThreshold) (Baseline, error) {
+	auto = auto.WithDefaults()
+
+	sorted := append([]time.Time(nil), observations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Before(sorted[j]) })
+
+	var intervals []time.Duration
+	for i := 1; i < len(sorted); i++ {
+		if interval := sorted[i].Sub(sorted[i-1]); interval > 0 {
+			intervals = append(intervals, interval)
+		}
+	}
+	if len(intervals) < MinIntervals {
+		return Baseline{}, ErrTooFewSamples
+	}
+
+	cadence := percentile(intervals, auto.Percentile)
+	hours := math.Ceil((time.Duration(float64(cadence) * auto.Multiplier)).Hours())
+	return Baseline{
+		Cadence:   cadence,
+		Threshold: time.Duration(math.Max(hours, 1)) * time.Hour,
+		Intervals: len(intervals),
+		Source:    source,
+	}, nil
+}
+
+// percentile returns the nearest-rank percentile of durations
+func percentile(durations []time.Duration, p float64) time.Duration {
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,55 @@
+package baseline
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Clever/analytics-monitor/config"
+)
+
+// updates returns times separated by the given intervals
+func updates(intervals ...time.Duration) []time.Time {
+	at := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+	times := []time.Time{at}
+	for _, interval := range intervals {
+		at = at.Add(interval)
+		times = append(times, at)
+	}
+	return times
+}
+
+func TestLearn(t *testing.T) {
+	hourly := []time.Duration{time.Hour, time.Hour, time.Hour, time.Hour, time.Hour, time.Hour, time.Hour, time.Hour, time.Hour, 6 * time.Hour}
+
+	t.Logf("Testing that the threshold is a multiple of the percentile interval")
+	b, err := Learn(updates(hourly...), SourceHistory, config.AutoThreshold{Multiplier: 1.5, Percentile: 50})
+	require.NoError(t, err)
+	assert.Equal(t, Baseline{Cadence: time.Hour, Threshold: 2 * time.Hour, Intervals: 10, Source: SourceHistory}, b)
+
+	t.Logf("Testing that high percentiles include the occasional slow update")
+	b, err = Learn(updates(hourly...), SourceHistory, config.AutoThreshold{})
+	require.NoError(t, err)
+	assert.Equal(t, 6*time.Hour, b.Cadence)
+	assert.Equal(t, 12*time.Hour, b.Threshold)
+
+	t.Logf("Testing that repeated and unordered observations are counted once")
+	times := updates(24*time.Hour, 24*time.Hour, 24*time.Hour)
+	times = append(times, times[2], times[0])
+	b, err = Learn(times, SourceTimestamps, config.AutoThreshold{Multiplier: 1})
+	require.NoError(t, err)
+	assert.Equal(t, Baseline{Cadence: 24 * time.Hour, Threshold: 24 * time.Hour, Intervals: 3, Source: SourceTimestamps}, b)
+
+	t.Logf("Testing that thresholds are at least an hour")
+	b, err = Learn(updates(time.Minute, time.Minute, time.Minute), SourceHistory, config.AutoThreshold{})
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, b.Threshold)
+
+	t.Logf("Testing that too few updates can't be learned from")
+	_, err = Learn(updates(time.Hour, time.Hour), SourceHistory, config.AutoThreshold{})
+	assert.Equal(t, ErrTooFewSamples, err)
+	_, err = Learn(nil, SourceHistory, config.AutoThreshold{})
+	assert.Equal(t, ErrTooFewSamples, err)
+}
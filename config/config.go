@@ -1,67 +1,449 @@
 package config
 
 import (
-	"encoding/json"
-	"io/ioutil"
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	l "github.com/Clever/analytics-monitor/logger"
 )
 
 var (
+	// PostgresURL is a postgres:// connection URL. When it is set the
+	// other POSTGRES_* variables are optional and override its parts.
+	PostgresURL      string
 	PostgresHost     string
 	PostgresPort     string
 	PostgresDatabase string
 	PostgresUsername string
 	PostgresPassword string
+
+	// PostgresSSLMode is a libpq sslmode, e.g. "verify-full". If unset,
+	// clusters without a username connect with SSL disabled.
+	PostgresSSLMode string
+	// PostgresSSLRootCert is the CA certificate used to verify the server
+	PostgresSSLRootCert string
+	// PostgresSSLCert and PostgresSSLKey are the client certificate and key
+	PostgresSSLCert string
+	PostgresSSLKey  string
+
+	// PostgresCredentialsCommand is a command printing short-lived
+	// credentials as JSON, run before opening each connection
+	PostgresCredentialsCommand string
+	// RedshiftClusterID enables temporary credentials for POSTGRES_USER
+	// from the Redshift GetClusterCredentials API
+	RedshiftClusterID string
+	// RedshiftRegion is the region of the RedshiftClusterID cluster
+	RedshiftRegion string
+
+	MySQLHost     string
+	MySQLPort     string
+	MySQLDatabase string
+	MySQLUsername string
+	MySQLPassword string
+
+	// SQLitePath is the database file of a local SQLite cluster
+	SQLitePath string
+
+	// DBMaxRetries is the number of times a query failing with a
+	// transient error (connection reset, lock wait, ...) is retried
+	DBMaxRetries int
+	// DBRetryBaseDelay is the initial backoff between retries
+	DBRetryBaseDelay time.Duration
+
+	// DBMaxOpenConns and DBMaxIdleConns size the connection pool
+	DBMaxOpenConns int
+	DBMaxIdleConns int
+	// DBConnMaxLifetime closes connections after they have been open this
+	// long, e.g. so they pick up rotated credentials. Zero never closes them.
+	DBConnMaxLifetime time.Duration
+	// DBConnMaxIdleTime closes connections idle for this long
+	DBConnMaxIdleTime time.Duration
+
+	// LatencyBatchSize is the number of tables whose latency is
+	// queried together in a single UNION ALL query
+	LatencyBatchSize int
+
+	// S3Endpoint overrides the endpoint used by object freshness checks,
+	// e.g. to point them at a local MinIO. Empty uses AWS S3.
+	S3Endpoint string
+	// S3Region is the region used by object freshness checks
+	S3Region string
 )
 
 // Config configures latency checks by cluster
+// `type` selects the cluster's backend: "redshift" (default),
+// "postgres", "mysql" or "sqlite"
 type Config struct {
+	Type           string         `json:"type"`
 	PostgresChecks []SchemaConfig `json:"postgres-checks"`
+	ObjectChecks   []ObjectCheck  `json:"object-checks"`
+	Silences       []Silence      `json:"silences"`
+}
+
+// Ownership identifies who is responsible for a check, so its alerts
+// can be routed to them. Fields left empty on a table are inherited
+// from its schema.
+type Ownership struct {
+	Owner      string `json:"owner"`
+	Team       string `json:"team"`
+	RunbookURL string `json:"runbook_url"`
+}
+
+// Inherit returns o with empty fields filled in from parent
+func (o Ownership) Inherit(parent Ownership) Ownership {
+	if o.Owner == "" {
+		o.Owner = parent.Owner
+	}
+	if o.Team == "" {
+		o.Team = parent.Team
+	}
+	if o.RunbookURL == "" {
+		o.RunbookURL = parent.RunbookURL
+	}
+	return o
 }
 
-// SchemaConfig configures latency checks by schema
+// SchemaConfig configures latency checks by schema.
+// Its ownership and dependencies apply to every table in the schema.
 type SchemaConfig struct {
-	SchemaName             string       `json:"schema"`
-	DefaultThreshold       string       `json:"default_threshold"`
-	DefaultTimestampColumn string       `json:"default_timestamp_column"`
-	TablesToOmit           []string     `json:"omit_tables"`
-	Checks                 []TableCheck `json:"checks"`
+	Ownership
+	SchemaName             string        `json:"schema"`
+	DefaultThreshold       string        `json:"default_threshold"`
+	DefaultTimestampColumn string        `json:"default_timestamp_column"`
+	DefaultFreshnessMode   string        `json:"default_freshness_mode"`
+	TablesToOmit           []string      `json:"omit_tables"`
+	DefaultSLO             SLO           `json:"default_slo"`
+	DefaultAuto            AutoThreshold `json:"default_auto"`
+	DefaultFutureTolerance string        `json:"default_future_tolerance"`
+	DefaultBoundToNow      bool          `json:"default_bound_to_now"`
+	DependsOn              []string      `json:"depends_on"`
+	Checks                 []TableCheck  `json:"checks"`
 }
 
-// TableCheck configures a single latency check for a table
+// TableCheck configures a single latency check for a table.
+// `depends_on` lists the upstream tables ("schema.table") or whole
+// schemas ("schema") that the table is derived from.
+// `quality` adds data quality checks on the table's columns.
 type TableCheck struct {
+	Ownership
 	TableName string      `json:"table"`
 	Latency   LatencyInfo `json:"latency"`
+	SLO       SLO         `json:"slo"`
+	DependsOn []string    `json:"depends_on"`
+	Quality   QualityInfo `json:"quality"`
+}
+
+// QualityInfo configures data quality checks on a table's columns, run
+// over the rows whose timestamp column is within the last `window`, a
+// string formatted Golang duration defaulting to DefaultQualityWindow
+type QualityInfo struct {
+	Window  string        `json:"window"`
+	Columns []ColumnCheck `json:"columns"`
+}
+
+// DefaultQualityWindow is the window of quality checks that don't set one
+const DefaultQualityWindow = "24h"
+
+// WindowDuration returns the window of the quality checks
+func (q QualityInfo) WindowDuration() (time.Duration, error) {
+	window := q.Window
+	if window == "" {
+		window = DefaultQualityWindow
+	}
+	return time.ParseDuration(window)
+}
+
+// ColumnCheck configures the data quality checks of a column. Each is
+// optional:
+// `max_null_fraction` is the highest fraction of rows, in [0, 1], that may be null
+// `min_distinct` is the fewest distinct values the column may take
+// `allowed_values` lists every value the column may take, compared as text
+type ColumnCheck struct {
+	Column          string   `json:"column"`
+	MaxNullFraction *float64 `json:"max_null_fraction"`
+	MinDistinct     int64    `json:"min_distinct"`
+	AllowedValues   []string `json:"allowed_values"`
+}
+
+// SLO is a freshness objective: the percentage of check runs over a
+// rolling window in which a table should meet its threshold.
+// `target` is a percentage below 100, e.g. 99.5, and `window` a string
+// formatted Golang duration, defaulting to DefaultSLOWindow.
+type SLO struct {
+	Target float64 `json:"target"`
+	Window string  `json:"window"`
+}
+
+// DefaultSLOWindow is the window of SLOs that don't set one, 30 days
+const DefaultSLOWindow = "720h"
+
+// IsSet reports whether an objective was configured
+func (s SLO) IsSet() bool {
+	return s.Target != 0
+}
+
+// Inherit returns s with empty fields filled in from parent
+func (s SLO) Inherit(parent SLO) SLO {
+	if s.Target == 0 {
+		s.Target = parent.Target
+	}
+	if s.Window == "" {
+		s.Window = parent.Window
+	}
+	return s
+}
+
+// WindowDuration returns the window of the objective
+func (s SLO) WindowDuration() (time.Duration, error) {
+	window := s.Window
+	if window == "" {
+		window = DefaultSLOWindow
+	}
+	return time.ParseDuration(window)
+}
+
+// ObjectCheck configures a freshness check on an object store prefix.
+// `path` is an s3://bucket/prefix path and `threshold` the maximum age of
+// the newest object under it, as a string formatted Golang duration.
+type ObjectCheck struct {
+	Ownership
+	Path      string `json:"path"`
+	Threshold string `json:"threshold"`
 }
 
 // LatencyInfo stores information for a latency check
-// `threshold` expects a string formatted Golang duration
+// `threshold` expects a string formatted Golang duration, or ThresholdAuto
+// `freshness_mode` is one of the FreshnessMode values below
+// `auto` tunes how automatic thresholds are learned
+// `future_tolerance` is how far in the future the most recent timestamp
+// may be before it's reported, as a string formatted Golang duration
+// `bound_to_now` ignores timestamps beyond the tolerance when computing
+// latency. Unset, it falls back to the schema's `default_bound_to_now`.
+// `filter` restricts the rows checked to those matching a Filter
+// `group_by` is a column whose values are each checked separately, so one
+// stale source can't hide behind fresh ones
 type LatencyInfo struct {
-	TimestampColumn string `json:"timestamp_column"`
-	Threshold       string `json:"threshold"`
+	TimestampColumn string        `json:"timestamp_column"`
+	Threshold       string        `json:"threshold"`
+	FreshnessMode   string        `json:"freshness_mode"`
+	Auto            AutoThreshold `json:"auto"`
+	FutureTolerance string        `json:"future_tolerance"`
+	BoundToNow      *bool         `json:"bound_to_now"`
+	Filter          *Filter       `json:"filter"`
+	GroupBy         string        `json:"group_by"`
 }
 
-// Parse reads environment variables and initializes the config.
-func Parse() {
-	PostgresHost = requiredEnv("POSTGRES_HOST")
-	PostgresPort = requiredEnv("POSTGRES_PORT")
-	PostgresDatabase = requiredEnv("POSTGRES_DATABASE")
-	PostgresUsername = requiredEnv("POSTGRES_USER")
-	PostgresPassword = requiredEnv("POSTGRES_PASSWORD")
+// Filter selects the rows whose `column` compares to `values` by `operator`,
+// one of the Filter operators below. Values are bound as query parameters,
+// so they compare as the column's type.
+type Filter struct {
+	Column   string   `json:"column"`
+	Operator string   `json:"operator"`
+	Values   []string `json:"values"`
 }
 
-// ParseChecks reads in the latency check definitions
-func ParseChecks(latencyConfigPath string) Config {
-	latencyJSON, err := ioutil.ReadFile(latencyConfigPath)
-	if err != nil {
-		l.GetKVLogger().CriticalD("read-latency-config-error", l.M{"error": err.Error()})
-		panic("Unable to read latency config")
+// Filter operators. As in SQL, rows whose column is null match neither
+// FilterEquals nor FilterNotEquals, nor FilterIn nor FilterNotIn.
+const (
+	// FilterEquals matches rows whose column equals the single value
+	FilterEquals = "="
+	// FilterNotEquals matches rows whose column differs from the single value
+	FilterNotEquals = "<>"
+	// FilterIn matches rows whose column equals any of the values
+	FilterIn = "in"
+	// FilterNotIn matches rows whose column equals none of the values
+	FilterNotIn = "not in"
+	// FilterIsNull matches rows whose column is null, and takes no values
+	FilterIsNull = "is null"
+	// FilterIsNotNull matches rows whose column isn't null, and takes no values
+	FilterIsNotNull = "is not null"
+)
+
+// IsGrouped reports whether latency is checked per value of a column
+func (li LatencyInfo) IsGrouped() bool {
+	return li.GroupBy != ""
+}
+
+// IsBoundToNow reports whether timestamps beyond the future tolerance are
+// ignored when computing latency
+func (li LatencyInfo) IsBoundToNow() bool {
+	return li.BoundToNow != nil && *li.BoundToNow
+}
+
+// DefaultFutureTolerance is the future tolerance of checks that don't set
+// one. It allows for clock skew between the writer and the monitor.
+const DefaultFutureTolerance = "1h"
+
+// FutureToleranceDuration returns how far in the future the most recent
+// timestamp may be
+func (li LatencyInfo) FutureToleranceDuration() (time.Duration, error) {
+	tolerance := li.FutureTolerance
+	if tolerance == "" {
+		tolerance = DefaultFutureTolerance
 	}
+	return time.ParseDuration(tolerance)
+}
+
+// ThresholdAuto is a threshold learned from the table's own update cadence
+// rather than configured
+const ThresholdAuto = "auto"
+
+// IsAuto reports whether the threshold is learned
+func (li LatencyInfo) IsAuto() bool {
+	return li.Threshold == ThresholdAuto
+}
+
+// AutoThreshold configures how a table's threshold is learned from its
+// update cadence: the interval between its observed update times, at
+// `percentile`, times `multiplier`. Update times are observed over the
+// `lookback` duration. Zero values use the defaults below.
+// `load_timestamps` declares that the timestamp column is set when rows are
+// loaded, so its distinct hours are load times that can be learned from
+// before check history has any. Event times say how dense the data is, not
+// how often it's loaded, so they aren't learned from unless this is set.
+type AutoThreshold struct {
+	Multiplier     float64 `json:"multiplier"`
+	Percentile     float64 `json:"percentile"`
+	Lookback       string  `json:"lookback"`
+	LoadTimestamps *bool   `json:"load_timestamps"`
+}
 
-	var checks Config
-	err = json.Unmarshal(latencyJSON, &checks)
+// Defaults of automatic thresholds
+const (
+	DefaultAutoMultiplier = 2
+	DefaultAutoPercentile = 95
+	// DefaultAutoLookback is two weeks
+	DefaultAutoLookback = "336h"
+)
+
+// Inherit returns a with empty fields filled in from parent
+func (a AutoThreshold) Inherit(parent AutoThreshold) AutoThreshold {
+	if a.Multiplier == 0 {
+		a.Multiplier = parent.Multiplier
+	}
+	if a.Percentile == 0 {
+		a.Percentile = parent.Percentile
+	}
+	if a.Lookback == "" {
+		a.Lookback = parent.Lookback
+	}
+	if a.LoadTimestamps == nil {
+		a.LoadTimestamps = parent.LoadTimestamps
+	}
+	return a
+}
+
+// WithDefaults returns a with empty fields set to their defaults
+func (a AutoThreshold) WithDefaults() AutoThreshold {
+	return a.Inherit(AutoThreshold{
+		Multiplier: DefaultAutoMultiplier,
+		Percentile: DefaultAutoPercentile,
+		Lookback:   DefaultAutoLookback,
+	})
+}
+
+// HasLoadTimestamps reports whether the timestamp column holds load times
+func (a AutoThreshold) HasLoadTimestamps() bool {
+	return a.LoadTimestamps != nil && *a.LoadTimestamps
+}
+
+// LookbackDuration returns how far back update times are observed
+func (a AutoThreshold) LookbackDuration() (time.Duration, error) {
+	return time.ParseDuration(a.WithDefaults().Lookback)
+}
+
+// Freshness modes select how a table's latency is measured
+const (
+	// FreshnessModeDataTimestamp measures latency as the age of the most
+	// recent value in the timestamp column. This is the default.
+	FreshnessModeDataTimestamp = "data_timestamp"
+	// FreshnessModeLastWrite measures latency as the time since the table
+	// last received rows, according to Redshift system tables
+	FreshnessModeLastWrite = "last_write"
+	// FreshnessModeBoth runs both the data timestamp and last write checks
+	FreshnessModeBoth = "both"
+)
+
+// ValidFreshnessMode reports whether mode is a known freshness mode.
+// An empty mode is valid and means FreshnessModeDataTimestamp.
+func ValidFreshnessMode(mode string) bool {
+	switch mode {
+	case "", FreshnessModeDataTimestamp, FreshnessModeLastWrite, FreshnessModeBoth:
+		return true
+	}
+	return false
+}
+
+// ChecksDataTimestamp reports whether latency should be measured
+// from the table's timestamp column
+func (li LatencyInfo) ChecksDataTimestamp() bool {
+	return li.FreshnessMode != FreshnessModeLastWrite
+}
+
+// ChecksLastWrite reports whether latency should be measured
+// from when the table last received rows
+func (li LatencyInfo) ChecksLastWrite() bool {
+	return li.FreshnessMode == FreshnessModeLastWrite || li.FreshnessMode == FreshnessModeBoth
+}
+
+// Parse reads environment variables and initializes the config.
+// Only the connection variables of the given cluster type are required.
+func Parse(clusterType string) {
+	switch clusterType {
+	case "mysql":
+		MySQLHost = requiredEnv("MYSQL_HOST")
+		MySQLPort = requiredEnv("MYSQL_PORT")
+		MySQLDatabase = requiredEnv("MYSQL_DATABASE")
+		MySQLUsername = requiredEnv("MYSQL_USER")
+		MySQLPassword = requiredEnv("MYSQL_PASSWORD")
+	case "sqlite":
+		SQLitePath = requiredEnv("SQLITE_PATH")
+	default:
+		PostgresURL = optionalEnv("POSTGRES_URL")
+		PostgresCredentialsCommand = optionalEnv("POSTGRES_CREDENTIALS_COMMAND")
+		RedshiftClusterID = optionalEnv("REDSHIFT_CLUSTER_ID")
+		RedshiftRegion = optionalEnv("REDSHIFT_REGION")
+		if RedshiftRegion == "" {
+			RedshiftRegion = "us-west-2"
+		}
+
+		// A URL may hold any of the connection variables, and
+		// credential providers supply the password (and user)
+		hasURL := PostgresURL != ""
+		hasCommand := PostgresCredentialsCommand != ""
+		PostgresHost = envIf(!hasURL, "POSTGRES_HOST")
+		PostgresPort = envIf(!hasURL, "POSTGRES_PORT")
+		PostgresDatabase = envIf(!hasURL, "POSTGRES_DATABASE")
+		PostgresUsername = envIf(!hasURL && !hasCommand, "POSTGRES_USER")
+		PostgresPassword = envIf(!hasURL && !hasCommand && RedshiftClusterID == "", "POSTGRES_PASSWORD")
+		PostgresSSLMode = optionalEnv("POSTGRES_SSLMODE")
+		PostgresSSLRootCert = optionalEnv("POSTGRES_SSLROOTCERT")
+		PostgresSSLCert = optionalEnv("POSTGRES_SSLCERT")
+		PostgresSSLKey = optionalEnv("POSTGRES_SSLKEY")
+	}
+
+	DBMaxRetries = optionalIntEnv("DB_MAX_RETRIES", 3)
+	DBRetryBaseDelay = optionalDurationEnv("DB_RETRY_BASE_DELAY", time.Second)
+	DBMaxOpenConns = optionalIntEnv("DB_MAX_OPEN_CONNS", 4)
+	DBMaxIdleConns = optionalIntEnv("DB_MAX_IDLE_CONNS", 2)
+	DBConnMaxLifetime = optionalDurationEnv("DB_CONN_MAX_LIFETIME", 30*time.Minute)
+	DBConnMaxIdleTime = optionalDurationEnv("DB_CONN_MAX_IDLE_TIME", 5*time.Minute)
+	LatencyBatchSize = optionalIntEnv("LATENCY_BATCH_SIZE", 50)
+
+	S3Endpoint = os.Getenv("S3_ENDPOINT")
+	S3Region = os.Getenv("S3_REGION")
+	if S3Region == "" {
+		S3Region = "us-west-2"
+	}
+}
+
+// ParseChecks reads in the latency check definitions from a JSON or YAML
+// file, or a directory of them (see LoadChecks)
+func ParseChecks(latencyConfigPath string) Config {
+	checks, err := LoadChecks(latencyConfigPath)
 	if err != nil {
 		l.GetKVLogger().CriticalD("parse-latency-checks-error", l.M{"error": err.Error()})
 		panic("Unable to parse latency checks")
@@ -70,13 +452,83 @@ func ParseChecks(latencyConfigPath string) Config {
 	return checks
 }
 
-// requiredEnv tries to find a value in the environment variables. If a value is not
-// found the program will panic.
-func requiredEnv(key string) string {
+// envValue returns the value of an environment variable. A secret can
+// instead be mounted as a file, whose path is set in the variable with a
+// _FILE suffix, e.g. POSTGRES_PASSWORD_FILE. Trailing newlines in the
+// file are ignored. Setting both variables is an error.
+func envValue(key string) (string, error) {
 	value := os.Getenv(key)
+	path := os.Getenv(key + "_FILE")
+	if path == "" {
+		return value, nil
+	}
+	if value != "" {
+		return "", fmt.Errorf("only one of %s and %s_FILE may be set", key, key)
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading %s_FILE: %s", key, err)
+	}
+	return strings.TrimRight(string(contents), "\r\n"), nil
+}
+
+// requiredEnv tries to find a value in the environment variables, or in the
+// file named by its _FILE variant. If a value is not found the program will exit.
+func requiredEnv(key string) string {
+	value := optionalEnv(key)
 	if value == "" {
 		l.GetKVLogger().CriticalD("required-env", l.M{"name": key})
 		os.Exit(1)
 	}
 	return value
 }
+
+// envIf reads a value with requiredEnv if required, or optionalEnv otherwise
+func envIf(required bool, key string) string {
+	if required {
+		return requiredEnv(key)
+	}
+	return optionalEnv(key)
+}
+
+// optionalEnv reads a value like requiredEnv, returning "" if it is unset.
+// The program exits if its _FILE variant can't be read.
+func optionalEnv(key string) string {
+	value, err := envValue(key)
+	if err != nil {
+		l.GetKVLogger().CriticalD("invalid-env", l.M{"name": key, "error": err.Error()})
+		os.Exit(1)
+	}
+	return value
+}
+
+// optionalIntEnv reads an integer from the environment, falling back to
+// defaultValue if it is unset. The program exits if the value is malformed.
+func optionalIntEnv(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		l.GetKVLogger().CriticalD("invalid-env", l.M{"name": key, "error": err.Error()})
+		os.Exit(1)
+	}
+	return parsed
+}
+
+// optionalDurationEnv reads a Golang duration from the environment, falling back
+// to defaultValue if it is unset. The program exits if the value is malformed.
+func optionalDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		l.GetKVLogger().CriticalD("invalid-env", l.M{"name": key, "error": err.Error()})
+		os.Exit(1)
+	}
+	return parsed
+}
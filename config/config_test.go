@@ -1,9 +1,13 @@
 package config
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // TestParseChecks verifies that the latency_config
@@ -15,3 +19,79 @@ func TestParseChecks(t *testing.T) {
 		ParseChecks("example_config.json")
 	}, "Unable to parse latency checks")
 }
+
+func TestEnvValue(t *testing.T) {
+	dir := t.TempDir()
+	passwordFile := filepath.Join(dir, "password")
+	require.NoError(t, os.WriteFile(passwordFile, []byte("s3cret pass\n"), 0600))
+
+	t.Logf("Testing that a variable is read from the environment")
+	t.Setenv("TEST_SECRET", "from-env")
+	value, err := envValue("TEST_SECRET")
+	require.NoError(t, err)
+	assert.Equal(t, "from-env", value)
+
+	t.Logf("Testing that setting both a variable and its _FILE variant is an error")
+	t.Setenv("TEST_SECRET_FILE", passwordFile)
+	_, err = envValue("TEST_SECRET")
+	assert.Error(t, err)
+
+	t.Logf("Testing that a _FILE variant is read without its trailing newline")
+	t.Setenv("TEST_SECRET", "")
+	value, err = envValue("TEST_SECRET")
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret pass", value)
+
+	t.Logf("Testing that a missing file is an error")
+	t.Setenv("TEST_SECRET_FILE", filepath.Join(dir, "missing"))
+	_, err = envValue("TEST_SECRET")
+	assert.Error(t, err)
+
+	t.Logf("Testing that unset variables are empty")
+	value, err = envValue("TEST_UNSET_SECRET")
+	require.NoError(t, err)
+	assert.Equal(t, "", value)
+}
+
+func TestOwnershipInherit(t *testing.T) {
+	schema := Ownership{Owner: "jane", Team: "data-eng", RunbookURL: "https://runbooks/data-eng"}
+
+	t.Logf("Testing that empty fields are inherited from the schema")
+	assert.Equal(t, schema, Ownership{}.Inherit(schema))
+
+	t.Logf("Testing that table fields override the schema's one at a time")
+	assert.Equal(t,
+		Ownership{Owner: "sam", Team: "data-eng", RunbookURL: "https://runbooks/data-eng"},
+		Ownership{Owner: "sam"}.Inherit(schema))
+}
+
+func TestAutoThresholdDefaults(t *testing.T) {
+	t.Logf("Testing that unset fields use the defaults")
+	assert.Equal(t,
+		AutoThreshold{Multiplier: DefaultAutoMultiplier, Percentile: DefaultAutoPercentile, Lookback: DefaultAutoLookback},
+		AutoThreshold{}.WithDefaults())
+
+	t.Logf("Testing that table fields override the schema's, then the defaults")
+	schema := AutoThreshold{Multiplier: 3, Lookback: "168h"}
+	assert.Equal(t,
+		AutoThreshold{Multiplier: 3, Percentile: 50, Lookback: "168h"},
+		AutoThreshold{Percentile: 50}.Inherit(schema).WithDefaults())
+
+	t.Logf("Testing that load timestamps are inherited unless the table sets them")
+	loads, events := true, false
+	schema.LoadTimestamps = &loads
+	assert.True(t, AutoThreshold{}.Inherit(schema).HasLoadTimestamps())
+	assert.False(t, AutoThreshold{LoadTimestamps: &events}.Inherit(schema).HasLoadTimestamps())
+	assert.False(t, AutoThreshold{}.WithDefaults().HasLoadTimestamps())
+}
+
+func TestFutureToleranceDuration(t *testing.T) {
+	t.Logf("Testing that an unset tolerance uses the default")
+	tolerance, err := LatencyInfo{}.FutureToleranceDuration()
+	assert.NoError(t, err)
+	assert.Equal(t, time.Hour, tolerance)
+
+	tolerance, err = LatencyInfo{FutureTolerance: "15m"}.FutureToleranceDuration()
+	assert.NoError(t, err)
+	assert.Equal(t, 15*time.Minute, tolerance)
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"reflect"
+	"sort"
+)
+
+// ConfigDiff lists the checks added, removed and changed between two
+// configs. Entries are schema names (for schema defaults and omitted
+// tables), schema.table names, object check paths and "silences".
+type ConfigDiff struct {
+	Added   []string
+	Removed []string
+	Changed []string
+}
+
+// Empty reports whether the configs had no differences
+func (d ConfigDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Diff compares two configs check by check
+func Diff(before, after Config) ConfigDiff {
+	beforeEntries := diffEntries(before)
+	afterEntries := diffEntries(after)
+
+	var diff ConfigDiff
+	for name, afterValue := range afterEntries {
+		beforeValue, ok := beforeEntries[name]
+		if !ok {
+			diff.Added = append(diff.Added, name)
+		} else if !reflect.DeepEqual(beforeValue, afterValue) {
+			diff.Changed = append(diff.Changed, name)
+		}
+	}
+	for name := range beforeEntries {
+		if _, ok := afterEntries[name]; !ok {
+			diff.Removed = append(diff.Removed, name)
+		}
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Changed)
+	return diff
+}
+
+// diffEntries flattens a config into comparable entries indexed by name
+func diffEntries(checks Config) map[string]interface{} {
+	entries := make(map[string]interface{})
+	if checks.Type != "" {
+		entries["type"] = checks.Type
+	}
+
+	for _, schema := range checks.PostgresChecks {
+		for _, check := range schema.Checks {
+			entries[schema.SchemaName+"."+check.TableName] = check
+		}
+		schema.Checks = nil
+		entries[schema.SchemaName] = schema
+	}
+
+	for _, objectCheck := range checks.ObjectChecks {
+		entries[objectCheck.Path] = objectCheck
+	}
+
+	if len(checks.Silences) > 0 {
+		entries["silences"] = checks.Silences
+	}
+	return entries
+}
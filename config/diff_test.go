@@ -0,0 +1,51 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	before := Config{
+		PostgresChecks: []SchemaConfig{
+			{
+				SchemaName:       "mongo",
+				DefaultThreshold: "24h",
+				Checks: []TableCheck{
+					{TableName: "districts", Latency: LatencyInfo{Threshold: "2h"}},
+					{TableName: "schools", Latency: LatencyInfo{Threshold: "2h"}},
+				},
+			},
+			{SchemaName: "events", DefaultThreshold: "1h"},
+		},
+		ObjectChecks: []ObjectCheck{{Path: "s3://firehose/events/", Threshold: "2h"}},
+	}
+	after := Config{
+		PostgresChecks: []SchemaConfig{
+			{
+				SchemaName:       "mongo",
+				DefaultThreshold: "24h",
+				Checks: []TableCheck{
+					{TableName: "districts", Latency: LatencyInfo{Threshold: "3h"}},
+					{TableName: "sections", Latency: LatencyInfo{Threshold: "2h"}},
+				},
+			},
+			{SchemaName: "events", DefaultThreshold: "1h", TablesToOmit: []string{"spammy"}},
+		},
+		ObjectChecks: []ObjectCheck{{Path: "s3://firehose/events/", Threshold: "2h"}},
+	}
+
+	diff := Diff(before, after)
+	assert.Equal(t, []string{"mongo.sections"}, diff.Added)
+	assert.Equal(t, []string{"mongo.schools"}, diff.Removed)
+	assert.Equal(t, []string{"events", "mongo.districts"}, diff.Changed)
+	assert.False(t, diff.Empty())
+
+	assert.True(t, Diff(after, after).Empty())
+
+	t.Logf("Testing that silences are compared as a whole")
+	silenced := after
+	silenced.Silences = []Silence{{Schema: "mongo", End: "2024-03-05T12:00:00Z", Reason: "migration"}}
+	assert.Equal(t, []string{"silences"}, Diff(after, silenced).Added)
+}
@@ -0,0 +1,324 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configExtensions are the file extensions read when loading a directory
+var configExtensions = map[string]bool{".json": true, ".yml": true, ".yaml": true}
+
+// fileConfig is the content of a single config file. `include` lists
+// further files, directories or globs to load, relative to the file.
+type fileConfig struct {
+	Config
+	Include []string `json:"include"`
+}
+
+// location identifies where something was declared in a config file
+type location struct {
+	file string
+	line int
+}
+
+func (loc location) String() string {
+	return fmt.Sprintf("%s:%d", loc.file, loc.line)
+}
+
+// loader merges config files, remembering where every
+// schema, table and object check was declared
+type loader struct {
+	merged Config
+
+	typeLocation    location
+	schemaIndex     map[string]int
+	schemaLocations map[string]location
+	tableLocations  map[string]location
+	objectLocations map[string]location
+	loading         map[string]bool
+	loaded          map[string]bool
+}
+
+// LoadChecks reads the check definitions at path, which may be a JSON or YAML
+// file, or a directory whose JSON and YAML files are merged. Files can pull in
+// others with an `include` directive. Conflicting definitions, such as two
+// files configuring the same schema.table, are reported with file and line.
+func LoadChecks(path string) (Config, error) {
+	ld := &loader{
+		schemaIndex:     make(map[string]int),
+		schemaLocations: make(map[string]location),
+		tableLocations:  make(map[string]location),
+		objectLocations: make(map[string]location),
+		loading:         make(map[string]bool),
+		loaded:          make(map[string]bool),
+	}
+	if err := ld.loadPath(path); err != nil {
+		return Config{}, err
+	}
+	return ld.merged, nil
+}
+
+// loadPath loads a single file, or every config file in a directory
+func (ld *loader) loadPath(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return ld.loadFile(path)
+	}
+
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return err
+	}
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() && configExtensions[strings.ToLower(filepath.Ext(entry.Name()))] {
+			files = append(files, filepath.Join(path, entry.Name()))
+		}
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		if err := ld.loadFile(file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadFile parses a config file, loads its includes and merges it in
+func (ld *loader) loadFile(path string) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if ld.loading[absPath] {
+		return fmt.Errorf("%s: include cycle", path)
+	}
+	if ld.loaded[absPath] {
+		// Already merged, e.g. included by two files
+		return nil
+	}
+	ld.loading[absPath] = true
+	defer delete(ld.loading, absPath)
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	// YAML is a superset of JSON, so both formats parse into a node tree
+	// that keeps line numbers. The tree is then decoded through JSON so
+	// the struct tags in Config stay the single source of truth.
+	var root yaml.Node
+	if err := yaml.Unmarshal(content, &root); err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+	var file fileConfig
+	if len(root.Content) > 0 {
+		var raw interface{}
+		if err := root.Decode(&raw); err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+		rawJSON, err := json.Marshal(raw)
+		if err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+		if err := json.Unmarshal(rawJSON, &file); err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+	}
+
+	for _, include := range file.Include {
+		if err := ld.loadInclude(filepath.Dir(path), include, path); err != nil {
+			return err
+		}
+	}
+
+	if err := ld.merge(path, &root, file.Config); err != nil {
+		return err
+	}
+	ld.loaded[absPath] = true
+	return nil
+}
+
+// loadInclude loads an included file, directory or glob, relative to dir
+func (ld *loader) loadInclude(dir, include, includedFrom string) error {
+	pattern := include
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(dir, pattern)
+	}
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return fmt.Errorf("%s: bad include %q: %s", includedFrom, include, err)
+	}
+	if len(matches) == 0 {
+		return fmt.Errorf("%s: include %q matched no files", includedFrom, include)
+	}
+
+	for _, match := range matches {
+		if err := ld.loadPath(match); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// merge adds a file's config to the merged config. A schema may appear in
+// several files, but its defaults may only be set in one of them, and each
+// table and object check may only be configured once.
+func (ld *loader) merge(path string, root *yaml.Node, file Config) error {
+	lines := lineNumbers(root)
+	seen := make(map[string]int)
+	// next returns the location of the next occurrence of key in the file,
+	// so must be called once for each entry, in file order
+	next := func(key string) location {
+		loc := location{file: path}
+		if n := seen[key]; n < len(lines[key]) {
+			loc.line = lines[key][n]
+		}
+		seen[key]++
+		return loc
+	}
+
+	if file.Type != "" {
+		typeLocation := next("type")
+		if ld.merged.Type != "" && ld.merged.Type != file.Type {
+			return fmt.Errorf("type %q at %s conflicts with type %q at %s",
+				file.Type, typeLocation, ld.merged.Type, ld.typeLocation)
+		}
+		ld.merged.Type = file.Type
+		ld.typeLocation = typeLocation
+	}
+
+	for _, schema := range file.PostgresChecks {
+		schemaLocation := next("schema:" + schema.SchemaName)
+		for _, check := range schema.Checks {
+			fullName := schema.SchemaName + "." + check.TableName
+			tableLocation := next("table:" + fullName)
+			if previous, ok := ld.tableLocations[fullName]; ok {
+				return fmt.Errorf("%s configured at %s conflicts with %s",
+					fullName, tableLocation, previous)
+			}
+			ld.tableLocations[fullName] = tableLocation
+		}
+
+		i, ok := ld.schemaIndex[schema.SchemaName]
+		if !ok {
+			ld.schemaIndex[schema.SchemaName] = len(ld.merged.PostgresChecks)
+			ld.merged.PostgresChecks = append(ld.merged.PostgresChecks, schema)
+			if hasSchemaSettings(schema) {
+				ld.schemaLocations[schema.SchemaName] = schemaLocation
+			}
+			continue
+		}
+
+		existing := &ld.merged.PostgresChecks[i]
+		if hasSchemaSettings(schema) {
+			if previous, ok := ld.schemaLocations[schema.SchemaName]; ok {
+				return fmt.Errorf("schema %s defaults set at %s conflict with %s",
+					schema.SchemaName, schemaLocation, previous)
+			}
+			ld.schemaLocations[schema.SchemaName] = schemaLocation
+
+			checks, omit := existing.Checks, existing.TablesToOmit
+			*existing = schema
+			existing.Checks = append(checks, schema.Checks...)
+			existing.TablesToOmit = append(omit, schema.TablesToOmit...)
+			continue
+		}
+		existing.Checks = append(existing.Checks, schema.Checks...)
+		existing.TablesToOmit = append(existing.TablesToOmit, schema.TablesToOmit...)
+	}
+
+	for _, objectCheck := range file.ObjectChecks {
+		objectLocation := next("object:" + objectCheck.Path)
+		if previous, ok := ld.objectLocations[objectCheck.Path]; ok {
+			return fmt.Errorf("%s configured at %s conflicts with %s",
+				objectCheck.Path, objectLocation, previous)
+		}
+		ld.objectLocations[objectCheck.Path] = objectLocation
+		ld.merged.ObjectChecks = append(ld.merged.ObjectChecks, objectCheck)
+	}
+
+	ld.merged.Silences = append(ld.merged.Silences, file.Silences...)
+
+	return nil
+}
+
+// hasSchemaSettings reports whether a schema entry sets anything besides
+// its name, table checks and omitted tables, e.g. default thresholds
+func hasSchemaSettings(schema SchemaConfig) bool {
+	schema.SchemaName = ""
+	schema.Checks = nil
+	schema.TablesToOmit = nil
+	return !reflect.DeepEqual(schema, SchemaConfig{})
+}
+
+// lineNumbers indexes the lines where the type, schemas, tables and object
+// checks of a parsed config file are declared, listing every occurrence of
+// each in file order. Keys are "type", "schema:<schema>",
+// "table:<schema>.<table>" and "object:<path>".
+func lineNumbers(root *yaml.Node) map[string][]int {
+	lines := make(map[string][]int)
+	if len(root.Content) == 0 {
+		return lines
+	}
+
+	doc := root.Content[0]
+	if node := mappingValue(doc, "type"); node != nil {
+		lines["type"] = append(lines["type"], node.Line)
+	}
+	if schemas := mappingValue(doc, "postgres-checks"); schemas != nil {
+		for _, schema := range schemas.Content {
+			schemaName := scalarValue(mappingValue(schema, "schema"))
+			lines["schema:"+schemaName] = append(lines["schema:"+schemaName], schema.Line)
+
+			if checks := mappingValue(schema, "checks"); checks != nil {
+				for _, check := range checks.Content {
+					tableName := scalarValue(mappingValue(check, "table"))
+					key := "table:" + schemaName + "." + tableName
+					lines[key] = append(lines[key], check.Line)
+				}
+			}
+		}
+	}
+	if objectChecks := mappingValue(doc, "object-checks"); objectChecks != nil {
+		for _, objectCheck := range objectChecks.Content {
+			key := "object:" + scalarValue(mappingValue(objectCheck, "path"))
+			lines[key] = append(lines[key], objectCheck.Line)
+		}
+	}
+	return lines
+}
+
+// mappingValue returns the value of key in a YAML mapping node, or nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// scalarValue returns the value of a scalar YAML node, or "" for nil
+func scalarValue(node *yaml.Node) string {
+	if node == nil {
+		return ""
+	}
+	return node.Value
+}
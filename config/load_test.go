@@ -0,0 +1,228 @@
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFiles writes config files into a temp directory and returns it
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+	return dir
+}
+
+// TestLoadChecksJSON verifies that JSON configs load
+// exactly as they did with encoding/json
+func TestLoadChecksJSON(t *testing.T) {
+	content, err := ioutil.ReadFile("example_config.json")
+	require.NoError(t, err)
+	var expected Config
+	require.NoError(t, json.Unmarshal(content, &expected))
+
+	checks, err := LoadChecks("example_config.json")
+	require.NoError(t, err)
+	assert.Equal(t, expected, checks)
+}
+
+func TestLoadChecksYAML(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"checks.yml": `
+# Comments no longer need _comment hacks
+type: postgres
+postgres-checks:
+  - schema: mongo
+    default_threshold: 24h
+    omit_tables: [billing_03_31_snapshot]
+    checks:
+      - table: districts
+        latency:
+          timestamp_column: _data_timestamp
+          threshold: 2h
+`,
+	})
+
+	checks, err := LoadChecks(filepath.Join(dir, "checks.yml"))
+	require.NoError(t, err)
+	assert.Equal(t, Config{
+		Type: "postgres",
+		PostgresChecks: []SchemaConfig{{
+			SchemaName:       "mongo",
+			DefaultThreshold: "24h",
+			TablesToOmit:     []string{"billing_03_31_snapshot"},
+			Checks: []TableCheck{{
+				TableName: "districts",
+				Latency:   LatencyInfo{TimestampColumn: "_data_timestamp", Threshold: "2h"},
+			}},
+		}},
+	}, checks)
+}
+
+func TestLoadChecksDirectoryAndIncludes(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.yml": `
+include: [teams/*.yml, objects.json]
+postgres-checks:
+  - schema: mongo
+    default_threshold: 24h
+`,
+		"teams/districts.yml": `
+postgres-checks:
+  - schema: mongo
+    checks:
+      - table: districts
+        latency: {timestamp_column: time, threshold: 2h}
+`,
+		"teams/sections.yml": `
+postgres-checks:
+  - schema: mongo
+    omit_tables: [sections_backup]
+    checks:
+      - table: sections
+        latency: {timestamp_column: time, threshold: 3h}
+  - schema: events
+    default_threshold: 1h
+`,
+		"objects.json": `{"object-checks": [{"path": "s3://firehose/events/", "threshold": "2h"}]}`,
+	})
+
+	t.Log("Testing that included files are merged")
+	checks, err := LoadChecks(filepath.Join(dir, "main.yml"))
+	require.NoError(t, err)
+	require.Len(t, checks.PostgresChecks, 2)
+	mongo := checks.PostgresChecks[0]
+	assert.Equal(t, "mongo", mongo.SchemaName)
+	assert.Equal(t, "24h", mongo.DefaultThreshold)
+	assert.Equal(t, []string{"sections_backup"}, mongo.TablesToOmit)
+	require.Len(t, mongo.Checks, 2)
+	assert.Equal(t, "districts", mongo.Checks[0].TableName)
+	assert.Equal(t, "sections", mongo.Checks[1].TableName)
+	assert.Equal(t, "events", checks.PostgresChecks[1].SchemaName)
+	assert.Equal(t, []ObjectCheck{{Path: "s3://firehose/events/", Threshold: "2h"}}, checks.ObjectChecks)
+
+	t.Log("Testing that a directory loads the same checks, including each file once")
+	dirChecks, err := LoadChecks(dir)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, checks.PostgresChecks[0].Checks, dirChecks.PostgresChecks[0].Checks)
+	assert.Equal(t, checks.ObjectChecks, dirChecks.ObjectChecks)
+}
+
+func TestLoadChecksSilences(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.yml": `
+include: [maintenance.yml]
+silences:
+  - schema: mongo
+    end: 2024-03-05T06:00:00Z
+    reason: districts backfill
+`,
+		"maintenance.yml": `
+silences:
+  - pattern: "events.*"
+    start: "2024-03-05T00:00:00Z"
+    end: "2024-03-05T02:00:00Z"
+    reason: events migration
+`,
+	})
+
+	t.Log("Testing that silences are merged, with YAML timestamps kept as RFC 3339")
+	checks, err := LoadChecks(filepath.Join(dir, "main.yml"))
+	require.NoError(t, err)
+	assert.Equal(t, []Silence{
+		{Pattern: "events.*", Start: "2024-03-05T00:00:00Z", End: "2024-03-05T02:00:00Z", Reason: "events migration"},
+		{Schema: "mongo", End: "2024-03-05T06:00:00Z", Reason: "districts backfill"},
+	}, checks.Silences)
+}
+
+func TestLoadChecksConflicts(t *testing.T) {
+	tests := []struct {
+		title    string
+		files    map[string]string
+		expected []string
+	}{
+		{
+			title: "the same schema.table in two files",
+			files: map[string]string{
+				"a.yml": "postgres-checks:\n  - schema: mongo\n    checks:\n      - table: districts\n        latency: {threshold: 2h}\n",
+				"b.json": "{\n  \"postgres-checks\": [\n    {\n      \"schema\": \"mongo\",\n      \"checks\": [\n" +
+					"        {\"table\": \"districts\", \"latency\": {\"threshold\": \"3h\"}}\n      ]\n    }\n  ]\n}\n",
+			},
+			expected: []string{"mongo.districts", "b.json:6", "a.yml:4"},
+		},
+		{
+			title: "the same schema.table twice in one file",
+			files: map[string]string{
+				"a.yml": "postgres-checks:\n  - schema: mongo\n    checks:\n      - table: districts\n" +
+					"  - schema: mongo\n    checks:\n      - table: schools\n      - table: districts\n",
+			},
+			expected: []string{"mongo.districts", "a.yml:8 conflicts", "a.yml:4"},
+		},
+		{
+			title: "the same object check twice in one file",
+			files: map[string]string{
+				"a.yml": "object-checks:\n  - path: s3://firehose/events/\n  - path: s3://firehose/events/\n",
+			},
+			expected: []string{"s3://firehose/events/", "a.yml:3 conflicts", "a.yml:2"},
+		},
+		{
+			title: "schema defaults set in two files",
+			files: map[string]string{
+				"a.yml": "postgres-checks:\n  - schema: mongo\n    default_threshold: 2h\n",
+				"b.yml": "\npostgres-checks:\n  - schema: mongo\n    default_threshold: 3h\n",
+			},
+			expected: []string{"schema mongo", "b.yml:3", "a.yml:2"},
+		},
+		{
+			title: "different cluster types",
+			files: map[string]string{
+				"a.yml": "type: redshift\n",
+				"b.yml": "\n\ntype: postgres\n",
+			},
+			expected: []string{"b.yml:3", "a.yml:1"},
+		},
+		{
+			title: "the same object check in two files",
+			files: map[string]string{
+				"a.yml": "object-checks:\n  - path: s3://firehose/events/\n    threshold: 2h\n",
+				"b.yml": "object-checks:\n  - path: s3://firehose/other/\n  - path: s3://firehose/events/\n",
+			},
+			expected: []string{"s3://firehose/events/", "b.yml:3", "a.yml:2"},
+		},
+		{
+			title: "an include cycle",
+			files: map[string]string{
+				"a.yml": "include: [b.yml]\n",
+				"b.yml": "include: [a.yml]\n",
+			},
+			expected: []string{"include cycle"},
+		},
+		{
+			title: "an include matching nothing",
+			files: map[string]string{
+				"a.yml": "include: [missing/*.yml]\n",
+			},
+			expected: []string{"a.yml", "matched no files"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Logf("Testing that LoadChecks reports %s", test.title)
+		dir := writeFiles(t, test.files)
+
+		_, err := LoadChecks(dir)
+		require.Error(t, err)
+		for _, expected := range test.expected {
+			assert.Contains(t, err.Error(), expected)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+// Silence mutes the alerts of matching checks during a maintenance window.
+// Empty `cluster`, `schema` and `table` fields match anything, and `pattern`
+// is a glob matched against schema.table, or against the path of object
+// checks, covering everything under the paths it matches. `start` and `end`
+// are RFC 3339 times. Without a start, the silence applies until its end.
+type Silence struct {
+	Cluster string `json:"cluster"`
+	Schema  string `json:"schema"`
+	Table   string `json:"table"`
+	Pattern string `json:"pattern"`
+	Start   string `json:"start"`
+	End     string `json:"end"`
+	Reason  string `json:"reason"`
+}
+
+// Window returns when the silence starts, which is zero if it has no
+// start, and ends
+func (s Silence) Window() (time.Time, time.Time, error) {
+	var start time.Time
+	if s.Start != "" {
+		var err error
+		if start, err = time.Parse(time.RFC3339, s.Start); err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+	end, err := time.Parse(time.RFC3339, s.End)
+	return start, end, err
+}
+
+// Matches reports whether the silence applies, at the given time, to a check
+// of a table, or to an object check if schema is empty and table is its path
+func (s Silence) Matches(cluster, schema, table string, at time.Time) bool {
+	start, end, err := s.Window()
+	if err != nil || at.Before(start) || !at.Before(end) {
+		return false
+	}
+	if (s.Cluster != "" && s.Cluster != cluster) ||
+		(s.Schema != "" && s.Schema != schema) ||
+		(s.Table != "" && s.Table != table) {
+		return false
+	}
+	if s.Pattern == "" {
+		return true
+	}
+	if schema == "" {
+		return matchObjectPath(s.Pattern, table)
+	}
+	matched, err := path.Match(s.Pattern, schema+"."+table)
+	return err == nil && matched
+}
+
+// matchObjectPath reports whether pattern matches an object path or one of
+// its parents. Globs never match across '/', so this lets s3://bucket/*
+// cover s3://bucket/events/2024/ as well as s3://bucket/events/.
+func matchObjectPath(pattern, objectPath string) bool {
+	for i := len(objectPath); i > 0; i = strings.LastIndex(objectPath[:i], "/") {
+		if matched, err := path.Match(pattern, objectPath[:i]); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateSilence checks that a silence has a reason, a scope, a valid
+// pattern and a window that ends after it starts
+func ValidateSilence(s Silence) error {
+	if s.Reason == "" {
+		return fmt.Errorf("silence with no reason")
+	}
+	if s.Cluster == "" && s.Schema == "" && s.Table == "" && s.Pattern == "" {
+		return fmt.Errorf("silence %q: expected a cluster, schema, table or pattern", s.Reason)
+	}
+	if s.Table != "" && s.Schema == "" {
+		return fmt.Errorf("silence %q: table %s needs a schema", s.Reason, s.Table)
+	}
+	if _, err := path.Match(s.Pattern, ""); err != nil {
+		return fmt.Errorf("silence %q: bad pattern %q: %s", s.Reason, s.Pattern, err)
+	}
+	start, end, err := s.Window()
+	if err != nil {
+		return fmt.Errorf("silence %q: invalid window: %s", s.Reason, err)
+	}
+	if !end.After(start) {
+		return fmt.Errorf("silence %q: ends before it starts", s.Reason)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSilenceMatches(t *testing.T) {
+	at := time.Date(2024, 3, 5, 3, 0, 0, 0, time.UTC)
+	window := func(s Silence) Silence {
+		s.Start, s.End, s.Reason = "2024-03-05T00:00:00Z", "2024-03-05T06:00:00Z", "migration"
+		return s
+	}
+
+	tests := []struct {
+		title    string
+		silence  Silence
+		cluster  string
+		schema   string
+		table    string
+		at       time.Time
+		expected bool
+	}{
+		{"a schema silence", window(Silence{Schema: "mongo"}), "prod", "mongo", "districts", at, true},
+		{"another schema", window(Silence{Schema: "mongo"}), "prod", "events", "districts", at, false},
+		{"a table silence", window(Silence{Schema: "mongo", Table: "districts"}), "prod", "mongo", "districts", at, true},
+		{"another table", window(Silence{Schema: "mongo", Table: "districts"}), "prod", "mongo", "schools", at, false},
+		{"a cluster silence", window(Silence{Cluster: "prod"}), "prod", "mongo", "districts", at, true},
+		{"another cluster", window(Silence{Cluster: "prod"}), "dev", "mongo", "districts", at, false},
+		{"a matching pattern", window(Silence{Pattern: "mongo.dist*"}), "prod", "mongo", "districts", at, true},
+		{"a pattern that doesn't match", window(Silence{Pattern: "mongo.dist*"}), "prod", "mongo", "schools", at, false},
+		{"a pattern on an object path", window(Silence{Pattern: "s3://firehose/*"}), "", "", "s3://firehose/events", at, true},
+		{"a pattern on a deeper object path", window(Silence{Pattern: "s3://firehose/*"}), "", "", "s3://firehose/events/2024/", at, true},
+		{"a pattern on an object path's parent", window(Silence{Pattern: "s3://firehose/ev*"}), "", "", "s3://firehose/events/2024/", at, true},
+		{"a pattern on another object path", window(Silence{Pattern: "s3://firehose/ev*"}), "", "", "s3://firehose/logs/events/", at, false},
+		{"a time before the start", window(Silence{Schema: "mongo"}), "prod", "mongo", "districts", at.Add(-4 * time.Hour), false},
+		{"the end", window(Silence{Schema: "mongo"}), "prod", "mongo", "districts", at.Add(3 * time.Hour), false},
+		{"no start", Silence{Schema: "mongo", End: "2024-03-05T06:00:00Z"}, "prod", "mongo", "districts", at.Add(-48 * time.Hour), true},
+	}
+	for _, test := range tests {
+		t.Logf("Testing that Matches handles %s", test.title)
+		assert.Equal(t, test.expected, test.silence.Matches(test.cluster, test.schema, test.table, test.at))
+	}
+}
+
+func TestValidateSilence(t *testing.T) {
+	valid := Silence{Schema: "mongo", End: "2024-03-05T06:00:00Z", Reason: "migration"}
+	assert.NoError(t, ValidateSilence(valid))
+
+	tests := []struct {
+		title    string
+		silence  Silence
+		expected string
+	}{
+		{"no scope", Silence{End: valid.End, Reason: "migration"}, "expected a cluster, schema, table or pattern"},
+		{"a table without a schema", Silence{Table: "districts", End: valid.End, Reason: "migration"}, "needs a schema"},
+		{"a bad pattern", Silence{Pattern: "mongo.[", End: valid.End, Reason: "migration"}, "bad pattern"},
+		{"no end", Silence{Schema: "mongo", Reason: "migration"}, "invalid window"},
+		{"a bad start", Silence{Schema: "mongo", Start: "tomorrow", End: valid.End, Reason: "migration"}, "invalid window"},
+	}
+	for _, test := range tests {
+		t.Logf("Testing that ValidateSilence rejects %s", test.title)
+		err := ValidateSilence(test.silence)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), test.expected)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Validate checks that a config can be run, returning the first problem found
+func Validate(checks Config) error {
+	for _, schema := range checks.PostgresChecks {
+		if schema.SchemaName == "" {
+			return fmt.Errorf("schema entry with no name")
+		}
+		if err := validateTableThreshold(schema.SchemaName, schema.DefaultThreshold, true); err != nil {
+			return err
+		}
+		if err := validateAutoThreshold(schema.SchemaName, schema.DefaultAuto); err != nil {
+			return err
+		}
+		if !ValidFreshnessMode(schema.DefaultFreshnessMode) {
+			return fmt.Errorf("%s: unknown freshness mode %q", schema.SchemaName, schema.DefaultFreshnessMode)
+		}
+		if err := validateAutoFreshness(schema.SchemaName, schema.DefaultThreshold, schema.DefaultFreshnessMode); err != nil {
+			return err
+		}
+		if err := validateLastWrite(schema.SchemaName, schema.DefaultFreshnessMode, checks.Type); err != nil {
+			return err
+		}
+		if err := validateRunbookURL(schema.SchemaName, schema.RunbookURL); err != nil {
+			return err
+		}
+		if err := validateSLO(schema.SchemaName, schema.DefaultSLO); err != nil {
+			return err
+		}
+		if err := validateDependencies(schema.SchemaName, schema.DependsOn); err != nil {
+			return err
+		}
+		if err := validateFutureTolerance(schema.SchemaName, schema.DefaultFutureTolerance); err != nil {
+			return err
+		}
+
+		for _, check := range schema.Checks {
+			fullName := schema.SchemaName + "." + check.TableName
+			if check.TableName == "" {
+				return fmt.Errorf("%s: check with no table name", schema.SchemaName)
+			}
+			if err := validateTableThreshold(fullName, check.Latency.Threshold, false); err != nil {
+				return err
+			}
+			if err := validateAutoThreshold(fullName, check.Latency.Auto); err != nil {
+				return err
+			}
+			if !ValidFreshnessMode(check.Latency.FreshnessMode) {
+				return fmt.Errorf("%s: unknown freshness mode %q", fullName, check.Latency.FreshnessMode)
+			}
+			freshnessMode := check.Latency.FreshnessMode
+			if freshnessMode == "" {
+				freshnessMode = schema.DefaultFreshnessMode
+			}
+			if err := validateAutoFreshness(fullName, check.Latency.Threshold, freshnessMode); err != nil {
+				return err
+			}
+			if err := validateLastWrite(fullName, check.Latency.FreshnessMode, checks.Type); err != nil {
+				return err
+			}
+			if err := validateRunbookURL(fullName, check.RunbookURL); err != nil {
+				return err
+			}
+			if err := validateSLO(fullName, check.SLO); err != nil {
+				return err
+			}
+			if err := validateDependencies(fullName, check.DependsOn); err != nil {
+				return err
+			}
+			if err := validateFutureTolerance(fullName, check.Latency.FutureTolerance); err != nil {
+				return err
+			}
+			if err := validatePartition(fullName, check.Latency, freshnessMode); err != nil {
+				return err
+			}
+			if err := validateQuality(fullName, check.Quality); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, objectCheck := range checks.ObjectChecks {
+		if !strings.HasPrefix(objectCheck.Path, "s3://") {
+			return fmt.Errorf("object check %q: expected an s3://bucket/prefix path", objectCheck.Path)
+		}
+		if err := validateThreshold(objectCheck.Path, objectCheck.Threshold, false); err != nil {
+			return err
+		}
+		if err := validateRunbookURL(objectCheck.Path, objectCheck.RunbookURL); err != nil {
+			return err
+		}
+	}
+
+	for _, silence := range checks.Silences {
+		if err := ValidateSilence(silence); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateThreshold checks that threshold is a Golang duration.
+// Defaults may be empty, falling back to the global default.
+func validateThreshold(name, threshold string, optional bool) error {
+	if threshold == "" && optional {
+		return nil
+	}
+	if _, err := time.ParseDuration(threshold); err != nil {
+		return fmt.Errorf("%s: invalid threshold %q: %s", name, threshold, err)
+	}
+	return nil
+}
+
+// validateTableThreshold checks that a table threshold is a Golang
+// duration or ThresholdAuto
+func validateTableThreshold(name, threshold string, optional bool) error {
+	if threshold == ThresholdAuto {
+		return nil
+	}
+	return validateThreshold(name, threshold, optional)
+}
+
+// validateAutoThreshold checks that an automatic threshold's multiplier is
+// positive, its percentile at most 100 and its lookback a positive duration
+func validateAutoThreshold(name string, auto AutoThreshold) error {
+	if auto.Multiplier < 0 {
+		return fmt.Errorf("%s: auto threshold multiplier %v must be positive", name, auto.Multiplier)
+	}
+	if auto.Percentile < 0 || auto.Percentile > 100 {
+		return fmt.Errorf("%s: auto threshold percentile %v must be between 0 and 100", name, auto.Percentile)
+	}
+	if lookback, err := auto.LookbackDuration(); err != nil || lookback <= 0 {
+		return fmt.Errorf("%s: invalid auto threshold lookback %q", name, auto.Lookback)
+	}
+	return nil
+}
+
+// validateAutoFreshness checks that an auto threshold isn't used with the
+// last_write freshness mode, since thresholds are learned from the
+// timestamp column's update cadence
+func validateAutoFreshness(name, threshold, freshnessMode string) error {
+	if threshold == ThresholdAuto && freshnessMode == FreshnessModeLastWrite {
+		return fmt.Errorf("%s: an auto threshold needs the data_timestamp or both freshness mode", name)
+	}
+	return nil
+}
+
+// validateLastWrite checks that last write freshness, which is read from
+// Redshift system tables, is only used on Redshift clusters
+func validateLastWrite(name, freshnessMode, clusterType string) error {
+	if clusterType == "" || clusterType == "redshift" {
+		return nil
+	}
+	if freshnessMode == FreshnessModeLastWrite || freshnessMode == FreshnessModeBoth {
+		return fmt.Errorf("%s: freshness mode %q needs a redshift cluster, not %s", name, freshnessMode, clusterType)
+	}
+	return nil
+}
+
+// validateDependencies checks that every dependency names a schema or a
+// schema.table, and that a table doesn't depend on itself
+func validateDependencies(name string, dependsOn []string) error {
+	for _, dependency := range dependsOn {
+		schema, table, hasTable := strings.Cut(dependency, ".")
+		if schema == "" || (hasTable && table == "") {
+			return fmt.Errorf("%s: invalid dependency %q: expected schema or schema.table", name, dependency)
+		}
+		if dependency == name {
+			return fmt.Errorf("%s: depends on itself", name)
+		}
+	}
+	return nil
+}
+
+// validateFutureTolerance checks that a future tolerance, if set,
+// is a duration that isn't negative
+func validateFutureTolerance(name, tolerance string) error {
+	if tolerance == "" {
+		return nil
+	}
+	if parsed, err := time.ParseDuration(tolerance); err != nil || parsed < 0 {
+		return fmt.Errorf("%s: invalid future tolerance %q", name, tolerance)
+	}
+	return nil
+}
+
+// validatePartition checks that filters and groups are only used where the
+// timestamp column is checked, freshnessMode being the table's own or its
+// schema's. Grouped checks can't learn their threshold, since each group
+// updates at its own cadence.
+func validatePartition(name string, latency LatencyInfo, freshnessMode string) error {
+	if latency.Filter == nil && latency.GroupBy == "" {
+		return nil
+	}
+	if freshnessMode == FreshnessModeLastWrite {
+		return fmt.Errorf("%s: filter and group_by need the data_timestamp freshness mode", name)
+	}
+	if latency.IsGrouped() && latency.IsAuto() {
+		return fmt.Errorf("%s: group_by can't be used with an auto threshold", name)
+	}
+	if latency.Filter != nil {
+		return validateFilter(name, *latency.Filter)
+	}
+	return nil
+}
+
+// validateFilter checks that a filter names a column and a known operator,
+// with one value for = and <>, at least one for in and not in, and none
+// for is null and is not null
+func validateFilter(name string, filter Filter) error {
+	if filter.Column == "" {
+		return fmt.Errorf("%s: filter with no column", name)
+	}
+	var valid bool
+	switch filter.Operator {
+	case FilterEquals, FilterNotEquals:
+		valid = len(filter.Values) == 1
+	case FilterIn, FilterNotIn:
+		valid = len(filter.Values) > 0
+	case FilterIsNull, FilterIsNotNull:
+		valid = len(filter.Values) == 0
+	default:
+		return fmt.Errorf("%s: unknown filter operator %q", name, filter.Operator)
+	}
+	if !valid {
+		return fmt.Errorf("%s: filter operator %q can't take %d values", name, filter.Operator, len(filter.Values))
+	}
+	return nil
+}
+
+// validateQuality checks that a table's quality window is a positive
+// duration, and that each of its column checks names a column and sets
+// at least one check, within range
+func validateQuality(name string, quality QualityInfo) error {
+	if quality.Window != "" {
+		if window, err := time.ParseDuration(quality.Window); err != nil || window <= 0 {
+			return fmt.Errorf("%s: invalid quality window %q", name, quality.Window)
+		}
+	}
+	for _, column := range quality.Columns {
+		if column.Column == "" {
+			return fmt.Errorf("%s: column check with no column", name)
+		}
+		fullName := name + "." + column.Column
+		if column.MaxNullFraction == nil && column.MinDistinct == 0 && len(column.AllowedValues) == 0 {
+			return fmt.Errorf("%s: column check with no checks", fullName)
+		}
+		if column.MaxNullFraction != nil && (*column.MaxNullFraction < 0 || *column.MaxNullFraction > 1) {
+			return fmt.Errorf("%s: max null fraction %v is not between 0 and 1", fullName, *column.MaxNullFraction)
+		}
+		if column.MinDistinct < 0 {
+			return fmt.Errorf("%s: min distinct %d is negative", fullName, column.MinDistinct)
+		}
+	}
+	return nil
+}
+
+// validateRunbookURL checks that a runbook, if set, is an http(s) URL
+func validateRunbookURL(name, runbookURL string) error {
+	if runbookURL == "" {
+		return nil
+	}
+	parsed, err := url.Parse(runbookURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("%s: runbook_url %q is not an http(s) URL", name, runbookURL)
+	}
+	return nil
+}
+
+// validateSLO checks that an objective's target is a percentage that leaves
+// an error budget, and that its window is a positive duration
+func validateSLO(name string, slo SLO) error {
+	if slo.Target < 0 || slo.Target >= 100 {
+		return fmt.Errorf("%s: SLO target %v must be between 0 and 100", name, slo.Target)
+	}
+	if window, err := slo.WindowDuration(); err != nil || window <= 0 {
+		return fmt.Errorf("%s: invalid SLO window %q", name, slo.Window)
+	}
+	return nil
+}
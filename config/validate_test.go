@@ -0,0 +1,175 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	valid := func() Config {
+		return Config{
+			PostgresChecks: []SchemaConfig{{
+				SchemaName:       "mongo",
+				DefaultThreshold: "24h",
+				Checks: []TableCheck{{
+					TableName: "districts",
+					Latency:   LatencyInfo{TimestampColumn: "time", Threshold: "2h"},
+				}},
+			}},
+			ObjectChecks: []ObjectCheck{{Path: "s3://firehose/events/", Threshold: "2h"}},
+		}
+	}
+
+	tests := []struct {
+		title    string
+		mutate   func(*Config)
+		expected string
+	}{
+		{"a valid config", func(c *Config) {}, ""},
+		{"an empty schema default threshold", func(c *Config) { c.PostgresChecks[0].DefaultThreshold = "" }, ""},
+		{"a bad schema default threshold", func(c *Config) { c.PostgresChecks[0].DefaultThreshold = "1 day" }, "mongo: invalid threshold"},
+		{"a bad table threshold", func(c *Config) { c.PostgresChecks[0].Checks[0].Latency.Threshold = "2j" }, "mongo.districts: invalid threshold"},
+		{"a missing table threshold", func(c *Config) { c.PostgresChecks[0].Checks[0].Latency.Threshold = "" }, "mongo.districts: invalid threshold"},
+		{"an unknown freshness mode", func(c *Config) { c.PostgresChecks[0].Checks[0].Latency.FreshnessMode = "vibes" }, "unknown freshness mode"},
+		{"a missing table name", func(c *Config) { c.PostgresChecks[0].Checks[0].TableName = "" }, "no table name"},
+		{"a schema runbook URL", func(c *Config) { c.PostgresChecks[0].RunbookURL = "https://wiki/runbooks/mongo" }, ""},
+		{"a relative table runbook URL", func(c *Config) { c.PostgresChecks[0].Checks[0].RunbookURL = "runbooks/mongo" }, "mongo.districts: runbook_url"},
+		{"a non-http object runbook URL", func(c *Config) { c.ObjectChecks[0].RunbookURL = "ftp://wiki/runbook" }, "runbook_url"},
+		{"a schema SLO", func(c *Config) { c.PostgresChecks[0].DefaultSLO = SLO{Target: 99.5, Window: "2160h"} }, ""},
+		{"a table SLO without a window", func(c *Config) { c.PostgresChecks[0].Checks[0].SLO = SLO{Target: 95} }, ""},
+		{"an SLO target of 100%", func(c *Config) { c.PostgresChecks[0].Checks[0].SLO = SLO{Target: 100} }, "mongo.districts: SLO target"},
+		{"a bad SLO window", func(c *Config) { c.PostgresChecks[0].DefaultSLO = SLO{Target: 99, Window: "30d"} }, "mongo: invalid SLO window"},
+		{"an auto table threshold", func(c *Config) { c.PostgresChecks[0].Checks[0].Latency.Threshold = ThresholdAuto }, ""},
+		{"an auto schema default threshold", func(c *Config) {
+			c.PostgresChecks[0].DefaultThreshold = ThresholdAuto
+			c.PostgresChecks[0].DefaultAuto = AutoThreshold{Multiplier: 1.5, Percentile: 90, Lookback: "168h"}
+		}, ""},
+		{"an auto threshold on a last write check", func(c *Config) {
+			c.PostgresChecks[0].Checks[0].Latency.Threshold = ThresholdAuto
+			c.PostgresChecks[0].Checks[0].Latency.FreshnessMode = FreshnessModeLastWrite
+		}, "mongo.districts: an auto threshold needs"},
+		{"an auto threshold on a table inheriting last write", func(c *Config) {
+			c.PostgresChecks[0].Checks[0].Latency.Threshold = ThresholdAuto
+			c.PostgresChecks[0].DefaultFreshnessMode = FreshnessModeLastWrite
+		}, "mongo.districts: an auto threshold needs"},
+		{"an auto schema default on last write", func(c *Config) {
+			c.PostgresChecks[0].DefaultThreshold = ThresholdAuto
+			c.PostgresChecks[0].DefaultFreshnessMode = FreshnessModeLastWrite
+		}, "mongo: an auto threshold needs"},
+		{"an auto threshold with both freshness modes", func(c *Config) {
+			c.PostgresChecks[0].Checks[0].Latency.Threshold = ThresholdAuto
+			c.PostgresChecks[0].Checks[0].Latency.FreshnessMode = FreshnessModeBoth
+		}, ""},
+		{"last write on redshift", func(c *Config) {
+			c.Type = "redshift"
+			c.PostgresChecks[0].Checks[0].Latency.FreshnessMode = FreshnessModeLastWrite
+		}, ""},
+		{"last write on postgres", func(c *Config) {
+			c.Type = "postgres"
+			c.PostgresChecks[0].Checks[0].Latency.FreshnessMode = FreshnessModeLastWrite
+		}, "mongo.districts: freshness mode \"last_write\" needs a redshift cluster"},
+		{"a schema checking both on sqlite", func(c *Config) {
+			c.Type = "sqlite"
+			c.PostgresChecks[0].DefaultFreshnessMode = FreshnessModeBoth
+		}, "mongo: freshness mode \"both\" needs a redshift cluster"},
+		{"a negative auto multiplier", func(c *Config) { c.PostgresChecks[0].Checks[0].Latency.Auto.Multiplier = -1 }, "mongo.districts: auto threshold multiplier"},
+		{"an auto percentile over 100", func(c *Config) { c.PostgresChecks[0].DefaultAuto.Percentile = 101 }, "mongo: auto threshold percentile"},
+		{"a bad auto lookback", func(c *Config) { c.PostgresChecks[0].Checks[0].Latency.Auto.Lookback = "2w" }, "invalid auto threshold lookback"},
+		{"an auto object threshold", func(c *Config) { c.ObjectChecks[0].Threshold = ThresholdAuto }, "invalid threshold"},
+		{"dependencies", func(c *Config) {
+			c.PostgresChecks[0].DependsOn = []string{"raw"}
+			c.PostgresChecks[0].Checks[0].DependsOn = []string{"raw.districts", "mongo.schools"}
+		}, ""},
+		{"an empty dependency", func(c *Config) { c.PostgresChecks[0].DependsOn = []string{""} }, "mongo: invalid dependency"},
+		{"a dependency without a table", func(c *Config) { c.PostgresChecks[0].Checks[0].DependsOn = []string{"raw."} }, "mongo.districts: invalid dependency"},
+		{"a table depending on itself", func(c *Config) { c.PostgresChecks[0].Checks[0].DependsOn = []string{"mongo.districts"} }, "depends on itself"},
+		{"future tolerances", func(c *Config) {
+			c.PostgresChecks[0].DefaultFutureTolerance = "30m"
+			c.PostgresChecks[0].Checks[0].Latency.FutureTolerance = "0s"
+		}, ""},
+		{"an invalid future tolerance", func(c *Config) { c.PostgresChecks[0].DefaultFutureTolerance = "soon" }, "mongo: invalid future tolerance"},
+		{"a negative future tolerance", func(c *Config) { c.PostgresChecks[0].Checks[0].Latency.FutureTolerance = "-1h" }, "mongo.districts: invalid future tolerance"},
+		{"a filter and group_by", func(c *Config) {
+			c.PostgresChecks[0].Checks[0].Latency.Filter = &Filter{Column: "source", Operator: FilterNotEquals, Values: []string{"test"}}
+			c.PostgresChecks[0].Checks[0].Latency.GroupBy = "source"
+		}, ""},
+		{"a null filter", func(c *Config) {
+			c.PostgresChecks[0].Checks[0].Latency.Filter = &Filter{Column: "deleted_at", Operator: FilterIsNull}
+		}, ""},
+		{"a filter without a column", func(c *Config) {
+			c.PostgresChecks[0].Checks[0].Latency.Filter = &Filter{Operator: FilterIn, Values: []string{"sis"}}
+		}, "mongo.districts: filter with no column"},
+		{"an unknown filter operator", func(c *Config) {
+			c.PostgresChecks[0].Checks[0].Latency.Filter = &Filter{Column: "source", Operator: "like", Values: []string{"%"}}
+		}, "unknown filter operator \"like\""},
+		{"an equals filter with two values", func(c *Config) {
+			c.PostgresChecks[0].Checks[0].Latency.Filter = &Filter{Column: "source", Operator: FilterEquals, Values: []string{"a", "b"}}
+		}, "filter operator \"=\" can't take 2 values"},
+		{"an in filter without values", func(c *Config) {
+			c.PostgresChecks[0].Checks[0].Latency.Filter = &Filter{Column: "source", Operator: FilterIn}
+		}, "filter operator \"in\" can't take 0 values"},
+		{"a filter on a table inheriting last write", func(c *Config) {
+			c.Type = "redshift"
+			c.PostgresChecks[0].DefaultFreshnessMode = FreshnessModeLastWrite
+			c.PostgresChecks[0].Checks[0].Latency.Filter = &Filter{Column: "source", Operator: FilterIsNotNull}
+		}, "mongo.districts: filter and group_by need the data_timestamp freshness mode"},
+		{"a group_by on a last write check", func(c *Config) {
+			c.PostgresChecks[0].Checks[0].Latency.FreshnessMode = FreshnessModeLastWrite
+			c.PostgresChecks[0].Checks[0].Latency.GroupBy = "source"
+		}, "need the data_timestamp freshness mode"},
+		{"a group_by with an auto threshold", func(c *Config) {
+			c.PostgresChecks[0].Checks[0].Latency.Threshold = ThresholdAuto
+			c.PostgresChecks[0].Checks[0].Latency.GroupBy = "source"
+		}, "group_by can't be used with an auto threshold"},
+		{"column checks", func(c *Config) {
+			half := 0.5
+			c.PostgresChecks[0].Checks[0].Quality = QualityInfo{Window: "6h", Columns: []ColumnCheck{
+				{Column: "name", MaxNullFraction: &half, MinDistinct: 10},
+				{Column: "state", AllowedValues: []string{"active", "archived"}},
+			}}
+		}, ""},
+		{"a column check without a column", func(c *Config) {
+			c.PostgresChecks[0].Checks[0].Quality.Columns = []ColumnCheck{{MinDistinct: 1}}
+		}, "mongo.districts: column check with no column"},
+		{"a column check without checks", func(c *Config) {
+			c.PostgresChecks[0].Checks[0].Quality.Columns = []ColumnCheck{{Column: "name"}}
+		}, "mongo.districts.name: column check with no checks"},
+		{"a max null fraction over 1", func(c *Config) {
+			percent := 50.0
+			c.PostgresChecks[0].Checks[0].Quality.Columns = []ColumnCheck{{Column: "name", MaxNullFraction: &percent}}
+		}, "max null fraction 50 is not between 0 and 1"},
+		{"a zero quality window", func(c *Config) {
+			c.PostgresChecks[0].Checks[0].Quality = QualityInfo{Window: "0s"}
+		}, "invalid quality window"},
+		{"a silence", func(c *Config) {
+			c.Silences = []Silence{{Schema: "mongo", Start: "2024-03-05T00:00:00Z", End: "2024-03-05T06:00:00Z", Reason: "migration"}}
+		}, ""},
+		{"a silence without a reason", func(c *Config) {
+			c.Silences = []Silence{{Schema: "mongo", End: "2024-03-05T06:00:00Z"}}
+		}, "silence with no reason"},
+		{"a silence that ends before it starts", func(c *Config) {
+			c.Silences = []Silence{{Schema: "mongo", Start: "2024-03-05T06:00:00Z", End: "2024-03-05T00:00:00Z", Reason: "migration"}}
+		}, "ends before it starts"},
+		{"a non-s3 object path", func(c *Config) { c.ObjectChecks[0].Path = "gs://bucket/" }, "expected an s3://"},
+		{"a bad object threshold", func(c *Config) { c.ObjectChecks[0].Threshold = "soon" }, "invalid threshold"},
+	}
+
+	for _, test := range tests {
+		t.Logf("Testing that Validate handles %s", test.title)
+		checks := valid()
+		test.mutate(&checks)
+
+		err := Validate(checks)
+		if test.expected == "" {
+			assert.NoError(t, err)
+		} else if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), test.expected)
+		}
+	}
+}
+
+// TestValidateExampleConfig verifies that the deployed config is valid
+func TestValidateExampleConfig(t *testing.T) {
+	assert.NoError(t, Validate(ParseChecks("example_config.json")))
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/kardianos/osext"
+
+	"github.com/Clever/analytics-monitor/config"
+	"github.com/Clever/analytics-monitor/coverage"
+	"github.com/Clever/analytics-monitor/db"
+	l "github.com/Clever/analytics-monitor/logger"
+)
+
+// coverageCommand prints the schemas and tables of a cluster that no
+// latency check covers, and the config entries missing from the cluster
+func coverageCommand(args []string, out io.Writer) error {
+	dir, err := osext.ExecutableFolder()
+	if err != nil {
+		return err
+	}
+
+	flags := flag.NewFlagSet("coverage", flag.ContinueOnError)
+	checksConfigPath := flags.String("config", defaultChecksConfigPath(dir),
+		"checks config file or directory (env CHECKS_CONFIG_PATH)")
+	clusterName := flags.String("cluster", os.Getenv("CLUSTER_NAME"),
+		"cluster name reported, defaults to one derived from the backend (env CLUSTER_NAME)")
+	format := flags.String("format", coverage.FormatText, "output format: text or json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	checks, err := config.LoadChecks(*checksConfigPath)
+	if err != nil {
+		return err
+	}
+	if err := config.Validate(checks); err != nil {
+		return err
+	}
+	config.Parse(checks.Type)
+
+	client, err := db.NewClient(checks.Type, *clusterName)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	report, err := coverage.Build(client, checks.PostgresChecks)
+	if err != nil {
+		return fmt.Errorf("Error building coverage report: %w", err)
+	}
+	return coverage.Write(out, *format, report)
+}
+
+// performCoverageCheck logs the number of schemas or tables in each gap
+// of the cluster's coverage. Failing to build the report is logged, but
+// doesn't fail the run.
+func performCoverageCheck(client db.Client, schemaConfigs []config.SchemaConfig) {
+	report, err := coverage.Build(client, schemaConfigs)
+	if err != nil {
+		l.GetKVLogger().ErrorD("coverage-check-failed", l.M{
+			"cluster": client.GetClusterName(),
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	for _, gap := range coverage.Gaps {
+		logger.CheckCoverageEvent(report.Cluster, gap, report.Items(gap))
+	}
+}
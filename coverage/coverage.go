@@ -0,0 +1,131 @@
+package coverage
+
+import (
+	"sort"
+
+	"github.com/Clever/analytics-monitor/config"
+	"github.com/Clever/analytics-monitor/db"
+)
+
+// Gaps in coverage, as reported in check-coverage events
+const (
+	GapUnmonitoredSchema  = "unmonitored_schema"
+	GapNoTimestampColumn  = "no_timestamp_column"
+	GapOmitted            = "omitted"
+	GapMissingFromCluster = "missing_from_cluster"
+)
+
+// Gaps lists every gap in the order they're reported
+var Gaps = []string{GapUnmonitoredSchema, GapNoTimestampColumn, GapOmitted, GapMissingFromCluster}
+
+// Report lists what a cluster's latency checks don't cover. Tables are
+// named schema.table, and every list is sorted.
+type Report struct {
+	Cluster string `json:"cluster"`
+	// UnmonitoredSchemas are schemas in the cluster that aren't configured
+	UnmonitoredSchemas []string `json:"unmonitored_schemas"`
+	// NoTimestampColumn are tables of configured schemas that have no
+	// timestamp column, so aren't checked
+	NoTimestampColumn []string `json:"no_timestamp_column"`
+	// Omitted are tables of configured schemas in tables_to_omit
+	Omitted []string `json:"omitted"`
+	// MissingFromCluster are schemas, checks and tables_to_omit entries
+	// of the config that aren't in the cluster
+	MissingFromCluster []string `json:"missing_from_cluster"`
+}
+
+// Items returns the schemas or tables of a gap
+func (r Report) Items(gap string) []string {
+	switch gap {
+	case GapUnmonitoredSchema:
+		return r.UnmonitoredSchemas
+	case GapNoTimestampColumn:
+		return r.NoTimestampColumn
+	case GapOmitted:
+		return r.Omitted
+	case GapMissingFromCluster:
+		return r.MissingFromCluster
+	}
+	return nil
+}
+
+// Build compares the schemas and tables of a cluster against the schemas
+// configured to be checked in it
+func Build(client db.Client, schemaConfigs []config.SchemaConfig) (Report, error) {
+	report := Report{
+		Cluster:            client.GetClusterName(),
+		UnmonitoredSchemas: []string{},
+		NoTimestampColumn:  []string{},
+		Omitted:            []string{},
+		MissingFromCluster: []string{},
+	}
+
+	schemas, err := client.QuerySchemas()
+	if err != nil {
+		return Report{}, err
+	}
+	inCluster := make(map[string]bool)
+	for _, schemaName := range schemas {
+		inCluster[schemaName] = true
+	}
+
+	configured := make(map[string]bool)
+	for _, schemaConfig := range schemaConfigs {
+		schemaName := schemaConfig.SchemaName
+		configured[schemaName] = true
+		if !inCluster[schemaName] {
+			report.MissingFromCluster = append(report.MissingFromCluster, schemaName)
+			continue
+		}
+
+		tables, err := client.QueryTables(schemaName)
+		if err != nil {
+			return Report{}, err
+		}
+		tableMetadata, err := client.QueryTableMetadata(schemaName)
+		if err != nil {
+			return Report{}, err
+		}
+
+		omitted := make(map[string]bool)
+		for _, tableName := range schemaConfig.TablesToOmit {
+			omitted[tableName] = true
+		}
+		tableExists := make(map[string]bool)
+		for _, tableName := range tables {
+			tableExists[tableName] = true
+			fullTableName := schemaName + "." + tableName
+			if omitted[tableName] {
+				report.Omitted = append(report.Omitted, fullTableName)
+			} else if _, ok := tableMetadata[tableName]; !ok {
+				report.NoTimestampColumn = append(report.NoTimestampColumn, fullTableName)
+			}
+		}
+
+		missing := make(map[string]bool)
+		for _, check := range schemaConfig.Checks {
+			if !tableExists[check.TableName] {
+				missing[check.TableName] = true
+			}
+		}
+		for tableName := range omitted {
+			if !tableExists[tableName] {
+				missing[tableName] = true
+			}
+		}
+		for tableName := range missing {
+			report.MissingFromCluster = append(report.MissingFromCluster, schemaName+"."+tableName)
+		}
+	}
+
+	for _, schemaName := range schemas {
+		if !configured[schemaName] {
+			report.UnmonitoredSchemas = append(report.UnmonitoredSchemas, schemaName)
+		}
+	}
+
+	for _, gap := range Gaps {
+		sort.Strings(report.Items(gap))
+	}
+	return report, nil
+}
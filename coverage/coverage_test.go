@@ -0,0 +1,118 @@
+package coverage
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Clever/analytics-monitor/config"
+	"github.com/Clever/analytics-monitor/db"
+)
+
+// stubClient serves schemas and tables from maps. Other
+// methods of db.Client panic if called.
+type stubClient struct {
+	db.Client
+	// tables lists the tables of each schema, and timestamped
+	// the ones with a timestamp column
+	tables      map[string][]string
+	timestamped map[string][]string
+}
+
+func (c *stubClient) GetClusterName() string {
+	return "mockClusterName"
+}
+
+func (c *stubClient) QuerySchemas() ([]string, error) {
+	var schemas []string
+	for schemaName := range c.tables {
+		schemas = append(schemas, schemaName)
+	}
+	return schemas, nil
+}
+
+func (c *stubClient) QueryTables(schemaName string) ([]string, error) {
+	return c.tables[schemaName], nil
+}
+
+func (c *stubClient) QueryTableMetadata(schemaName string) (map[string]db.TableMetadata, error) {
+	tableMetadata := make(map[string]db.TableMetadata)
+	for _, tableName := range c.timestamped[schemaName] {
+		tableMetadata[tableName] = db.TableMetadata{TableName: tableName, TimestampColumn: "_data_timestamp"}
+	}
+	return tableMetadata, nil
+}
+
+func TestBuild(t *testing.T) {
+	client := &stubClient{
+		tables: map[string][]string{
+			"mongo":    {"districts", "schools", "lookup", "sections"},
+			"scratch":  {"tmp"},
+			"segment":  {"tracks"},
+			"internal": {},
+		},
+		timestamped: map[string][]string{
+			"mongo":   {"districts", "schools"},
+			"segment": {"tracks"},
+		},
+	}
+	schemaConfigs := []config.SchemaConfig{
+		{
+			SchemaName:   "mongo",
+			TablesToOmit: []string{"sections", "teachers"},
+			Checks: []config.TableCheck{
+				{TableName: "districts"},
+				{TableName: "students"},
+			},
+		},
+		{SchemaName: "segment"},
+		{SchemaName: "salesforce", Checks: []config.TableCheck{{TableName: "accounts"}}},
+	}
+
+	report, err := Build(client, schemaConfigs)
+	require.NoError(t, err)
+
+	t.Logf("Testing that schemas in the cluster but not the config are unmonitored")
+	assert.Equal(t, "mockClusterName", report.Cluster)
+	assert.Equal(t, []string{"internal", "scratch"}, report.UnmonitoredSchemas)
+
+	t.Logf("Testing that tables without a timestamp column are listed, unless omitted")
+	assert.Equal(t, []string{"mongo.lookup"}, report.NoTimestampColumn)
+	assert.Equal(t, []string{"mongo.sections"}, report.Omitted)
+
+	t.Logf("Testing that schemas, checks and omitted tables missing from the cluster are listed")
+	assert.Equal(t, []string{"mongo.students", "mongo.teachers", "salesforce"}, report.MissingFromCluster)
+}
+
+func TestWrite(t *testing.T) {
+	report := Report{
+		Cluster:            "mockClusterName",
+		UnmonitoredSchemas: []string{"scratch"},
+		NoTimestampColumn:  []string{"mongo.lookup", "mongo.sections"},
+		Omitted:            []string{},
+		MissingFromCluster: []string{"mongo.students"},
+	}
+
+	t.Logf("Testing that the text format lists each gap with its count")
+	var out bytes.Buffer
+	require.NoError(t, Write(&out, FormatText, report))
+	assert.Contains(t, out.String(), "Schemas not configured (1):\n  scratch\n")
+	assert.Contains(t, out.String(), "Tables with no timestamp column (2):\n  mongo.lookup\n  mongo.sections\n")
+	assert.Contains(t, out.String(), "Tables omitted by tables_to_omit (0):\n")
+	assert.True(t, strings.HasSuffix(out.String(), "Config entries missing from the cluster (1):\n  mongo.students\n"))
+
+	t.Logf("Testing that the JSON format round trips, with empty gaps as empty lists")
+	out.Reset()
+	require.NoError(t, Write(&out, FormatJSON, report))
+	assert.Contains(t, out.String(), `"omitted": []`)
+	var decoded Report
+	require.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+	assert.Equal(t, report, decoded)
+
+	t.Logf("Testing that unknown formats are rejected")
+	assert.Error(t, Write(&out, "csv", report))
+}
@@ -0,0 +1,52 @@
+package coverage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Formats that reports can be written in
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// gapHeadings introduce each gap in text reports
+var gapHeadings = map[string]string{
+	GapUnmonitoredSchema:  "Schemas not configured",
+	GapNoTimestampColumn:  "Tables with no timestamp column",
+	GapOmitted:            "Tables omitted by tables_to_omit",
+	GapMissingFromCluster: "Config entries missing from the cluster",
+}
+
+// Write writes a report in the given format
+func Write(out io.Writer, format string, report Report) error {
+	switch format {
+	case FormatText:
+		return writeText(out, report)
+	case FormatJSON:
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	return fmt.Errorf("unknown report format %q: expected text or json", format)
+}
+
+// writeText writes each gap under a heading with its count,
+// followed by its schemas or tables, one per line
+func writeText(out io.Writer, report Report) error {
+	for i, gap := range Gaps {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		items := report.Items(gap)
+		fmt.Fprintf(out, "%s (%d):\n", gapHeadings[gap], len(items))
+		for _, item := range items {
+			if _, err := fmt.Fprintf(out, "  %s\n", item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	l "github.com/Clever/analytics-monitor/logger"
+)
+
+// DefaultLatencyBatchSize is the number of tables checked per
+// UNION ALL query when no batch size is configured
+const DefaultLatencyBatchSize = 50
+
+// NullGroup is the group of rows whose group_by column is null
+const NullGroup = "NULL"
+
+// LatencyRequest identifies a table to check and its timestamp column.
+// If BoundedBy is set, timestamps after it are ignored when computing
+// latency, so bogus future rows can't make a stale table look fresh.
+// If Filter is set, only rows matching it are checked.
+// GroupBy is only used by QueryGroupLatencies.
+type LatencyRequest struct {
+	TableName       string
+	TimestampColumn string
+	BoundedBy       time.Time
+	Filter          *Filter
+	GroupBy         string
+}
+
+// Filter restricts a query to the rows whose Column compares to Values by
+// Operator, one of the config package's filter operators
+type Filter struct {
+	Column   string
+	Operator string
+	Values   []string
+}
+
+// LatencyResult holds the outcome of a latency query for a single table.
+// See QueryLatency for the meaning of LatencyHrs and HasRows. MaxTimestamp
+// is the most recent timestamp itself, if HasRows. Both only count
+// timestamps up to the request's bound, whereas UnboundedMaxTimestamp is
+// the most recent timestamp of all, if the table has any rows.
+type LatencyResult struct {
+	LatencyHrs            int64
+	HasRows               bool
+	MaxTimestamp          time.Time
+	UnboundedMaxTimestamp time.Time
+	Err                   error
+}
+
+// latencyFromEpoch converts the most recent timestamp of a table,
+// as epoch seconds, into hours of latency
+func latencyFromEpoch(maxEpoch sql.NullFloat64) (int64, bool) {
+	hourDiff := (time.Now().Unix() - int64(maxEpoch.Float64)) / 3600
+	return hourDiff, maxEpoch.Valid
+}
+
+// latencyResultFromEpoch converts the most recent timestamp of a table,
+// as epoch seconds, into a LatencyResult
+func latencyResultFromEpoch(maxEpoch sql.NullFloat64) LatencyResult {
+	latencyHrs, hasRows := latencyFromEpoch(maxEpoch)
+	result := LatencyResult{LatencyHrs: latencyHrs, HasRows: hasRows}
+	if hasRows {
+		result.MaxTimestamp = time.Unix(int64(maxEpoch.Float64), 0).UTC()
+		result.UnboundedMaxTimestamp = result.MaxTimestamp
+	}
+	return result
+}
+
+// boundedLatencyResult converts the most recent timestamp of a table, and
+// the most recent up to a bound, as epoch seconds, into a LatencyResult
+func boundedLatencyResult(maxEpoch, boundedEpoch sql.NullFloat64) LatencyResult {
+	result := latencyResultFromEpoch(boundedEpoch)
+	if maxEpoch.Valid {
+		result.UnboundedMaxTimestamp = time.Unix(int64(maxEpoch.Float64), 0).UTC()
+	}
+	return result
+}
+
+// QueryLatencies returns the latency of several tables in a schema, indexed by
+// table name. Tables are queried in chunks of the configured batch size with a
+// single UNION ALL query each. If a chunk fails, its tables are queried one
+// at a time so a single bad table can't fail the rest of the chunk.
+func (c *sqlClient) QueryLatencies(schemaName string, requests []LatencyRequest) map[string]LatencyResult {
+	return batchLatencies(schemaName, requests, c.batchSize,
+		func(chunk []LatencyRequest) (map[string]LatencyResult, error) {
+			return c.queryLatencyBatch(schemaName, chunk)
+		},
+		func(request LatencyRequest) LatencyResult {
+			results, err := c.queryLatencyBatch(schemaName, []LatencyRequest{request})
+			if err != nil {
+				return LatencyResult{Err: err}
+			}
+			return results[request.TableName]
+		},
+	)
+}
+
+// queryLatencyBatch runs one UNION ALL latency query for a chunk of tables
+func (c *sqlClient) queryLatencyBatch(schemaName string, chunk []LatencyRequest) (map[string]LatencyResult, error) {
+	query, args := c.dialect.batchLatencyQuery(schemaName, chunk)
+
+	var results map[string]LatencyResult
+	err := c.retry.Do(fmt.Sprintf("latency batch %s (%d tables)", schemaName, len(chunk)), func() error {
+		results = make(map[string]LatencyResult)
+		rows, err := c.session.Query(query, args...)
+		if err != nil {
+			return fmt.Errorf("Error executing latency batch for schema %s: %w", schemaName, err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var idx int
+			var maxEpoch, boundedEpoch sql.NullFloat64
+			if err := rows.Scan(&idx, &maxEpoch, &boundedEpoch); err != nil {
+				return fmt.Errorf("Unable to scan latency batch row for schema %s: %w", schemaName, err)
+			}
+			if idx < 0 || idx >= len(chunk) {
+				return fmt.Errorf("Unexpected latency batch row %d for schema %s", idx, schemaName)
+			}
+
+			results[chunk[idx].TableName] = boundedLatencyResult(maxEpoch, boundedEpoch)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(results) != len(chunk) {
+		return nil, fmt.Errorf("Latency batch for schema %s returned %d of %d tables",
+			schemaName, len(results), len(chunk))
+	}
+
+	return results, nil
+}
+
+// batchLatencies splits requests into chunks of batchSize, runs queryBatch on
+// each and falls back to querySingle for every table of a chunk that fails.
+// A batchSize of 1 or less queries every table individually.
+func batchLatencies(schemaName string, requests []LatencyRequest, batchSize int,
+	queryBatch func([]LatencyRequest) (map[string]LatencyResult, error),
+	querySingle func(LatencyRequest) LatencyResult,
+) map[string]LatencyResult {
+	// Sort so that chunks are stable between runs
+	sorted := append([]LatencyRequest(nil), requests...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].TableName < sorted[j].TableName })
+
+	results := make(map[string]LatencyResult)
+	if batchSize <= 1 {
+		for _, request := range sorted {
+			results[request.TableName] = querySingle(request)
+		}
+		return results
+	}
+
+	for start := 0; start < len(sorted); start += batchSize {
+		end := start + batchSize
+		if end > len(sorted) {
+			end = len(sorted)
+		}
+		chunk := sorted[start:end]
+
+		chunkResults, err := queryBatch(chunk)
+		if err == nil {
+			for tableName, result := range chunkResults {
+				results[tableName] = result
+			}
+			continue
+		}
+
+		l.GetKVLogger().WarnD("latency-batch-fallback", l.M{
+			"schema": schemaName,
+			"tables": len(chunk),
+			"error":  err.Error(),
+		})
+		for _, request := range chunk {
+			results[request.TableName] = querySingle(request)
+		}
+	}
+
+	return results
+}
+
+// QueryGroupLatencies returns the latency of each value of a table's
+// GroupBy column, indexed by the value. Null values are indexed by
+// NullGroup. Tables without rows have no groups.
+func (c *sqlClient) QueryGroupLatencies(schemaName string, request LatencyRequest) (map[string]LatencyResult, error) {
+	query, args := c.dialect.groupLatencyQuery(schemaName, request)
+
+	var results map[string]LatencyResult
+	err := c.retry.Do(fmt.Sprintf("group latency %s.%s", schemaName, request.TableName), func() error {
+		results = make(map[string]LatencyResult)
+		rows, err := c.session.Query(query, args...)
+		if err != nil {
+			return fmt.Errorf("Error executing group latency query for %s.%s: %w", schemaName, request.TableName, err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var group sql.NullString
+			var maxEpoch, boundedEpoch sql.NullFloat64
+			if err := rows.Scan(&group, &maxEpoch, &boundedEpoch); err != nil {
+				return fmt.Errorf("Unable to scan group latency row for %s.%s: %w", schemaName, request.TableName, err)
+			}
+			if !group.Valid {
+				group.String = NullGroup
+			}
+			results[group.String] = boundedLatencyResult(maxEpoch, boundedEpoch)
+		}
+		return rows.Err()
+	})
+	return results, err
+}
@@ -0,0 +1,59 @@
+package db
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchLatencies(t *testing.T) {
+	requests := []LatencyRequest{
+		{TableName: "e", TimestampColumn: "time"},
+		{TableName: "d", TimestampColumn: "time"},
+		{TableName: "c", TimestampColumn: "time"},
+		{TableName: "b", TimestampColumn: "time"},
+		{TableName: "a", TimestampColumn: "time"},
+	}
+
+	var batches [][]string
+	var singles []string
+	queryBatch := func(chunk []LatencyRequest) (map[string]LatencyResult, error) {
+		var names []string
+		results := make(map[string]LatencyResult)
+		for _, request := range chunk {
+			names = append(names, request.TableName)
+			if request.TableName == "c" {
+				// One bad table poisons the whole batch
+				return nil, errors.New("relation does not exist")
+			}
+			results[request.TableName] = LatencyResult{LatencyHrs: 1, HasRows: true}
+		}
+		batches = append(batches, names)
+		return results, nil
+	}
+	querySingle := func(request LatencyRequest) LatencyResult {
+		singles = append(singles, request.TableName)
+		if request.TableName == "c" {
+			return LatencyResult{Err: errors.New("relation does not exist")}
+		}
+		return LatencyResult{LatencyHrs: 2, HasRows: true}
+	}
+
+	t.Log("Testing that tables are chunked and failed chunks fall back to single queries")
+	results := batchLatencies("test", requests, 2, queryBatch, querySingle)
+	assert.Equal(t, [][]string{{"a", "b"}, {"e"}}, batches)
+	assert.Equal(t, []string{"c", "d"}, singles)
+	assert.Len(t, results, 5)
+	assert.Equal(t, int64(1), results["a"].LatencyHrs)
+	assert.Equal(t, int64(2), results["d"].LatencyHrs)
+	assert.Error(t, results["c"].Err)
+	assert.NoError(t, results["e"].Err)
+
+	t.Log("Testing that a batch size of 1 queries every table individually")
+	batches, singles = nil, nil
+	results = batchLatencies("test", requests, 1, queryBatch, querySingle)
+	assert.Empty(t, batches)
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, singles)
+	assert.Len(t, results, 5)
+}
@@ -0,0 +1,342 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Clever/analytics-monitor/config"
+)
+
+// Client exposes a warehouse-agnostic interface for querying a cluster.
+// The SQL for each kind of warehouse is generated by its Dialect.
+type Client interface {
+	GetClusterName() string
+	Dialect() Dialect
+	QueryTableMetadata(schemaName string) (map[string]TableMetadata, error)
+	QueryTables(schemaName string) ([]string, error)
+	QueryColumns(schemaName string) (map[string][]Column, error)
+	QuerySchemas() ([]string, error)
+	QueryLatency(timestampColumn, schemaName, tableName string) (int64, bool, error)
+	QueryLatencies(schemaName string, requests []LatencyRequest) map[string]LatencyResult
+	QueryGroupLatencies(schemaName string, request LatencyRequest) (map[string]LatencyResult, error)
+	QueryRecentTimestamps(timestampColumn, schemaName, tableName string, filter *Filter, since time.Time, limit int) ([]time.Time, error)
+	QueryColumnStats(schemaName, tableName, timestampColumn string, since time.Time, requests []ColumnStatsRequest) ([]ColumnStats, error)
+	QueryLastWrites(schemaName string) (map[string]LatencyResult, error)
+	QuerySTLLoadErrors() ([]LoadError, error)
+	Ping() error
+	Close() error
+}
+
+// sqlClient provides a default implementation of Client for any
+// database/sql driver, generating queries with the cluster's dialect.
+type sqlClient struct {
+	session     *sql.DB
+	clusterName string
+	dialect     Dialect
+	retry       RetryPolicy
+	batchSize   int
+}
+
+// ignoredLoadErrorPrefix excludes noisy github events firehose
+// deliveries from the load errors check
+const ignoredLoadErrorPrefix = "s3://firehose-prod/github-events"
+
+// TableMetadata contains information about a table in a cluster
+type TableMetadata struct {
+	TableName       string
+	TimestampColumn string
+}
+
+// Column is a column of a table and its data type
+type Column struct {
+	Name string
+	Type string
+}
+
+// LoadError contains information surfacing load errors
+type LoadError struct {
+	TableNames string `json:"table_names"`
+	ErrorCode  int64  `json:"error_code"`
+	Count      int64  `json:"count"`
+}
+
+// NewClient initializes a client for the given cluster type, one of
+// "redshift" (the default), "postgres", "mysql" or "sqlite". If clusterName
+// is empty, a name is derived from the backend.
+func NewClient(clusterType, clusterName string) (Client, error) {
+	switch clusterType {
+	case "mysql":
+		return NewMySQLClient(clusterName)
+	case "sqlite":
+		return NewSQLiteClient(clusterName)
+	}
+
+	dialect, err := DialectFor(clusterType)
+	if err != nil {
+		return nil, err
+	}
+	return NewPostgresClient(dialect, clusterName)
+}
+
+// configuredRetryPolicy returns the retry policy set by environment variables
+func configuredRetryPolicy() RetryPolicy {
+	retry := DefaultRetryPolicy
+	retry.MaxRetries = config.DBMaxRetries
+	retry.BaseDelay = config.DBRetryBaseDelay
+	return retry
+}
+
+// GetClusterName returns the name of the client cluster
+func (c *sqlClient) GetClusterName() string {
+	return c.clusterName
+}
+
+// Dialect returns the SQL dialect of the client cluster
+func (c *sqlClient) Dialect() Dialect {
+	return c.dialect
+}
+
+// Ping checks that the cluster can be reached with the configured
+// credentials, retrying transient errors
+func (c *sqlClient) Ping() error {
+	err := c.retry.Do("ping", c.session.Ping)
+	if err != nil {
+		return fmt.Errorf("connecting to %s cluster %s: %s", c.dialect.Name(), c.clusterName, err)
+	}
+	return nil
+}
+
+// Close releases the client's connections
+func (c *sqlClient) Close() error {
+	return c.session.Close()
+}
+
+// QueryTableMetadata returns a map of tables
+// belonging to a given schema, indexed
+// by table name.
+// It also attempts to infer the timestamp column, by
+// choosing the alphabetically lowest column with a
+// timestamp type. We use this as a heuristic since a
+// lot of our timestamp columns are prefixed with "_".
+func (c *sqlClient) QueryTableMetadata(schemaName string) (map[string]TableMetadata, error) {
+	query, args := c.dialect.tableMetadataQuery(schemaName)
+
+	var tableMetadata map[string]TableMetadata
+	err := c.retry.Do("table-metadata "+schemaName, func() error {
+		tableMetadata = make(map[string]TableMetadata)
+		rows, err := c.session.Query(query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var row TableMetadata
+			if err := rows.Scan(&row.TableName, &row.TimestampColumn); err != nil {
+				return fmt.Errorf("Unable to scan row for schema %s: %w", schemaName, err)
+			}
+
+			tableMetadata[row.TableName] = row
+		}
+		return rows.Err()
+	})
+
+	return tableMetadata, err
+}
+
+// QueryTables returns the names of every table in a schema, sorted,
+// including those without a timestamp column
+func (c *sqlClient) QueryTables(schemaName string) ([]string, error) {
+	query, args := c.dialect.tablesQuery(schemaName)
+	return c.queryNames("tables "+schemaName, query, args)
+}
+
+// QueryColumns returns the columns of every table in a schema,
+// indexed by table name, in the order they're declared
+func (c *sqlClient) QueryColumns(schemaName string) (map[string][]Column, error) {
+	query, args := c.dialect.columnsQuery(schemaName)
+
+	var columns map[string][]Column
+	err := c.retry.Do("columns "+schemaName, func() error {
+		columns = make(map[string][]Column)
+		rows, err := c.session.Query(query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var tableName string
+			var column Column
+			if err := rows.Scan(&tableName, &column.Name, &column.Type); err != nil {
+				return fmt.Errorf("Unable to scan column for schema %s: %w", schemaName, err)
+			}
+			columns[tableName] = append(columns[tableName], column)
+		}
+		return rows.Err()
+	})
+
+	return columns, err
+}
+
+// QuerySchemas returns the names of every non-system schema, sorted
+func (c *sqlClient) QuerySchemas() ([]string, error) {
+	return c.queryNames("schemas", c.dialect.schemasQuery(), nil)
+}
+
+// queryNames runs a query selecting a single column of names
+// and returns them sorted
+func (c *sqlClient) queryNames(description, query string, args []interface{}) ([]string, error) {
+	var names []string
+	err := c.retry.Do(description, func() error {
+		names = nil
+		rows, err := c.session.Query(query, args...)
+		if err != nil {
+			return fmt.Errorf("Error querying %s: %w", description, err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				return fmt.Errorf("Unable to scan row querying %s: %w", description, err)
+			}
+			names = append(names, name)
+		}
+		return rows.Err()
+	})
+	sort.Strings(names)
+	return names, err
+}
+
+// QueryLatency returns the latency for a given table,
+// defined as the time difference in hours between now
+// and the most recent record in a table. Returns the latency,
+// if applicable, and whether or not the table contains rows
+func (c *sqlClient) QueryLatency(timestampColumn, schemaName, tableName string) (int64, bool, error) {
+	maxEpoch, err := c.queryMaxEpoch(timestampColumn, schemaName, tableName)
+	if err != nil {
+		return 0, false, err
+	}
+	hourDiff, hasRows := latencyFromEpoch(maxEpoch)
+	return hourDiff, hasRows, nil
+}
+
+// queryMaxEpoch returns the most recent timestamp in a table as epoch
+// seconds, which is null if the table has no rows
+func (c *sqlClient) queryMaxEpoch(timestampColumn, schemaName, tableName string) (sql.NullFloat64, error) {
+	query := c.dialect.latencyQuery(timestampColumn, schemaName, tableName)
+	var latency sql.NullFloat64
+	err := c.retry.Do(fmt.Sprintf("latency %s.%s", schemaName, tableName), func() error {
+		rows, err := c.session.Query(query)
+		if err != nil {
+			return fmt.Errorf("Error executing query %s: %w", query, err)
+		}
+		defer rows.Close()
+
+		rows.Next()
+		if err := rows.Scan(&latency); err != nil {
+			return fmt.Errorf("Unable to scan row for query %s: %w", query, err)
+		}
+		return nil
+	})
+	return latency, err
+}
+
+// QueryRecentTimestamps returns the distinct hours, most recent first, in
+// which a table's rows matching filter, or all its rows if it's nil, have
+// timestamps since the given time, up to limit hours. It scans the table,
+// so is much more expensive than QueryLatency.
+func (c *sqlClient) QueryRecentTimestamps(timestampColumn, schemaName, tableName string, filter *Filter,
+	since time.Time, limit int) ([]time.Time, error) {
+	query, args := c.dialect.recentTimestampsQuery(timestampColumn, schemaName, tableName, filter, since, limit)
+
+	var hours []time.Time
+	err := c.retry.Do(fmt.Sprintf("recent timestamps %s.%s", schemaName, tableName), func() error {
+		hours = nil
+		rows, err := c.session.Query(query, args...)
+		if err != nil {
+			return fmt.Errorf("Error executing query %s: %w", query, err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var hourEpoch float64
+			if err := rows.Scan(&hourEpoch); err != nil {
+				return fmt.Errorf("Unable to scan row for query %s: %w", query, err)
+			}
+			hours = append(hours, time.Unix(int64(hourEpoch), 0).UTC())
+		}
+		return rows.Err()
+	})
+	return hours, err
+}
+
+// QueryLastWrites returns, for each table in a schema, the time in hours since
+// the table last received rows, indexed by table name. It reads Redshift's
+// stl_insert system table rather than scanning the tables themselves, so it is
+// much cheaper than QueryLatency. Tables with no write in the system table's
+// retention window are omitted.
+func (c *sqlClient) QueryLastWrites(schemaName string) (map[string]LatencyResult, error) {
+	dialect, ok := c.dialect.(lastWriteDialect)
+	if !ok {
+		return nil, fmt.Errorf("Unable to query last writes on %s: %w", c.dialect.Name(), ErrUnsupportedCheck)
+	}
+	query, args := dialect.lastWriteQuery(schemaName)
+
+	var lastWrites map[string]LatencyResult
+	err := c.retry.Do("last-writes "+schemaName, func() error {
+		lastWrites = make(map[string]LatencyResult)
+		rows, err := c.session.Query(query, args...)
+		if err != nil {
+			return fmt.Errorf("Error querying last writes for schema %s: %w", schemaName, err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var tableName string
+			var lastWrite sql.NullFloat64
+			if err := rows.Scan(&tableName, &lastWrite); err != nil {
+				return fmt.Errorf("Unable to scan last write row for schema %s: %w", schemaName, err)
+			}
+
+			lastWrites[tableName] = latencyResultFromEpoch(lastWrite)
+		}
+		return rows.Err()
+	})
+
+	return lastWrites, err
+}
+
+// QuerySTLLoadErrors returns a summary of recent Redshift load errors
+func (c *sqlClient) QuerySTLLoadErrors() ([]LoadError, error) {
+	dialect, ok := c.dialect.(loadErrorsDialect)
+	if !ok {
+		return nil, fmt.Errorf("Unable to query load errors on %s: %w", c.dialect.Name(), ErrUnsupportedCheck)
+	}
+	query, args := dialect.loadErrorsQuery(ignoredLoadErrorPrefix)
+
+	var loadErrors []LoadError
+	err := c.retry.Do("stl-load-errors", func() error {
+		loadErrors = nil
+		rows, err := c.session.Query(query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var row LoadError
+			if err := rows.Scan(&row.Count, &row.ErrorCode, &row.TableNames); err != nil {
+				return fmt.Errorf("Unable to scan row: %w", err)
+			}
+
+			loadErrors = append(loadErrors, row)
+		}
+		return rows.Err()
+	})
+
+	return loadErrors, err
+}
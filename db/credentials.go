@@ -0,0 +1,193 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Clever/pq"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/redshift"
+	"github.com/aws/aws-sdk-go/service/redshift/redshiftiface"
+
+	l "github.com/Clever/analytics-monitor/logger"
+)
+
+// credentialRefreshMargin is how long before they expire credentials are
+// refreshed, so a connection isn't opened with credentials about to lapse
+const credentialRefreshMargin = time.Minute
+
+// Credentials are a database username and password. A zero Expiration
+// means the credentials don't expire.
+type Credentials struct {
+	Username   string
+	Password   string
+	Expiration time.Time
+}
+
+// CredentialProvider fetches credentials for a new database connection,
+// e.g. short-lived credentials instead of a static password.
+type CredentialProvider interface {
+	Credentials(ctx context.Context) (Credentials, error)
+}
+
+// CommandCredentialProvider runs an external command that prints
+// credentials as JSON, e.g.
+//
+//	{"user": "monitor", "password": "...", "expiration": "2024-01-02T15:04:05Z"}
+//
+// The expiration is an optional RFC 3339 timestamp.
+type CommandCredentialProvider struct {
+	// Command is run with `sh -c`
+	Command string
+}
+
+// commandCredentials is the JSON printed by a credentials command
+type commandCredentials struct {
+	User       string    `json:"user"`
+	Password   string    `json:"password"`
+	Expiration time.Time `json:"expiration"`
+}
+
+// Credentials runs the command and parses its output
+func (p CommandCredentialProvider) Credentials(ctx context.Context) (Credentials, error) {
+	cmd := exec.CommandContext(ctx, "sh", "-c", p.Command)
+	output, err := cmd.Output()
+	if err != nil {
+		// Only report stderr, since stdout may hold a password
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return Credentials{}, fmt.Errorf("credentials command failed: %s: %s",
+				err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return Credentials{}, fmt.Errorf("credentials command failed: %s", err)
+	}
+
+	var parsed commandCredentials
+	if err := json.Unmarshal(output, &parsed); err != nil {
+		return Credentials{}, fmt.Errorf("credentials command printed invalid JSON")
+	}
+	if parsed.User == "" || parsed.Password == "" {
+		return Credentials{}, fmt.Errorf("credentials command must print a user and password")
+	}
+	return Credentials{parsed.User, parsed.Password, parsed.Expiration}, nil
+}
+
+// RedshiftCredentialProvider fetches temporary credentials for a database
+// user with the Redshift GetClusterCredentials API, authenticating with the
+// default AWS credential chain.
+type RedshiftCredentialProvider struct {
+	api               redshiftiface.RedshiftAPI
+	clusterIdentifier string
+	dbUser            string
+	dbName            string
+}
+
+// NewRedshiftCredentialProvider returns a provider of temporary credentials
+// for dbUser on the cluster with the given identifier
+func NewRedshiftCredentialProvider(region, clusterIdentifier, dbUser, dbName string) (*RedshiftCredentialProvider, error) {
+	sess, err := session.NewSession(aws.NewConfig().WithRegion(region))
+	if err != nil {
+		return nil, err
+	}
+	return &RedshiftCredentialProvider{redshift.New(sess), clusterIdentifier, dbUser, dbName}, nil
+}
+
+// Credentials calls GetClusterCredentials. The returned username is
+// prefixed with "IAM:", as Redshift expects.
+func (p *RedshiftCredentialProvider) Credentials(ctx context.Context) (Credentials, error) {
+	input := &redshift.GetClusterCredentialsInput{
+		ClusterIdentifier: aws.String(p.clusterIdentifier),
+		DbUser:            aws.String(p.dbUser),
+	}
+	if p.dbName != "" {
+		input.DbName = aws.String(p.dbName)
+	}
+
+	output, err := p.api.GetClusterCredentialsWithContext(ctx, input)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("fetching cluster credentials: %s", err)
+	}
+	return Credentials{
+		Username:   aws.StringValue(output.DbUser),
+		Password:   aws.StringValue(output.DbPassword),
+		Expiration: aws.TimeValue(output.Expiration),
+	}, nil
+}
+
+// cachingCredentialProvider reuses credentials until they are about to
+// expire. Credentials without an expiration are fetched for every connection.
+type cachingCredentialProvider struct {
+	provider CredentialProvider
+	now      func() time.Time
+
+	mu     sync.Mutex
+	cached Credentials
+}
+
+// newCachingCredentialProvider wraps provider with a cache
+func newCachingCredentialProvider(provider CredentialProvider) *cachingCredentialProvider {
+	return &cachingCredentialProvider{provider: provider, now: time.Now}
+}
+
+func (p *cachingCredentialProvider) Credentials(ctx context.Context) (Credentials, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.cached.Expiration.IsZero() && p.now().Add(credentialRefreshMargin).Before(p.cached.Expiration) {
+		return p.cached, nil
+	}
+
+	credentials, err := p.provider.Credentials(ctx)
+	if err != nil {
+		return Credentials{}, err
+	}
+	l.GetKVLogger().InfoD("db-credentials-refreshed", l.M{
+		"username":   credentials.Username,
+		"expiration": credentials.Expiration,
+	})
+	p.cached = credentials
+	return credentials, nil
+}
+
+// credentialConnector is a driver.Connector that fetches credentials
+// from its provider before opening each Postgres connection
+type credentialConnector struct {
+	params   map[string]string
+	provider CredentialProvider
+}
+
+// newCredentialConnector returns a connector for the given connection
+// parameters, whose user and password are set by provider
+func newCredentialConnector(params map[string]string, provider CredentialProvider) driver.Connector {
+	return &credentialConnector{params, newCachingCredentialProvider(provider)}
+}
+
+func (c *credentialConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	credentials, err := c.provider.Credentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	params := make(map[string]string, len(c.params)+2)
+	for key, value := range c.params {
+		params[key] = value
+	}
+	params["user"] = credentials.Username
+	params["password"] = credentials.Password
+
+	connector, err := pq.NewConnector(postgresDSN(params, false))
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(ctx)
+}
+
+func (c *credentialConnector) Driver() driver.Driver {
+	return &pq.Driver{}
+}
@@ -0,0 +1,145 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/redshift"
+	"github.com/aws/aws-sdk-go/service/redshift/redshiftiface"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandCredentialProvider(t *testing.T) {
+	ctx := context.Background()
+
+	t.Logf("Testing that credentials are parsed from the command's JSON output")
+	provider := CommandCredentialProvider{
+		`echo '{"user": "monitor", "password": "s3cret", "expiration": "2024-01-02T15:04:05Z"}'`,
+	}
+	credentials, err := provider.Credentials(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, Credentials{
+		Username:   "monitor",
+		Password:   "s3cret",
+		Expiration: time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC),
+	}, credentials)
+
+	t.Logf("Testing that the expiration is optional")
+	credentials, err = CommandCredentialProvider{`echo '{"user": "monitor", "password": "s3cret"}'`}.Credentials(ctx)
+	require.NoError(t, err)
+	assert.True(t, credentials.Expiration.IsZero())
+
+	t.Logf("Testing that failing commands report stderr")
+	_, err = CommandCredentialProvider{"echo 'not logged in' >&2; exit 1"}.Credentials(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not logged in")
+
+	t.Logf("Testing that invalid output is an error that doesn't echo it")
+	_, err = CommandCredentialProvider{"echo s3cret"}.Credentials(ctx)
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "s3cret")
+
+	t.Logf("Testing that a user and password are required")
+	_, err = CommandCredentialProvider{`echo '{"user": "monitor"}'`}.Credentials(ctx)
+	assert.Error(t, err)
+}
+
+// mockRedshiftAPI returns canned GetClusterCredentials output
+type mockRedshiftAPI struct {
+	redshiftiface.RedshiftAPI
+	input  *redshift.GetClusterCredentialsInput
+	output *redshift.GetClusterCredentialsOutput
+}
+
+func (m *mockRedshiftAPI) GetClusterCredentialsWithContext(ctx aws.Context, input *redshift.GetClusterCredentialsInput,
+	opts ...request.Option) (*redshift.GetClusterCredentialsOutput, error) {
+	m.input = input
+	return m.output, nil
+}
+
+func TestRedshiftCredentialProvider(t *testing.T) {
+	expiration := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	api := &mockRedshiftAPI{output: &redshift.GetClusterCredentialsOutput{
+		DbUser:     aws.String("IAM:monitor"),
+		DbPassword: aws.String("temporary"),
+		Expiration: aws.Time(expiration),
+	}}
+	provider := &RedshiftCredentialProvider{api, "analytics", "monitor", "warehouse"}
+
+	credentials, err := provider.Credentials(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, Credentials{"IAM:monitor", "temporary", expiration}, credentials)
+	assert.Equal(t, "analytics", aws.StringValue(api.input.ClusterIdentifier))
+	assert.Equal(t, "monitor", aws.StringValue(api.input.DbUser))
+	assert.Equal(t, "warehouse", aws.StringValue(api.input.DbName))
+}
+
+// countingProvider returns credentials expiring after ttl, counting calls
+type countingProvider struct {
+	now   func() time.Time
+	ttl   time.Duration
+	calls int
+	err   error
+}
+
+func (p *countingProvider) Credentials(ctx context.Context) (Credentials, error) {
+	p.calls++
+	if p.err != nil {
+		return Credentials{}, p.err
+	}
+	credentials := Credentials{Username: "monitor", Password: "s3cret"}
+	if p.ttl > 0 {
+		credentials.Expiration = p.now().Add(p.ttl)
+	}
+	return credentials, nil
+}
+
+func TestCachingCredentialProvider(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	t.Logf("Testing that credentials are reused until they are about to expire")
+	inner := &countingProvider{now: clock, ttl: 15 * time.Minute}
+	provider := newCachingCredentialProvider(inner)
+	provider.now = clock
+	for i := 0; i < 3; i++ {
+		_, err := provider.Credentials(ctx)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, inner.calls)
+
+	now = now.Add(15*time.Minute - credentialRefreshMargin)
+	_, err := provider.Credentials(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, inner.calls)
+
+	t.Logf("Testing that credentials without an expiration are fetched every time")
+	inner = &countingProvider{now: clock}
+	provider = newCachingCredentialProvider(inner)
+	provider.now = clock
+	provider.Credentials(ctx)
+	provider.Credentials(ctx)
+	assert.Equal(t, 2, inner.calls)
+}
+
+func TestCredentialConnector(t *testing.T) {
+	t.Logf("Testing that the provider is called before connecting, and its errors returned")
+	inner := &countingProvider{now: time.Now, err: errors.New("no credentials")}
+	params, err := postgresParams(PostgresCredentials{Host: "localhost", Port: "1", Provider: inner})
+	require.NoError(t, err)
+	assert.NotContains(t, params, "sslmode")
+
+	session := sql.OpenDB(newCredentialConnector(params, inner))
+	defer session.Close()
+	err = session.Ping()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no credentials")
+	assert.Equal(t, 1, inner.calls)
+}
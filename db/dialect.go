@@ -0,0 +1,152 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// CheckType identifies a kind of check run against a cluster
+type CheckType string
+
+const (
+	// CheckLatency measures latency from a table's timestamp column
+	CheckLatency CheckType = "latency"
+	// CheckLastWrite measures latency from when a table last received rows
+	CheckLastWrite CheckType = "last_write"
+	// CheckLoadErrors surfaces recent load errors
+	CheckLoadErrors CheckType = "load_errors"
+	// CheckColumnQuality profiles the recent values of a table's columns
+	CheckColumnQuality CheckType = "column_quality"
+)
+
+// ErrUnsupportedCheck is returned when a check is run against
+// a cluster whose dialect doesn't support it
+var ErrUnsupportedCheck = errors.New("check not supported by dialect")
+
+// Dialect generates SQL for a flavor of database and advertises which
+// check types can be run against it, so that unsupported checks can be
+// skipped explicitly rather than failing.
+type Dialect interface {
+	Name() string
+	Supports(check CheckType) bool
+
+	tableMetadataQuery(schemaName string) (string, []interface{})
+	tablesQuery(schemaName string) (string, []interface{})
+	columnsQuery(schemaName string) (string, []interface{})
+	schemasQuery() string
+	latencyQuery(timestampColumn, schemaName, tableName string) string
+	batchLatencyQuery(schemaName string, requests []LatencyRequest) (string, []interface{})
+	groupLatencyQuery(schemaName string, request LatencyRequest) (string, []interface{})
+	recentTimestampsQuery(timestampColumn, schemaName, tableName string, filter *Filter, since time.Time, limit int) (string, []interface{})
+	columnStatsQuery(schemaName, tableName, timestampColumn string, since time.Time, requests []ColumnStatsRequest) (string, []interface{})
+}
+
+// lastWriteDialect is implemented by dialects exposing when tables were last written
+type lastWriteDialect interface {
+	lastWriteQuery(schemaName string) (string, []interface{})
+}
+
+// loadErrorsDialect is implemented by dialects that record load errors
+type loadErrorsDialect interface {
+	loadErrorsQuery(ignoredFilePrefix string) (string, []interface{})
+}
+
+var (
+	// Redshift supports every check, using Redshift system tables
+	// for last write and load error checks
+	Redshift Dialect = redshiftDialect{}
+	// Postgres supports latency and column quality checks only
+	Postgres Dialect = postgresDialect{}
+)
+
+// DialectFor returns the dialect with the given name.
+// An empty name selects Redshift.
+func DialectFor(name string) (Dialect, error) {
+	switch name {
+	case "", "redshift":
+		return Redshift, nil
+	case "postgres":
+		return Postgres, nil
+	case "mysql":
+		return MySQL, nil
+	case "sqlite":
+		return SQLite, nil
+	}
+	return nil, fmt.Errorf("Unknown dialect %q", name)
+}
+
+// postgresDialect generates SQL for vanilla Postgres
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string {
+	return "postgres"
+}
+
+func (postgresDialect) Supports(check CheckType) bool {
+	return check == CheckLatency || check == CheckColumnQuality
+}
+
+func (postgresDialect) tableMetadataQuery(schemaName string) (string, []interface{}) {
+	return tableMetadataQuery(schemaName)
+}
+
+func (postgresDialect) tablesQuery(schemaName string) (string, []interface{}) {
+	return tablesQuery(schemaName)
+}
+
+func (postgresDialect) schemasQuery() string {
+	return schemasQuery()
+}
+
+func (postgresDialect) columnsQuery(schemaName string) (string, []interface{}) {
+	return columnsQuery(schemaName)
+}
+
+func (postgresDialect) latencyQuery(timestampColumn, schemaName, tableName string) string {
+	return latencyQuery(timestampColumn, schemaName, tableName)
+}
+
+func (postgresDialect) batchLatencyQuery(schemaName string, requests []LatencyRequest) (string, []interface{}) {
+	return batchLatencyQuery(schemaName, requests)
+}
+
+func (postgresDialect) groupLatencyQuery(schemaName string, request LatencyRequest) (string, []interface{}) {
+	return groupLatencyQuery(schemaName, request)
+}
+
+func (postgresDialect) recentTimestampsQuery(timestampColumn, schemaName, tableName string, filter *Filter, since time.Time,
+	limit int) (string, []interface{}) {
+	return recentTimestampsQuery(timestampColumn, schemaName, tableName, filter, since, limit)
+}
+
+func (postgresDialect) columnStatsQuery(schemaName, tableName, timestampColumn string, since time.Time,
+	requests []ColumnStatsRequest) (string, []interface{}) {
+	return columnStatsQuery(schemaName, tableName, timestampColumn, since, requests)
+}
+
+// redshiftDialect generates SQL for Redshift. Latency queries are shared
+// with Postgres, and it adds queries against Redshift system tables.
+type redshiftDialect struct {
+	postgresDialect
+}
+
+func (redshiftDialect) Name() string {
+	return "redshift"
+}
+
+func (redshiftDialect) Supports(check CheckType) bool {
+	switch check {
+	case CheckLatency, CheckLastWrite, CheckLoadErrors, CheckColumnQuality:
+		return true
+	}
+	return false
+}
+
+func (redshiftDialect) lastWriteQuery(schemaName string) (string, []interface{}) {
+	return lastWriteQuery(schemaName)
+}
+
+func (redshiftDialect) loadErrorsQuery(ignoredFilePrefix string) (string, []interface{}) {
+	return loadErrorsQuery(ignoredFilePrefix)
+}
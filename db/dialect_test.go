@@ -0,0 +1,70 @@
+package db
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDialectFor(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected Dialect
+		err      bool
+	}{
+		{"", Redshift, false},
+		{"redshift", Redshift, false},
+		{"postgres", Postgres, false},
+		{"mysql", MySQL, false},
+		{"sqlite", SQLite, false},
+		{"oracle", nil, true},
+	}
+
+	for _, test := range tests {
+		t.Logf("Testing DialectFor(%q)", test.name)
+		dialect, err := DialectFor(test.name)
+		assert.Equal(t, test.expected, dialect)
+		assert.Equal(t, test.err, err != nil)
+	}
+}
+
+func TestDialectSupports(t *testing.T) {
+	tests := []struct {
+		dialect   Dialect
+		check     CheckType
+		supported bool
+	}{
+		{Redshift, CheckLatency, true},
+		{Redshift, CheckLastWrite, true},
+		{Redshift, CheckLoadErrors, true},
+		{Redshift, CheckColumnQuality, true},
+		{Postgres, CheckLatency, true},
+		{Postgres, CheckColumnQuality, true},
+		{Postgres, CheckLastWrite, false},
+		{Postgres, CheckLoadErrors, false},
+		{MySQL, CheckLatency, true},
+		{MySQL, CheckLastWrite, false},
+		{MySQL, CheckColumnQuality, true},
+		{SQLite, CheckLatency, true},
+		{SQLite, CheckLoadErrors, false},
+		{SQLite, CheckColumnQuality, true},
+	}
+
+	for _, test := range tests {
+		t.Logf("Testing that %s supports %s: %v", test.dialect.Name(), test.check, test.supported)
+		assert.Equal(t, test.supported, test.dialect.Supports(test.check))
+	}
+}
+
+// TestUnsupportedQueries verifies that Redshift-only queries fail with
+// ErrUnsupportedCheck on Postgres before reaching the database
+func TestUnsupportedQueries(t *testing.T) {
+	client := &sqlClient{clusterName: "testCluster", dialect: Postgres}
+
+	_, err := client.QueryLastWrites("test")
+	assert.True(t, errors.Is(err, ErrUnsupportedCheck))
+
+	_, err = client.QuerySTLLoadErrors()
+	assert.True(t, errors.Is(err, ErrUnsupportedCheck))
+}
@@ -0,0 +1,198 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	"github.com/Clever/analytics-monitor/config"
+	l "github.com/Clever/analytics-monitor/logger"
+)
+
+// MySQL supports latency and column quality checks against MySQL, where a
+// schema is a database
+var MySQL Dialect = mysqlDialect{}
+
+// MySQLCredentials contains the mysql credentials/information.
+type MySQLCredentials struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	Database string
+}
+
+// mysqlDialect generates SQL for MySQL
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string {
+	return "mysql"
+}
+
+func (mysqlDialect) Supports(check CheckType) bool {
+	return check == CheckLatency || check == CheckColumnQuality
+}
+
+// quoteMySQLIdentifier quotes a MySQL identifier with backticks,
+// doubling any embedded backticks
+func quoteMySQLIdentifier(name string) string {
+	return "`" + strings.Replace(name, "`", "``", -1) + "`"
+}
+
+// quoteMySQLTable returns the quoted, schema-qualified name of a MySQL table
+func quoteMySQLTable(schemaName, tableName string) string {
+	return quoteMySQLIdentifier(schemaName) + "." + quoteMySQLIdentifier(tableName)
+}
+
+// tableMetadataQuery infers timestamp columns the same way as Postgres,
+// choosing the alphabetically lowest timestamp or datetime column
+func (mysqlDialect) tableMetadataQuery(schemaName string) (string, []interface{}) {
+	query := `
+		SELECT table_name, min(column_name)
+		FROM information_schema.columns
+		WHERE table_schema = ?
+		AND data_type IN ('timestamp', 'datetime')
+		GROUP BY table_name
+	`
+	return query, []interface{}{schemaName}
+}
+
+func (mysqlDialect) tablesQuery(schemaName string) (string, []interface{}) {
+	query := `
+		SELECT table_name
+		FROM information_schema.tables
+		WHERE table_schema = ?
+		AND table_type = 'BASE TABLE'
+	`
+	return query, []interface{}{schemaName}
+}
+
+func (mysqlDialect) columnsQuery(schemaName string) (string, []interface{}) {
+	query := `
+		SELECT table_name, column_name, data_type
+		FROM information_schema.columns
+		WHERE table_schema = ?
+		ORDER BY table_name, ordinal_position
+	`
+	return query, []interface{}{schemaName}
+}
+
+func (mysqlDialect) schemasQuery() string {
+	return `
+		SELECT schema_name
+		FROM information_schema.schemata
+		WHERE schema_name NOT IN ('information_schema', 'mysql', 'performance_schema', 'sys')
+	`
+}
+
+// latencyQuery relies on the session time zone being UTC
+// (see newMySQLClient) so datetimes convert to the right epoch
+func (mysqlDialect) latencyQuery(timestampColumn, schemaName, tableName string) string {
+	return fmt.Sprintf("SELECT UNIX_TIMESTAMP(MAX(%s)) FROM %s",
+		quoteMySQLIdentifier(timestampColumn), quoteMySQLTable(schemaName, tableName))
+}
+
+func (mysqlDialect) batchLatencyQuery(schemaName string, requests []LatencyRequest) (string, []interface{}) {
+	var args []interface{}
+	selects := make([]string, len(requests))
+	for i, request := range requests {
+		var epochs, where string
+		epochs, args = mysqlLatencyEpochs(request, args)
+		where, args = filterClause(request.Filter, quoteMySQLIdentifier, positionalPlaceholder, args)
+		selects[i] = fmt.Sprintf("SELECT %d AS idx, %s FROM %s%s",
+			i, epochs, quoteMySQLTable(schemaName, request.TableName), where)
+	}
+	return strings.Join(selects, "\nUNION ALL\n"), args
+}
+
+func (mysqlDialect) groupLatencyQuery(schemaName string, request LatencyRequest) (string, []interface{}) {
+	group := quoteMySQLIdentifier(request.GroupBy)
+	epochs, args := mysqlLatencyEpochs(request, nil)
+	where, args := filterClause(request.Filter, quoteMySQLIdentifier, positionalPlaceholder, args)
+	query := fmt.Sprintf("SELECT CAST(%s AS CHAR) AS group_value, %s FROM %s%s GROUP BY %s",
+		group, epochs, quoteMySQLTable(schemaName, request.TableName), where, group)
+	return query, args
+}
+
+// mysqlLatencyEpochs is latencyEpochs for MySQL
+func mysqlLatencyEpochs(request LatencyRequest, args []interface{}) (string, []interface{}) {
+	column := quoteMySQLIdentifier(request.TimestampColumn)
+	bounded := fmt.Sprintf("UNIX_TIMESTAMP(MAX(%s))", column)
+	if !request.BoundedBy.IsZero() {
+		args = append(args, request.BoundedBy.Unix())
+		bounded = fmt.Sprintf("UNIX_TIMESTAMP(MAX(CASE WHEN %s <= FROM_UNIXTIME(?) THEN %s END))", column, column)
+	}
+	return fmt.Sprintf("UNIX_TIMESTAMP(MAX(%s)) AS max_epoch, %s AS bounded_epoch", column, bounded), args
+}
+
+// recentTimestampsQuery also relies on the session time zone being UTC
+func (mysqlDialect) recentTimestampsQuery(timestampColumn, schemaName, tableName string, filter *Filter, since time.Time,
+	limit int) (string, []interface{}) {
+	column := quoteMySQLIdentifier(timestampColumn)
+	filtered, args := recentTimestampsFilter(filter, quoteMySQLIdentifier, positionalPlaceholder,
+		[]interface{}{since.Unix()})
+	query := fmt.Sprintf(`
+		SELECT DISTINCT FLOOR(UNIX_TIMESTAMP(%s) / 3600) * 3600 AS hour_epoch
+		FROM %s
+		WHERE %s >= FROM_UNIXTIME(?)%s
+		ORDER BY hour_epoch DESC
+		LIMIT ?
+	`, column, quoteMySQLTable(schemaName, tableName), column, filtered)
+	return query, append(args, limit)
+}
+
+func (mysqlDialect) columnStatsQuery(schemaName, tableName, timestampColumn string, since time.Time,
+	requests []ColumnStatsRequest) (string, []interface{}) {
+	selects, args := columnStatsSelects(requests, quoteMySQLIdentifier, "CHAR",
+		func(int) string { return "?" })
+	args = append(args, since.Unix())
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s >= FROM_UNIXTIME(?)",
+		selects, quoteMySQLTable(schemaName, tableName), quoteMySQLIdentifier(timestampColumn))
+	return query, args
+}
+
+// newMySQLClient creates a MySQL db client.
+func newMySQLClient(info MySQLCredentials, clusterName string, retry RetryPolicy, batchSize int, pool PoolSettings) (Client, error) {
+	mysqlConfig := mysql.NewConfig()
+	mysqlConfig.Net = "tcp"
+	mysqlConfig.Addr = fmt.Sprintf("%s:%s", info.Host, info.Port)
+	mysqlConfig.User = info.Username
+	mysqlConfig.Passwd = info.Password
+	mysqlConfig.DBName = info.Database
+	// Interpret DATETIME columns as UTC when converting them to epochs
+	mysqlConfig.Params = map[string]string{"time_zone": "'+00:00'"}
+
+	l.GetKVLogger().InfoD("New-mysql-client", l.M{
+		"addr":     mysqlConfig.Addr,
+		"database": info.Database,
+		"dialect":  MySQL.Name(),
+	})
+	session, err := sql.Open("mysql", mysqlConfig.FormatDSN())
+	if err != nil {
+		return nil, err
+	}
+	pool.apply(session)
+
+	return &sqlClient{session, clusterName, MySQL, retry, batchSize}, nil
+}
+
+// NewMySQLClient initializes a mysql client.
+// clusterName defaults to "mysql-<database>".
+func NewMySQLClient(clusterName string) (Client, error) {
+	info := MySQLCredentials{
+		Host:     config.MySQLHost,
+		Port:     config.MySQLPort,
+		Username: config.MySQLUsername,
+		Password: config.MySQLPassword,
+		Database: config.MySQLDatabase,
+	}
+
+	if clusterName == "" {
+		clusterName = "mysql-" + info.Database
+	}
+
+	return newMySQLClient(info, clusterName, configuredRetryPolicy(), config.LatencyBatchSize, configuredPoolSettings())
+}
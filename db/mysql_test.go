@@ -0,0 +1,69 @@
+package db
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Clever/analytics-monitor/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuoteMySQLIdentifier(t *testing.T) {
+	assert.Equal(t, "`districts`", quoteMySQLIdentifier("districts"))
+	assert.Equal(t, "`a``b`", quoteMySQLIdentifier("a`b"))
+	assert.Equal(t, "`mongo`.`districts`", quoteMySQLTable("mongo", "districts"))
+}
+
+func TestMySQLQueries(t *testing.T) {
+	for _, name := range append(hostileNames, "back`tick", "`; DROP TABLE users; --") {
+		t.Logf("Testing MySQL queries with identifier %q", name)
+
+		query, args := MySQL.tableMetadataQuery(name)
+		assert.NotContains(t, query, name, "schema name interpolated into query")
+		assert.Equal(t, []interface{}{name}, args)
+
+		query, args = MySQL.tablesQuery(name)
+		assert.NotContains(t, query, name, "schema name interpolated into query")
+		assert.Equal(t, []interface{}{name}, args)
+
+		query, args = MySQL.columnsQuery(name)
+		assert.NotContains(t, query, name, "schema name interpolated into query")
+		assert.Equal(t, []interface{}{name}, args)
+
+		quoted := quoteMySQLIdentifier(name)
+		query = MySQL.latencyQuery(name, "schema", "table")
+		assert.Equal(t, "SELECT UNIX_TIMESTAMP(MAX("+quoted+")) FROM `schema`.`table`", query)
+
+		since := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+		query, args = MySQL.batchLatencyQuery("schema", []LatencyRequest{
+			{TableName: name, TimestampColumn: "time"},
+			{TableName: "table", TimestampColumn: name, BoundedBy: since},
+		})
+		assert.Equal(t, 1, strings.Count(query, "UNION ALL"))
+		assert.Contains(t, query, "FROM `schema`."+quoted)
+		assert.Contains(t, query, "MAX("+quoted+")")
+		assert.Contains(t, query, "MAX(CASE WHEN "+quoted+" <= FROM_UNIXTIME(?) THEN "+quoted+" END)")
+		assert.Equal(t, []interface{}{since.Unix()}, args)
+
+		query, args = MySQL.groupLatencyQuery("schema", LatencyRequest{TableName: "table", TimestampColumn: "time",
+			GroupBy: name, Filter: &Filter{Column: name, Operator: config.FilterIn, Values: []string{name, "0"}}})
+		assert.Equal(t, "SELECT CAST("+quoted+" AS CHAR) AS group_value, UNIX_TIMESTAMP(MAX(`time`)) AS max_epoch, "+
+			"UNIX_TIMESTAMP(MAX(`time`)) AS bounded_epoch FROM `schema`.`table` WHERE "+quoted+" IN (?, ?) GROUP BY "+quoted, query)
+		assert.Equal(t, []interface{}{name, "0"}, args)
+
+		query, args = MySQL.columnStatsQuery("schema", "table", "time", since, []ColumnStatsRequest{
+			{Column: name, AllowedValues: []string{name}},
+		})
+		assert.Equal(t, "SELECT COUNT(*), COUNT(*) - COUNT("+quoted+"), COUNT(DISTINCT "+quoted+"), "+
+			"COUNT(CASE WHEN CAST("+quoted+" AS CHAR) NOT IN (?) THEN 1 END) "+
+			"FROM `schema`.`table` WHERE `time` >= FROM_UNIXTIME(?)", query)
+		assert.Equal(t, []interface{}{name, since.Unix()}, args)
+
+		query, args = MySQL.recentTimestampsQuery(name, "schema", "table",
+			&Filter{Column: name, Operator: config.FilterEquals, Values: []string{name}}, since, 336)
+		assert.Contains(t, query, "UNIX_TIMESTAMP("+quoted+")")
+		assert.Contains(t, query, "FROM_UNIXTIME(?) AND "+quoted+" = ?")
+		assert.Equal(t, []interface{}{since.Unix(), name, 336}, args)
+	}
+}
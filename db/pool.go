@@ -0,0 +1,44 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/Clever/analytics-monitor/config"
+)
+
+// PoolSettings size a client's connection pool and bound how long its
+// connections are kept open
+type PoolSettings struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// DefaultPoolSettings keeps a few connections open, recycling them every
+// 30 minutes so that rotated credentials are picked up
+var DefaultPoolSettings = PoolSettings{
+	MaxOpenConns:    4,
+	MaxIdleConns:    2,
+	ConnMaxLifetime: 30 * time.Minute,
+	ConnMaxIdleTime: 5 * time.Minute,
+}
+
+// apply configures the pool of session
+func (p PoolSettings) apply(session *sql.DB) {
+	session.SetMaxOpenConns(p.MaxOpenConns)
+	session.SetMaxIdleConns(p.MaxIdleConns)
+	session.SetConnMaxLifetime(p.ConnMaxLifetime)
+	session.SetConnMaxIdleTime(p.ConnMaxIdleTime)
+}
+
+// configuredPoolSettings returns the pool settings set by environment variables
+func configuredPoolSettings() PoolSettings {
+	return PoolSettings{
+		MaxOpenConns:    config.DBMaxOpenConns,
+		MaxIdleConns:    config.DBMaxIdleConns,
+		ConnMaxLifetime: config.DBConnMaxLifetime,
+		ConnMaxIdleTime: config.DBConnMaxIdleTime,
+	}
+}
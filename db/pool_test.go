@@ -0,0 +1,31 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoolSettingsApply(t *testing.T) {
+	session, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	defer session.Close()
+
+	DefaultPoolSettings.apply(session)
+	assert.Equal(t, DefaultPoolSettings.MaxOpenConns, session.Stats().MaxOpenConnections)
+}
+
+func TestPingAndClose(t *testing.T) {
+	db := setupSQLite(t)
+
+	t.Logf("Testing that reachable clusters ping successfully")
+	assert.NoError(t, db.Ping())
+
+	t.Logf("Testing that closed clients fail to ping, naming the cluster")
+	require.NoError(t, db.Close())
+	err := db.Ping()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "sqlite cluster testCluster")
+}
@@ -3,7 +3,9 @@ package db
 import (
 	"database/sql"
 	"fmt"
-	"time"
+	"net/url"
+	"sort"
+	"strings"
 
 	"github.com/Clever/analytics-monitor/config"
 	l "github.com/Clever/analytics-monitor/logger"
@@ -12,169 +14,192 @@ import (
 	_ "github.com/Clever/pq"
 )
 
-// PostgresClient exposes an interface for querying Postgres.
-type PostgresClient interface {
-	GetClusterName() string
-	QueryTableMetadata(schemaName string) (map[string]TableMetadata, error)
-	QueryLatency(timestampColumn, schemaName, tableName string) (int64, bool, error)
-	QuerySTLLoadErrors() ([]LoadError, error)
-}
-
-// postgresClient provides a default implementation of PostgresClient
-// that contains the postgres client connection.
-type postgresClient struct {
-	session     *sql.DB
-	clusterName string
-}
-
 // PostgresCredentials contains the postgres credentials/information.
+// URL is an optional postgres:// connection URL; the other non-empty
+// fields override its parts. If Provider is set, it supplies the username
+// and password of each new connection instead.
 type PostgresCredentials struct {
+	URL      string
 	Host     string
 	Port     string
 	Username string
 	Password string
 	Database string
+
+	SSLMode     string
+	SSLRootCert string
+	SSLCert     string
+	SSLKey      string
+
+	Provider CredentialProvider
 }
 
-// TableMetadata contains information about a table in Postgres
-type TableMetadata struct {
-	TableName       string
-	TimestampColumn string
+// postgresConnectTimeout is the connect_timeout in seconds, unless the URL sets one
+const postgresConnectTimeout = "60"
+
+// postgresParams resolves credentials into libpq connection parameters
+func postgresParams(info PostgresCredentials) (map[string]string, error) {
+	params := map[string]string{}
+	if info.URL != "" {
+		u, err := url.Parse(info.URL)
+		if err != nil {
+			// Don't include the error, which can contain the password
+			return nil, fmt.Errorf("invalid postgres URL")
+		}
+		if u.Scheme != "postgres" && u.Scheme != "postgresql" {
+			return nil, fmt.Errorf("invalid postgres URL scheme %q", u.Scheme)
+		}
+		if u.User != nil {
+			params["user"] = u.User.Username()
+			if password, ok := u.User.Password(); ok {
+				params["password"] = password
+			}
+		}
+		params["host"] = u.Hostname()
+		params["port"] = u.Port()
+		params["dbname"] = strings.TrimPrefix(u.Path, "/")
+		for key, values := range u.Query() {
+			params[key] = values[len(values)-1]
+		}
+	}
+
+	overrides := map[string]string{
+		"host":        info.Host,
+		"port":        info.Port,
+		"dbname":      info.Database,
+		"user":        info.Username,
+		"password":    info.Password,
+		"sslmode":     info.SSLMode,
+		"sslrootcert": info.SSLRootCert,
+		"sslcert":     info.SSLCert,
+		"sslkey":      info.SSLKey,
+	}
+	for key, value := range overrides {
+		if value != "" {
+			params[key] = value
+		}
+	}
+	for key, value := range params {
+		if value == "" {
+			delete(params, key)
+		}
+	}
+
+	params["keepalive"] = "1"
+	if params["connect_timeout"] == "" {
+		params["connect_timeout"] = postgresConnectTimeout
+	}
+	if params["user"] == "" && info.Provider == nil && params["sslmode"] == "" {
+		// Locally we have to disable ssl mode
+		params["sslmode"] = "disable"
+	}
+	return params, nil
 }
 
-// LoadError contains information surfacing load errors
-type LoadError struct {
-	TableNames string `json:"table_names"`
-	ErrorCode  int64  `json:"error_code"`
-	Count      int64  `json:"count"`
+// postgresDSN formats connection parameters as a keyword/value DSN,
+// quoting every value so passwords may contain spaces and quotes.
+// The password is omitted if redact is set, for logging.
+func postgresDSN(params map[string]string, redact bool) string {
+	quoter := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	var pairs []string
+	for key, value := range params {
+		if key == "password" && redact {
+			continue
+		}
+		pairs = append(pairs, fmt.Sprintf("%s='%s'", key, quoter.Replace(value)))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, " ")
 }
 
-// NewPostgresClient creates a Postgres db client.
-func newPostgresClient(info PostgresCredentials, clusterName string) (PostgresClient, error) {
-	const connectionTimeout = 60
-	connectionParams := fmt.Sprintf("host=%s port=%s dbname=%s keepalive=1 connect_timeout=%d",
-		info.Host, info.Port, info.Database, connectionTimeout)
-	credentialsParams := ""
-	if len(info.Username) > 0 {
-		credentialsParams = fmt.Sprintf("user=%s password=%s", info.Username, info.Password)
-	} else {
-		// Locally we have to disable ssl mode
-		credentialsParams = "sslmode=disable"
+// newPostgresClient creates a Postgres db client.
+func newPostgresClient(info PostgresCredentials, clusterName string, dialect Dialect, retry RetryPolicy, batchSize int, pool PoolSettings) (Client, error) {
+	params, err := postgresParams(info)
+	if err != nil {
+		return nil, err
 	}
 
 	l.GetKVLogger().InfoD("New-postgres-client", l.M{
-		"connectionParams": connectionParams,
+		"connectionParams": postgresDSN(params, true),
+		"dialect":          dialect.Name(),
 	})
-	openParams := fmt.Sprintf("%s %s", connectionParams, credentialsParams)
-	session, err := sql.Open("postgres", openParams)
-	if err != nil {
-		return nil, err
+	var session *sql.DB
+	if info.Provider != nil {
+		session = sql.OpenDB(newCredentialConnector(params, info.Provider))
+	} else {
+		session, err = sql.Open("postgres", postgresDSN(params, false))
+		if err != nil {
+			return nil, err
+		}
 	}
+	pool.apply(session)
 
-	return &postgresClient{session, clusterName}, nil
+	return &sqlClient{session, clusterName, dialect, retry, batchSize}, nil
 }
 
 // NewPostgresClient initializes a postgres client
-func NewPostgresClient() (PostgresClient, error) {
+// that generates SQL for the given dialect.
+// clusterName defaults to "redshift-prod" for Redshift,
+// and to "postgres-<database>" otherwise.
+func NewPostgresClient(dialect Dialect, clusterName string) (Client, error) {
 	info := PostgresCredentials{
+		URL:      config.PostgresURL,
 		Host:     config.PostgresHost,
 		Port:     config.PostgresPort,
 		Username: config.PostgresUsername,
 		Password: config.PostgresPassword,
 		Database: config.PostgresDatabase,
-	}
-
-	return newPostgresClient(info, "redshift-prod")
-}
 
-// GetClusterName returns the name of the client Postgres cluster
-func (c *postgresClient) GetClusterName() string {
-	return c.clusterName
-}
+		SSLMode:     config.PostgresSSLMode,
+		SSLRootCert: config.PostgresSSLRootCert,
+		SSLCert:     config.PostgresSSLCert,
+		SSLKey:      config.PostgresSSLKey,
+	}
 
-// QueryTableMetadata returns a map of tables
-// belonging to a given schema in Postgres, indexed
-// by table name.
-// It also attempts to infer the timestamp column, by
-// choosing the alphabetically lowest column with a
-// timestamp type. We use this as a heuristic since a
-// lot of our timestamp columns are prefixed with "_".
-func (c *postgresClient) QueryTableMetadata(schemaName string) (map[string]TableMetadata, error) {
-	query := fmt.Sprintf(`
-		SELECT table_name, min("column_name")
-		FROM information_schema.columns
-		WHERE table_schema = '%s'
-		AND data_type ILIKE '%%timestamp%%'
-		GROUP BY table_name
-	`, schemaName)
-
-	tableMetadata := make(map[string]TableMetadata)
-	rows, err := c.session.Query(query)
+	provider, err := configuredCredentialProvider(info)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	info.Provider = provider
 
-	for rows.Next() {
-		var row TableMetadata
-		if err := rows.Scan(&row.TableName, &row.TimestampColumn); err != nil {
-			return tableMetadata, fmt.Errorf("Unable to scan row for schema %s: %s", schemaName, err)
-		}
-
-		tableMetadata[row.TableName] = row
+	if clusterName == "" {
+		clusterName = defaultPostgresClusterName(dialect, info)
 	}
 
-	return tableMetadata, nil
+	return newPostgresClient(info, clusterName, dialect, configuredRetryPolicy(), config.LatencyBatchSize,
+		configuredPoolSettings())
 }
 
-// QueryLatency returns the latency for a given table,
-// defined as the time difference in hours between now
-// and the most recent record in a table. Returns the latency,
-// if applicable, and whether or not the table contains rows
-func (c *postgresClient) QueryLatency(timestampColumn, schemaName, tableName string) (int64, bool, error) {
-	// We extract the epoch because it works in both Redshift and Postgres
-	latencyQuery := fmt.Sprintf("SELECT extract(epoch from MAX(\"%s\")) FROM \"%s\".\"%s\"", timestampColumn, schemaName, tableName)
-	rows, err := c.session.Query(latencyQuery)
-	if err != nil {
-		return 0, false, fmt.Errorf("Error executing query %s: %s", latencyQuery, err)
+// defaultPostgresClusterName names a cluster that wasn't named after its
+// dialect, so plain Postgres isn't reported as Redshift
+func defaultPostgresClusterName(dialect Dialect, info PostgresCredentials) string {
+	if dialect == Redshift {
+		return "redshift-prod"
 	}
-
-	var latency sql.NullFloat64
-	rows.Next()
-	if err := rows.Scan(&latency); err != nil {
-		return 0, false, fmt.Errorf("Unable to scan row for query %s: %s", latencyQuery, err)
+	params, err := postgresParams(info)
+	if err != nil || params["dbname"] == "" {
+		return "postgres"
 	}
-	hourDiff := (time.Now().Unix() - int64(latency.Float64)) / 3600
-	return hourDiff, latency.Valid, nil
+	return "postgres-" + params["dbname"]
 }
 
-func (c *postgresClient) QuerySTLLoadErrors() ([]LoadError, error) {
-	query := fmt.Sprintf(`
-		SELECT sum("count") AS count, err_code, listagg(name, ', ')
-    FROM (SELECT COUNT(stl.err_code) AS count, stl.err_code, stv.name
-    FROM stl_load_errors AS stl
-    INNER JOIN stv_tbl_perm AS stv ON stl.tbl = stv.id
-    WHERE starttime > (getdate() - INTERVAL '3 hour')
-        AND filename not like 's3://firehose-prod/github-events%%'
-    GROUP BY name, err_code)
-    GROUP BY err_code
-  `)
-
-	var loadErrors []LoadError
-	rows, err := c.session.Query(query)
+// configuredCredentialProvider returns the credential provider set by
+// environment variables, or nil to use the configured password
+func configuredCredentialProvider(info PostgresCredentials) (CredentialProvider, error) {
+	if config.PostgresCredentialsCommand != "" {
+		return CommandCredentialProvider{config.PostgresCredentialsCommand}, nil
+	}
+	if config.RedshiftClusterID == "" {
+		return nil, nil
+	}
+
+	// The user and database may also come from the URL
+	params, err := postgresParams(info)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var row LoadError
-		if err := rows.Scan(&row.Count, &row.ErrorCode, &row.TableNames); err != nil {
-			return loadErrors, fmt.Errorf("Unable to scan row: %s", err)
-		}
-
-		loadErrors = append(loadErrors, row)
+	if params["user"] == "" {
+		return nil, fmt.Errorf("REDSHIFT_CLUSTER_ID requires a database user")
 	}
-
-	return loadErrors, nil
+	return NewRedshiftCredentialProvider(config.RedshiftRegion, config.RedshiftClusterID, params["user"], params["dbname"])
 }
@@ -0,0 +1,108 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ColumnStatsRequest identifies a column to profile and, if any, the
+// values it is allowed to take
+type ColumnStatsRequest struct {
+	Column        string
+	AllowedValues []string
+}
+
+// ColumnStats profiles a column over a table's recent rows: of Rows rows,
+// Nulls were null, the rest took Distinct values, and Disallowed took a
+// value outside the request's allowed values. Disallowed is zero if no
+// values were given.
+type ColumnStats struct {
+	Column     string
+	Rows       int64
+	Nulls      int64
+	Distinct   int64
+	Disallowed int64
+}
+
+// NullFraction returns the fraction of rows in which the column was null,
+// or zero if there were no rows
+func (s ColumnStats) NullFraction() float64 {
+	if s.Rows == 0 {
+		return 0
+	}
+	return float64(s.Nulls) / float64(s.Rows)
+}
+
+// QueryColumnStats profiles columns of a table over its rows with a
+// timestamp since the given time, with a single query. Stats are
+// returned in the order of requests.
+func (c *sqlClient) QueryColumnStats(schemaName, tableName, timestampColumn string, since time.Time,
+	requests []ColumnStatsRequest) ([]ColumnStats, error) {
+	query, args := c.dialect.columnStatsQuery(schemaName, tableName, timestampColumn, since, requests)
+
+	var stats []ColumnStats
+	err := c.retry.Do(fmt.Sprintf("column stats %s.%s", schemaName, tableName), func() error {
+		counts := make([]int64, 1+3*len(requests))
+		dest := make([]interface{}, len(counts))
+		for i := range counts {
+			dest[i] = &counts[i]
+		}
+		if err := c.session.QueryRow(query, args...).Scan(dest...); err != nil {
+			return fmt.Errorf("Error executing column stats query for %s.%s: %w", schemaName, tableName, err)
+		}
+
+		stats = make([]ColumnStats, len(requests))
+		for i, request := range requests {
+			stats[i] = ColumnStats{
+				Column:     request.Column,
+				Rows:       counts[0],
+				Nulls:      counts[1+3*i],
+				Distinct:   counts[2+3*i],
+				Disallowed: counts[3+3*i],
+			}
+		}
+		return nil
+	})
+	return stats, err
+}
+
+// columnStatsSelects selects, for each request, its column's null, distinct
+// and disallowed counts, after the count of all rows. Allowed values are
+// compared as text, castType being the dialect's text type, and bound
+// with placeholder, which is given each value's position in args.
+func columnStatsSelects(requests []ColumnStatsRequest, quote func(string) string, castType string,
+	placeholder func(int) string) (string, []interface{}) {
+	var args []interface{}
+	selects := []string{"COUNT(*)"}
+	for _, request := range requests {
+		column := quote(request.Column)
+		disallowed := "0"
+		if len(request.AllowedValues) > 0 {
+			placeholders := make([]string, len(request.AllowedValues))
+			for i, value := range request.AllowedValues {
+				args = append(args, value)
+				placeholders[i] = placeholder(len(args))
+			}
+			disallowed = fmt.Sprintf("COUNT(CASE WHEN CAST(%s AS %s) NOT IN (%s) THEN 1 END)",
+				column, castType, strings.Join(placeholders, ", "))
+		}
+		selects = append(selects,
+			fmt.Sprintf("COUNT(*) - COUNT(%s)", column),
+			fmt.Sprintf("COUNT(DISTINCT %s)", column),
+			disallowed)
+	}
+	return strings.Join(selects, ", "), args
+}
+
+// columnStatsQuery profiles columns of a table over its rows with
+// a timestamp since a time
+func columnStatsQuery(schemaName, tableName, timestampColumn string, since time.Time,
+	requests []ColumnStatsRequest) (string, []interface{}) {
+	selects, args := columnStatsSelects(requests, quoteIdentifier, "VARCHAR",
+		func(n int) string { return fmt.Sprintf("$%d", n) })
+	args = append(args, since.Unix())
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s >= TIMESTAMP 'epoch' + $%d * INTERVAL '1 second'",
+		selects, quoteTable(schemaName, tableName), quoteIdentifier(timestampColumn), len(args))
+	return query, args
+}
@@ -0,0 +1,256 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Clever/analytics-monitor/config"
+	"github.com/Clever/pq"
+)
+
+// The query builders below never interpolate values into SQL. Values are
+// passed as bind parameters and schema, table and column names are
+// quoted as identifiers, so names read from config can't alter a query.
+
+// quoteIdentifier quotes a schema, table or column name, doubling
+// any embedded double quotes
+func quoteIdentifier(name string) string {
+	return pq.QuoteIdentifier(name)
+}
+
+// quoteTable returns the quoted, schema-qualified name of a table
+func quoteTable(schemaName, tableName string) string {
+	return quoteIdentifier(schemaName) + "." + quoteIdentifier(tableName)
+}
+
+// numberedPlaceholder is the Postgres placeholder of the nth argument
+func numberedPlaceholder(n int) string {
+	return fmt.Sprintf("$%d", n)
+}
+
+// positionalPlaceholder is the MySQL and SQLite placeholder of any
+// argument, which are bound in the order they appear in the query
+func positionalPlaceholder(int) string {
+	return "?"
+}
+
+// filterClause returns the WHERE clause of a filter, or nothing if it's
+// nil. See filterPredicate for quote, placeholder and args.
+func filterClause(filter *Filter, quote func(string) string, placeholder func(int) string,
+	args []interface{}) (string, []interface{}) {
+	if filter == nil {
+		return "", args
+	}
+	predicate, args := filterPredicate(*filter, quote, placeholder, args)
+	return " WHERE " + predicate, args
+}
+
+// filterPredicate returns the condition of a filter on its column, quoted
+// with quote. Its values are appended to args and bound with placeholder,
+// which is given each value's position in args. An unknown operator
+// matches no rows.
+func filterPredicate(filter Filter, quote func(string) string, placeholder func(int) string,
+	args []interface{}) (string, []interface{}) {
+	column := quote(filter.Column)
+	placeholders := make([]string, len(filter.Values))
+	for i, value := range filter.Values {
+		args = append(args, value)
+		placeholders[i] = placeholder(len(args))
+	}
+	values := strings.Join(placeholders, ", ")
+
+	switch filter.Operator {
+	case config.FilterEquals, config.FilterNotEquals:
+		if len(placeholders) == 1 {
+			return fmt.Sprintf("%s %s %s", column, filter.Operator, values), args
+		}
+	case config.FilterIn:
+		if len(placeholders) > 0 {
+			return fmt.Sprintf("%s IN (%s)", column, values), args
+		}
+	case config.FilterNotIn:
+		if len(placeholders) > 0 {
+			return fmt.Sprintf("%s NOT IN (%s)", column, values), args
+		}
+	case config.FilterIsNull:
+		return column + " IS NULL", args
+	case config.FilterIsNotNull:
+		return column + " IS NOT NULL", args
+	}
+	return "1 = 0", args
+}
+
+// tableMetadataQuery lists tables in a schema along with the
+// alphabetically lowest column with a timestamp type
+func tableMetadataQuery(schemaName string) (string, []interface{}) {
+	query := `
+		SELECT table_name, min("column_name")
+		FROM information_schema.columns
+		WHERE table_schema = $1
+		AND data_type ILIKE '%timestamp%'
+		GROUP BY table_name
+	`
+	return query, []interface{}{schemaName}
+}
+
+// tablesQuery lists every table in a schema, whatever its columns
+func tablesQuery(schemaName string) (string, []interface{}) {
+	query := `
+		SELECT table_name
+		FROM information_schema.tables
+		WHERE table_schema = $1
+		AND table_type = 'BASE TABLE'
+	`
+	return query, []interface{}{schemaName}
+}
+
+// schemasQuery lists every schema except the system ones
+func schemasQuery() string {
+	return `
+		SELECT schema_name
+		FROM information_schema.schemata
+		WHERE schema_name <> 'information_schema'
+		AND left(schema_name, 3) <> 'pg_'
+	`
+}
+
+// columnsQuery lists the columns of every table in a schema, with
+// their types, in the order they're declared
+func columnsQuery(schemaName string) (string, []interface{}) {
+	query := `
+		SELECT table_name, "column_name", data_type
+		FROM information_schema.columns
+		WHERE table_schema = $1
+		ORDER BY table_name, ordinal_position
+	`
+	return query, []interface{}{schemaName}
+}
+
+// latencyQuery selects the most recent timestamp in a table as epoch seconds.
+// We extract the epoch because it works in both Redshift and Postgres
+func latencyQuery(timestampColumn, schemaName, tableName string) string {
+	return fmt.Sprintf("SELECT extract(epoch from MAX(%s)) FROM %s",
+		quoteIdentifier(timestampColumn), quoteTable(schemaName, tableName))
+}
+
+// recentTimestampsQuery selects the distinct hours, as epoch seconds, in
+// which a table has timestamps since a time, most recent first. Only rows
+// matching filter are counted, if it isn't nil.
+func recentTimestampsQuery(timestampColumn, schemaName, tableName string, filter *Filter, since time.Time,
+	limit int) (string, []interface{}) {
+	column := quoteIdentifier(timestampColumn)
+	args := []interface{}{since.Unix()}
+	filtered, args := recentTimestampsFilter(filter, quoteIdentifier, numberedPlaceholder, args)
+	args = append(args, limit)
+	query := fmt.Sprintf(`
+		SELECT DISTINCT floor(extract(epoch from %s) / 3600) * 3600 AS hour_epoch
+		FROM %s
+		WHERE %s >= TIMESTAMP 'epoch' + $1 * INTERVAL '1 second'%s
+		ORDER BY hour_epoch DESC
+		LIMIT $%d
+	`, column, quoteTable(schemaName, tableName), column, filtered, len(args))
+	return query, args
+}
+
+// recentTimestampsFilter returns the condition added to the WHERE clause of
+// a recent timestamps query by its filter, or nothing if it's nil. See
+// filterPredicate for quote, placeholder and args.
+func recentTimestampsFilter(filter *Filter, quote func(string) string, placeholder func(int) string,
+	args []interface{}) (string, []interface{}) {
+	if filter == nil {
+		return "", args
+	}
+	predicate, args := filterPredicate(*filter, quote, placeholder, args)
+	return " AND " + predicate, args
+}
+
+// loadErrorsQuery summarizes Redshift load errors from the last three
+// hours by error code, ignoring files under ignoredFilePrefix
+func loadErrorsQuery(ignoredFilePrefix string) (string, []interface{}) {
+	query := `
+		SELECT sum("count") AS count, err_code, listagg(name, ', ')
+    FROM (SELECT COUNT(stl.err_code) AS count, stl.err_code, stv.name
+    FROM stl_load_errors AS stl
+    INNER JOIN stv_tbl_perm AS stv ON stl.tbl = stv.id
+    WHERE starttime > (getdate() - INTERVAL '3 hour')
+        AND filename not like $1
+    GROUP BY name, err_code)
+    GROUP BY err_code
+  `
+	return query, []interface{}{ignoredFilePrefix + "%"}
+}
+
+// batchLatencyQuery selects the most recent timestamp of several tables in
+// one schema as epoch seconds, one row per table, along with the most recent
+// timestamp up to the request's bound, if any. Rows are keyed by the table's
+// index in requests rather than its name so no value has to be embedded in
+// the query.
+func batchLatencyQuery(schemaName string, requests []LatencyRequest) (string, []interface{}) {
+	var args []interface{}
+	selects := make([]string, len(requests))
+	for i, request := range requests {
+		var epochs, where string
+		epochs, args = latencyEpochs(request, args)
+		where, args = filterClause(request.Filter, quoteIdentifier, numberedPlaceholder, args)
+		selects[i] = fmt.Sprintf("SELECT %d AS idx, %s FROM %s%s",
+			i, epochs, quoteTable(schemaName, request.TableName), where)
+	}
+	return strings.Join(selects, "\nUNION ALL\n"), args
+}
+
+// groupLatencyQuery selects the most recent timestamp, and the most recent
+// up to the request's bound, of each value of its group_by column
+func groupLatencyQuery(schemaName string, request LatencyRequest) (string, []interface{}) {
+	group := quoteIdentifier(request.GroupBy)
+	epochs, args := latencyEpochs(request, nil)
+	where, args := filterClause(request.Filter, quoteIdentifier, numberedPlaceholder, args)
+	query := fmt.Sprintf("SELECT CAST(%s AS VARCHAR) AS group_value, %s FROM %s%s GROUP BY %s",
+		group, epochs, quoteTable(schemaName, request.TableName), where, group)
+	return query, args
+}
+
+// latencyEpochs selects a request's most recent timestamp as max_epoch and
+// the most recent up to its bound as bounded_epoch, both as epoch seconds.
+// Unbounded requests select the most recent timestamp twice. The bound
+// is appended to args, and numbered accordingly.
+func latencyEpochs(request LatencyRequest, args []interface{}) (string, []interface{}) {
+	column := quoteIdentifier(request.TimestampColumn)
+	bounded := fmt.Sprintf("extract(epoch from MAX(%s))", column)
+	if !request.BoundedBy.IsZero() {
+		args = append(args, request.BoundedBy.Unix())
+		bounded = fmt.Sprintf(
+			"extract(epoch from MAX(CASE WHEN %s <= TIMESTAMP 'epoch' + $%d * INTERVAL '1 second' THEN %s END))",
+			column, len(args), column)
+	}
+	return fmt.Sprintf("extract(epoch from MAX(%s)) AS max_epoch, %s AS bounded_epoch", column, bounded), args
+}
+
+// LastWriteRetention is how long Redshift is sure to keep the system table
+// history last writes are read from. It keeps more when it has room.
+const LastWriteRetention = 48 * time.Hour
+
+// lastWriteQuery selects when each table in a schema last received rows,
+// from inserts or COPY loads, as epoch seconds. Loads are committed in
+// stl_load_commits by query, and the query's scans name the table loaded.
+// Tables without a write in the system tables' retention are missing from
+// the results.
+func lastWriteQuery(schemaName string) (string, []interface{}) {
+	query := `
+		SELECT ti."table", extract(epoch from MAX(writes.endtime))
+		FROM (
+			SELECT ins.tbl, ins.endtime
+			FROM stl_insert AS ins
+			WHERE ins."rows" > 0
+			UNION ALL
+			SELECT scan.tbl, commits.curtime AS endtime
+			FROM stl_load_commits AS commits
+			INNER JOIN stl_scan AS scan ON commits.query = scan.query
+			WHERE commits.lines_scanned > 0
+		) AS writes
+		INNER JOIN svv_table_info AS ti ON writes.tbl = ti.table_id
+		WHERE ti."schema" = $1
+		GROUP BY ti."table"
+	`
+	return query, []interface{}{schemaName}
+}
@@ -0,0 +1,250 @@
+package db
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Clever/analytics-monitor/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// hostileNames are schema/table/column names that would break
+// out of naively quoted SQL
+var hostileNames = []string{
+	`plain`,
+	`with space`,
+	`quote"inside`,
+	`"; DROP TABLE users; --`,
+	`'; DROP TABLE users; --`,
+	`trailing"`,
+	`""`,
+	`back\slash`,
+}
+
+// splitIdentifiers parses the double-quoted identifiers out of a query,
+// un-doubling embedded quotes. It returns the identifiers and the query
+// skeleton with each identifier replaced by "?", so tests can verify a
+// hostile name stayed inside its identifier.
+func splitIdentifiers(t *testing.T, query string) ([]string, string) {
+	var identifiers []string
+	var skeleton, current strings.Builder
+	inIdentifier := false
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case !inIdentifier && c == '"':
+			inIdentifier = true
+			current.Reset()
+		case inIdentifier && c == '"' && i+1 < len(query) && query[i+1] == '"':
+			current.WriteByte('"')
+			i++
+		case inIdentifier && c == '"':
+			inIdentifier = false
+			identifiers = append(identifiers, current.String())
+			skeleton.WriteByte('?')
+		case inIdentifier:
+			current.WriteByte(c)
+		default:
+			skeleton.WriteByte(c)
+		}
+	}
+	assert.False(t, inIdentifier, "unterminated identifier in %s", query)
+	return identifiers, skeleton.String()
+}
+
+func TestQuoteIdentifier(t *testing.T) {
+	assert.Equal(t, `"districts"`, quoteIdentifier("districts"))
+	assert.Equal(t, `"a""b"`, quoteIdentifier(`a"b`))
+	assert.Equal(t, `"mongo"."districts"`, quoteTable("mongo", "districts"))
+	assert.Equal(t, `"a""; DROP TABLE x; --"."t"`, quoteTable(`a"; DROP TABLE x; --`, "t"))
+}
+
+func TestTableMetadataQuery(t *testing.T) {
+	for _, name := range hostileNames {
+		t.Logf("Testing tableMetadataQuery with schema %q", name)
+		query, args := tableMetadataQuery(name)
+		assert.NotContains(t, query, name, "schema name interpolated into query")
+		assert.Contains(t, query, "$1")
+		assert.Equal(t, []interface{}{name}, args)
+	}
+}
+
+func TestTablesQuery(t *testing.T) {
+	for _, name := range hostileNames {
+		t.Logf("Testing tablesQuery with schema %q", name)
+		query, args := tablesQuery(name)
+		assert.NotContains(t, query, name, "schema name interpolated into query")
+		assert.Contains(t, query, "$1")
+		assert.Equal(t, []interface{}{name}, args)
+	}
+}
+
+func TestColumnsQuery(t *testing.T) {
+	for _, name := range hostileNames {
+		t.Logf("Testing columnsQuery with schema %q", name)
+		query, args := columnsQuery(name)
+		assert.NotContains(t, query, name, "schema name interpolated into query")
+		assert.Contains(t, query, "$1")
+		assert.Equal(t, []interface{}{name}, args)
+	}
+}
+
+func TestLatencyQuery(t *testing.T) {
+	for _, name := range hostileNames {
+		t.Logf("Testing latencyQuery with identifier %q", name)
+		query := latencyQuery(name, name+"_schema", name+"_table")
+		identifiers, skeleton := splitIdentifiers(t, query)
+		assert.Equal(t, []string{name, name + "_schema", name + "_table"}, identifiers)
+		assert.Equal(t, "SELECT extract(epoch from MAX(?)) FROM ?.?", skeleton)
+	}
+}
+
+func TestRecentTimestampsQuery(t *testing.T) {
+	since := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+	for _, name := range hostileNames {
+		t.Logf("Testing recentTimestampsQuery with identifier %q", name)
+		query, args := recentTimestampsQuery(name, name+"_schema", name+"_table", nil, since, 336)
+		identifiers, _ := splitIdentifiers(t, query)
+		assert.Equal(t, []string{name, name + "_schema", name + "_table", name}, identifiers)
+		assert.Contains(t, query, "LIMIT $2")
+		assert.Equal(t, []interface{}{since.Unix(), 336}, args)
+	}
+
+	t.Log("Testing that filtered queries bind the filter's values between the time and the limit")
+	query, args := recentTimestampsQuery("time", "schema", "table",
+		&Filter{Column: "source", Operator: config.FilterNotIn, Values: []string{"test"}}, since, 336)
+	assert.Contains(t, query, `* INTERVAL '1 second' AND "source" NOT IN ($2)`)
+	assert.Contains(t, query, "LIMIT $3")
+	assert.Equal(t, []interface{}{since.Unix(), "test", 336}, args)
+}
+
+func TestColumnStatsQuery(t *testing.T) {
+	since := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+	for _, name := range hostileNames {
+		t.Logf("Testing columnStatsQuery with identifier %q", name)
+		query, args := columnStatsQuery("schema", "table", "time", since, []ColumnStatsRequest{
+			{Column: name},
+			{Column: "state", AllowedValues: []string{name, "active"}},
+		})
+		identifiers, skeleton := splitIdentifiers(t, query)
+		assert.Equal(t, []string{name, name, "state", "state", "state", "schema", "table", "time"}, identifiers)
+		assert.Equal(t, "SELECT COUNT(*), COUNT(*) - COUNT(?), COUNT(DISTINCT ?), 0, "+
+			"COUNT(*) - COUNT(?), COUNT(DISTINCT ?), COUNT(CASE WHEN CAST(? AS VARCHAR) NOT IN ($1, $2) THEN 1 END) "+
+			"FROM ?.? WHERE ? >= TIMESTAMP 'epoch' + $3 * INTERVAL '1 second'", skeleton)
+		assert.Equal(t, []interface{}{name, "active", since.Unix()}, args)
+	}
+}
+
+func TestLoadErrorsQuery(t *testing.T) {
+	prefix := `s3://bucket/'; DROP TABLE users; --`
+	query, args := loadErrorsQuery(prefix)
+	assert.NotContains(t, query, prefix, "file prefix interpolated into query")
+	assert.Equal(t, []interface{}{prefix + "%"}, args)
+}
+
+func TestBatchLatencyQuery(t *testing.T) {
+	var requests []LatencyRequest
+	var expected []string
+	for _, name := range hostileNames {
+		requests = append(requests, LatencyRequest{TableName: name + "_table", TimestampColumn: name})
+		expected = append(expected, name, name, "schema", name+"_table")
+	}
+
+	query, args := batchLatencyQuery("schema", requests)
+	identifiers, skeleton := splitIdentifiers(t, query)
+	assert.Equal(t, expected, identifiers)
+	assert.Equal(t, len(hostileNames)-1, strings.Count(skeleton, "UNION ALL"))
+	assert.True(t, strings.HasPrefix(skeleton,
+		"SELECT 0 AS idx, extract(epoch from MAX(?)) AS max_epoch, extract(epoch from MAX(?)) AS bounded_epoch FROM ?.?\nUNION ALL\n"))
+	assert.Empty(t, args)
+
+	t.Logf("Testing that bounds are bind parameters, numbered in order")
+	bound := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+	query, args = batchLatencyQuery("schema", []LatencyRequest{
+		{TableName: "a", TimestampColumn: "time", BoundedBy: bound},
+		{TableName: "b", TimestampColumn: "time"},
+		{TableName: "c", TimestampColumn: "time", BoundedBy: bound.Add(time.Hour)},
+	})
+	assert.Contains(t, query, `MAX(CASE WHEN "time" <= TIMESTAMP 'epoch' + $1 * INTERVAL '1 second' THEN "time" END)`)
+	assert.Contains(t, query, `$2 * INTERVAL`)
+	assert.Equal(t, []interface{}{bound.Unix(), bound.Add(time.Hour).Unix()}, args)
+
+	t.Logf("Testing that filters restrict only their own table")
+	query, args = batchLatencyQuery("schema", []LatencyRequest{
+		{TableName: "a", TimestampColumn: "time", BoundedBy: bound,
+			Filter: &Filter{Column: "source", Operator: config.FilterEquals, Values: []string{"sis"}}},
+		{TableName: "b", TimestampColumn: "time"},
+	})
+	assert.Contains(t, query, `$1 * INTERVAL`)
+	assert.Contains(t, query, `FROM "schema"."a" WHERE "source" = $2`+"\nUNION ALL\n")
+	assert.True(t, strings.HasSuffix(query, `FROM "schema"."b"`))
+	assert.Equal(t, []interface{}{bound.Unix(), "sis"}, args)
+}
+
+func TestFilterPredicate(t *testing.T) {
+	for _, name := range hostileNames {
+		t.Logf("Testing that filterPredicate quotes column %q and binds its values", name)
+		predicate, args := filterPredicate(Filter{Column: name, Operator: config.FilterNotIn, Values: []string{name, "b"}},
+			quoteIdentifier, numberedPlaceholder, []interface{}{1})
+		identifiers, skeleton := splitIdentifiers(t, predicate)
+		assert.Equal(t, []string{name}, identifiers)
+		assert.Equal(t, "? NOT IN ($2, $3)", skeleton)
+		assert.Equal(t, []interface{}{1, name, "b"}, args)
+	}
+
+	tests := []struct {
+		filter   Filter
+		expected string
+	}{
+		{Filter{Column: "a", Operator: config.FilterEquals, Values: []string{"x"}}, `"a" = $1`},
+		{Filter{Column: "a", Operator: config.FilterNotEquals, Values: []string{"x"}}, `"a" <> $1`},
+		{Filter{Column: "a", Operator: config.FilterIn, Values: []string{"x", "y"}}, `"a" IN ($1, $2)`},
+		{Filter{Column: "a", Operator: config.FilterIsNull}, `"a" IS NULL`},
+		{Filter{Column: "a", Operator: config.FilterIsNotNull}, `"a" IS NOT NULL`},
+		{Filter{Column: "a", Operator: config.FilterEquals}, "1 = 0"},
+		{Filter{Column: "a", Operator: "; DROP TABLE users; --", Values: []string{"x"}}, "1 = 0"},
+	}
+	for _, test := range tests {
+		t.Logf("Testing that filterPredicate handles operator %q with %d values", test.filter.Operator, len(test.filter.Values))
+		predicate, _ := filterPredicate(test.filter, quoteIdentifier, numberedPlaceholder, nil)
+		assert.Equal(t, test.expected, predicate)
+	}
+}
+
+func TestGroupLatencyQuery(t *testing.T) {
+	for _, name := range hostileNames {
+		query, args := groupLatencyQuery("schema", LatencyRequest{TableName: "table", TimestampColumn: "time", GroupBy: name})
+		identifiers, skeleton := splitIdentifiers(t, query)
+		assert.Equal(t, []string{name, "time", "time", "schema", "table", name}, identifiers)
+		assert.Equal(t, "SELECT CAST(? AS VARCHAR) AS group_value, extract(epoch from MAX(?)) AS max_epoch, "+
+			"extract(epoch from MAX(?)) AS bounded_epoch FROM ?.? GROUP BY ?", skeleton)
+		assert.Empty(t, args)
+	}
+
+	t.Logf("Testing that grouped queries are filtered and bounded")
+	bound := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+	query, args := groupLatencyQuery("schema", LatencyRequest{TableName: "table", TimestampColumn: "time",
+		GroupBy: "source", BoundedBy: bound,
+		Filter: &Filter{Column: "source", Operator: config.FilterNotEquals, Values: []string{"test"}}})
+	assert.Contains(t, query, `WHERE "source" <> $2 GROUP BY "source"`)
+	assert.Contains(t, query, "$1 * INTERVAL")
+	assert.Equal(t, []interface{}{bound.Unix(), "test"}, args)
+}
+
+func TestLastWriteQuery(t *testing.T) {
+	for _, name := range hostileNames {
+		t.Logf("Testing lastWriteQuery with schema %q", name)
+		query, args := lastWriteQuery(name)
+		assert.Contains(t, query, "$1")
+		assert.Equal(t, []interface{}{name}, args)
+
+		identifiers, _ := splitIdentifiers(t, query)
+		assert.NotContains(t, identifiers, name, "schema name interpolated into query")
+	}
+
+	t.Log("Testing that lastWriteQuery reads both inserts and COPY loads")
+	query, _ := lastWriteQuery("mongo")
+	assert.Contains(t, query, "stl_insert")
+	assert.Contains(t, query, "stl_load_commits")
+}
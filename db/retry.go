@@ -0,0 +1,131 @@
+package db
+
+import (
+	"database/sql/driver"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	l "github.com/Clever/analytics-monitor/logger"
+	"github.com/Clever/pq"
+)
+
+// RetryPolicy configures how queries are retried when they fail
+// with a transient error. Delays grow exponentially from BaseDelay,
+// are capped at MaxDelay and have full jitter applied.
+type RetryPolicy struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+
+	// sleep is swapped out in tests so retries don't block
+	sleep func(time.Duration)
+}
+
+// DefaultRetryPolicy is used when no retry settings are configured
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 3,
+	BaseDelay:  time.Second,
+	MaxDelay:   30 * time.Second,
+}
+
+// transientErrorCodes are Postgres/Redshift error codes that are
+// worth retrying. Class 08 (connection exceptions) is handled separately.
+var transientErrorCodes = map[pq.ErrorCode]bool{
+	"40001": true, // serialization_failure
+	"40P01": true, // deadlock_detected
+	"53300": true, // too_many_connections
+	"55P03": true, // lock_not_available
+	"57P01": true, // admin_shutdown
+	"57P03": true, // cannot_connect_now
+}
+
+// transientMySQLErrors are MySQL error numbers that are worth retrying
+var transientMySQLErrors = map[uint16]bool{
+	1040: true, // ER_CON_COUNT_ERROR (too many connections)
+	1205: true, // ER_LOCK_WAIT_TIMEOUT
+	1213: true, // ER_LOCK_DEADLOCK
+}
+
+// IsTransientError reports whether err is likely to succeed on retry,
+// e.g. connection resets, serialization failures or too many connections.
+// Everything else (syntax errors, missing tables, ...) is permanent.
+func IsTransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code.Class() == "08" || transientErrorCodes[pqErr.Code]
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return transientMySQLErrors[mysqlErr.Number]
+	}
+
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) || errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	// Some drivers flatten network and locking errors into plain strings
+	message := err.Error()
+	return strings.Contains(message, "connection reset by peer") ||
+		strings.Contains(message, "database is locked")
+}
+
+// backoff returns the delay before the given retry attempt (starting at 1)
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay << uint(attempt-1)
+	if delay <= 0 || (p.MaxDelay > 0 && delay > p.MaxDelay) {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(delay) + 1))
+}
+
+// Do runs fn, retrying transient errors up to MaxRetries times.
+// Each failed attempt is logged. The last error is returned if
+// every attempt fails, and permanent errors are returned immediately.
+func (p RetryPolicy) Do(description string, fn func() error) error {
+	sleep := p.sleep
+	if sleep == nil {
+		sleep = time.Sleep
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if !IsTransientError(err) || attempt >= p.MaxRetries {
+			return err
+		}
+
+		delay := p.backoff(attempt + 1)
+		l.GetKVLogger().WarnD("db-retry", l.M{
+			"query":       description,
+			"attempt":     attempt + 1,
+			"max_retries": p.MaxRetries,
+			"delay":       delay.String(),
+			"error":       err.Error(),
+		})
+		sleep(delay)
+	}
+}
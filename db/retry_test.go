@@ -0,0 +1,88 @@
+package db
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Clever/pq"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsTransientError(t *testing.T) {
+	tests := []struct {
+		title     string
+		err       error
+		transient bool
+	}{
+		{"nil error", nil, false},
+		{"connection failure", &pq.Error{Code: "08006"}, true},
+		{"serialization failure", &pq.Error{Code: "40001"}, true},
+		{"too many connections", &pq.Error{Code: "53300"}, true},
+		{"lock not available", &pq.Error{Code: "55P03"}, true},
+		{"undefined table", &pq.Error{Code: "42P01"}, false},
+		{"syntax error", &pq.Error{Code: "42601"}, false},
+		{"wrapped pq error", fmt.Errorf("Error executing query: %w", &pq.Error{Code: "40P01"}), true},
+		{"mysql too many connections", &mysql.MySQLError{Number: 1040}, true},
+		{"mysql lock wait timeout", &mysql.MySQLError{Number: 1205}, true},
+		{"mysql unknown table", &mysql.MySQLError{Number: 1146}, false},
+		{"sqlite busy", errors.New("database is locked (5) (SQLITE_BUSY)"), true},
+		{"bad connection", driver.ErrBadConn, true},
+		{"connection reset", fmt.Errorf("read: %w", syscall.ECONNRESET), true},
+		{"flattened connection reset", errors.New("read tcp 10.0.0.1: connection reset by peer"), true},
+		{"generic error", errors.New("Data Warehouse out of space"), false},
+	}
+
+	for _, test := range tests {
+		t.Logf("Testing that IsTransientError classifies %s", test.title)
+		assert.Equal(t, test.transient, IsTransientError(test.err))
+	}
+}
+
+func TestRetryPolicyDo(t *testing.T) {
+	var delays []time.Duration
+	policy := RetryPolicy{
+		MaxRetries: 2,
+		BaseDelay:  10 * time.Millisecond,
+		MaxDelay:   15 * time.Millisecond,
+		sleep:      func(d time.Duration) { delays = append(delays, d) },
+	}
+
+	t.Log("Testing that transient errors are retried until success")
+	calls := 0
+	err := policy.Do("test", func() error {
+		calls++
+		if calls < 3 {
+			return &pq.Error{Code: "08006"}
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+	assert.Len(t, delays, 2)
+	for _, delay := range delays {
+		assert.True(t, delay <= policy.MaxDelay, "backoff exceeded the max delay")
+	}
+
+	t.Log("Testing that retries stop after MaxRetries")
+	calls = 0
+	err = policy.Do("test", func() error {
+		calls++
+		return &pq.Error{Code: "53300"}
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 3, calls)
+
+	t.Log("Testing that permanent errors are not retried")
+	calls = 0
+	err = policy.Do("test", func() error {
+		calls++
+		return &pq.Error{Code: "42P01"}
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+}
@@ -0,0 +1,164 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Clever/analytics-monitor/config"
+	l "github.com/Clever/analytics-monitor/logger"
+	// Pure Go SQLite driver, so local testing doesn't need cgo
+	_ "modernc.org/sqlite"
+)
+
+// SQLite supports latency and column quality checks against a local SQLite
+// file. The schema is a SQLite database name, "main" for the file itself.
+var SQLite Dialect = sqliteDialect{}
+
+// sqliteDialect generates SQL for SQLite. Timestamps must be stored as
+// text SQLite's date functions understand, e.g. "2006-01-02 15:04:05" in UTC.
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string {
+	return "sqlite"
+}
+
+func (sqliteDialect) Supports(check CheckType) bool {
+	return check == CheckLatency || check == CheckColumnQuality
+}
+
+// tableMetadataQuery infers timestamp columns by declared type,
+// since SQLite has no dedicated timestamp type
+func (sqliteDialect) tableMetadataQuery(schemaName string) (string, []interface{}) {
+	query := fmt.Sprintf(`
+		SELECT m.name, min(p.name)
+		FROM %s.sqlite_master AS m
+		INNER JOIN pragma_table_info(m.name, ?) AS p
+		WHERE m.type = 'table'
+		AND (upper(p.type) LIKE '%%TIME%%' OR upper(p.type) LIKE '%%DATE%%')
+		GROUP BY m.name
+	`, quoteIdentifier(schemaName))
+	return query, []interface{}{schemaName}
+}
+
+func (sqliteDialect) tablesQuery(schemaName string) (string, []interface{}) {
+	query := fmt.Sprintf(`
+		SELECT name
+		FROM %s.sqlite_master
+		WHERE type = 'table'
+		AND name NOT LIKE 'sqlite_%%'
+	`, quoteIdentifier(schemaName))
+	return query, nil
+}
+
+// columnsQuery uses declared types, which SQLite doesn't enforce
+func (sqliteDialect) columnsQuery(schemaName string) (string, []interface{}) {
+	query := fmt.Sprintf(`
+		SELECT m.name, p.name, p.type
+		FROM %s.sqlite_master AS m
+		INNER JOIN pragma_table_info(m.name, ?) AS p
+		WHERE m.type = 'table'
+		AND m.name NOT LIKE 'sqlite_%%'
+		ORDER BY m.name, p.cid
+	`, quoteIdentifier(schemaName))
+	return query, []interface{}{schemaName}
+}
+
+// schemasQuery lists the main and attached databases
+func (sqliteDialect) schemasQuery() string {
+	return `SELECT name FROM pragma_database_list WHERE name <> 'temp'`
+}
+
+func (sqliteDialect) latencyQuery(timestampColumn, schemaName, tableName string) string {
+	return fmt.Sprintf("SELECT CAST(strftime('%%s', MAX(%s)) AS REAL) FROM %s",
+		quoteIdentifier(timestampColumn), quoteTable(schemaName, tableName))
+}
+
+func (sqliteDialect) batchLatencyQuery(schemaName string, requests []LatencyRequest) (string, []interface{}) {
+	var args []interface{}
+	selects := make([]string, len(requests))
+	for i, request := range requests {
+		var epochs, where string
+		epochs, args = sqliteLatencyEpochs(request, args)
+		where, args = filterClause(request.Filter, quoteIdentifier, positionalPlaceholder, args)
+		selects[i] = fmt.Sprintf("SELECT %d AS idx, %s FROM %s%s",
+			i, epochs, quoteTable(schemaName, request.TableName), where)
+	}
+	return strings.Join(selects, "\nUNION ALL\n"), args
+}
+
+func (sqliteDialect) groupLatencyQuery(schemaName string, request LatencyRequest) (string, []interface{}) {
+	group := quoteIdentifier(request.GroupBy)
+	epochs, args := sqliteLatencyEpochs(request, nil)
+	where, args := filterClause(request.Filter, quoteIdentifier, positionalPlaceholder, args)
+	query := fmt.Sprintf("SELECT CAST(%s AS TEXT) AS group_value, %s FROM %s%s GROUP BY %s",
+		group, epochs, quoteTable(schemaName, request.TableName), where, group)
+	return query, args
+}
+
+// sqliteLatencyEpochs is latencyEpochs for SQLite
+func sqliteLatencyEpochs(request LatencyRequest, args []interface{}) (string, []interface{}) {
+	column := quoteIdentifier(request.TimestampColumn)
+	bounded := fmt.Sprintf("CAST(strftime('%%s', MAX(%s)) AS REAL)", column)
+	if !request.BoundedBy.IsZero() {
+		args = append(args, request.BoundedBy.Unix())
+		bounded = fmt.Sprintf(
+			"CAST(strftime('%%s', MAX(CASE WHEN CAST(strftime('%%s', %s) AS INTEGER) <= ? THEN %s END)) AS REAL)",
+			column, column)
+	}
+	return fmt.Sprintf("CAST(strftime('%%s', MAX(%s)) AS REAL) AS max_epoch, %s AS bounded_epoch", column, bounded), args
+}
+
+func (sqliteDialect) recentTimestampsQuery(timestampColumn, schemaName, tableName string, filter *Filter, since time.Time,
+	limit int) (string, []interface{}) {
+	column := quoteIdentifier(timestampColumn)
+	filtered, args := recentTimestampsFilter(filter, quoteIdentifier, positionalPlaceholder,
+		[]interface{}{since.Unix()})
+	query := fmt.Sprintf(`
+		SELECT DISTINCT CAST(strftime('%%s', %s) AS INTEGER) / 3600 * 3600 AS hour_epoch
+		FROM %s
+		WHERE CAST(strftime('%%s', %s) AS INTEGER) >= ?%s
+		ORDER BY hour_epoch DESC
+		LIMIT ?
+	`, column, quoteTable(schemaName, tableName), column, filtered)
+	return query, append(args, limit)
+}
+
+func (sqliteDialect) columnStatsQuery(schemaName, tableName, timestampColumn string, since time.Time,
+	requests []ColumnStatsRequest) (string, []interface{}) {
+	selects, args := columnStatsSelects(requests, quoteIdentifier, "TEXT",
+		func(int) string { return "?" })
+	args = append(args, since.Unix())
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE CAST(strftime('%%s', %s) AS INTEGER) >= ?",
+		selects, quoteTable(schemaName, tableName), quoteIdentifier(timestampColumn))
+	return query, args
+}
+
+// newSQLiteClient creates a SQLite db client for the database file at path.
+func newSQLiteClient(path, clusterName string, retry RetryPolicy, batchSize int) (Client, error) {
+	l.GetKVLogger().InfoD("New-sqlite-client", l.M{
+		"path":    path,
+		"dialect": SQLite.Name(),
+	})
+	session, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite only allows a single writer, and in-memory databases
+	// are private to their connection
+	session.SetMaxOpenConns(1)
+
+	return &sqlClient{session, clusterName, SQLite, retry, batchSize}, nil
+}
+
+// NewSQLiteClient initializes a sqlite client.
+// clusterName defaults to "sqlite-<file name>".
+func NewSQLiteClient(clusterName string) (Client, error) {
+	if clusterName == "" {
+		name := filepath.Base(config.SQLitePath)
+		clusterName = "sqlite-" + strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	return newSQLiteClient(config.SQLitePath, clusterName, configuredRetryPolicy(), config.LatencyBatchSize)
+}
@@ -0,0 +1,257 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Clever/analytics-monitor/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupSQLite(t *testing.T) *sqlClient {
+	client, err := newSQLiteClient(filepath.Join(t.TempDir(), "test.db"), "testCluster",
+		DefaultRetryPolicy, DefaultLatencyBatchSize)
+	require.NoError(t, err)
+	db := client.(*sqlClient)
+	t.Cleanup(func() { db.session.Close() })
+
+	for _, statement := range []string{
+		`CREATE TABLE latency (id INTEGER, "time" TIMESTAMP, updated_at DATETIME)`,
+		`CREATE TABLE empty (_data_timestamp TIMESTAMP)`,
+		`CREATE TABLE no_timestamp (id INTEGER, name TEXT)`,
+		`CREATE TABLE "quote""d" ("ti""me" TIMESTAMP)`,
+	} {
+		_, err = db.session.Exec(statement)
+		require.NoError(t, err)
+	}
+
+	return db
+}
+
+func TestSQLiteQueryTableMetadata(t *testing.T) {
+	db := setupSQLite(t)
+
+	metadata, err := db.QueryTableMetadata("main")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]TableMetadata{
+		"latency": {TableName: "latency", TimestampColumn: "time"},
+		"empty":   {TableName: "empty", TimestampColumn: "_data_timestamp"},
+		`quote"d`: {TableName: `quote"d`, TimestampColumn: `ti"me`},
+	}, metadata)
+}
+
+func TestSQLiteQueryTables(t *testing.T) {
+	db := setupSQLite(t)
+
+	schemas, err := db.QuerySchemas()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"main"}, schemas)
+
+	tables, err := db.QueryTables("main")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"empty", "latency", "no_timestamp", `quote"d`}, tables)
+}
+
+func TestSQLiteQueryColumns(t *testing.T) {
+	db := setupSQLite(t)
+
+	columns, err := db.QueryColumns("main")
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]Column{
+		"latency":      {{"id", "INTEGER"}, {"time", "TIMESTAMP"}, {"updated_at", "DATETIME"}},
+		"empty":        {{"_data_timestamp", "TIMESTAMP"}},
+		"no_timestamp": {{"id", "INTEGER"}, {"name", "TEXT"}},
+		`quote"d`:      {{`ti"me`, "TIMESTAMP"}},
+	}, columns)
+}
+
+func TestSQLiteQueryLatency(t *testing.T) {
+	db := setupSQLite(t)
+	past := time.Now().UTC().Add(-96 * time.Hour)
+
+	_, valid, err := db.QueryLatency("time", "main", "latency")
+	assert.NoError(t, err)
+	assert.False(t, valid)
+
+	_, err = db.session.Exec(`INSERT INTO latency (id, "time") VALUES (1, ?)`, past.Format("2006-01-02 15:04:05"))
+	require.NoError(t, err)
+	_, err = db.session.Exec(`INSERT INTO "quote""d" ("ti""me") VALUES (?)`, past.Format("2006-01-02 15:04:05"))
+	require.NoError(t, err)
+
+	latency, valid, err := db.QueryLatency("time", "main", "latency")
+	assert.NoError(t, err)
+	assert.True(t, valid)
+	// Give a little leeway for timing
+	assert.True(t, latency >= 95 && latency <= 97)
+
+	t.Log("Testing that batched latencies fall back to single queries around a bad table")
+	results := db.QueryLatencies("main", []LatencyRequest{
+		{TableName: "latency", TimestampColumn: "time"},
+		{TableName: "empty", TimestampColumn: "_data_timestamp"},
+		{TableName: "missing", TimestampColumn: "time"},
+		{TableName: `quote"d`, TimestampColumn: `ti"me`},
+	})
+	assert.Len(t, results, 4)
+	assert.NoError(t, results["latency"].Err)
+	assert.True(t, results["latency"].HasRows)
+	assert.Equal(t, latency, results["latency"].LatencyHrs)
+	assert.NoError(t, results["empty"].Err)
+	assert.False(t, results["empty"].HasRows)
+	assert.Error(t, results["missing"].Err)
+	assert.NoError(t, results[`quote"d`].Err)
+	assert.Equal(t, latency, results[`quote"d`].LatencyHrs)
+	assert.Equal(t, past.Truncate(time.Second), results[`quote"d`].MaxTimestamp)
+
+	t.Log("Testing that a healthy batch is answered by a single query")
+	batch, err := db.queryLatencyBatch("main", []LatencyRequest{
+		{TableName: "latency", TimestampColumn: "time"},
+		{TableName: "empty", TimestampColumn: "_data_timestamp"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, latency, batch["latency"].LatencyHrs)
+	assert.Equal(t, past.Truncate(time.Second), batch["latency"].MaxTimestamp)
+	assert.False(t, batch["empty"].HasRows)
+	assert.True(t, batch["empty"].MaxTimestamp.IsZero())
+
+	t.Log("Testing that bounded latencies ignore timestamps after the bound")
+	future := time.Now().UTC().Add(24 * 365 * time.Hour)
+	_, err = db.session.Exec(`INSERT INTO latency (id, "time") VALUES (2, ?)`, future.Format("2006-01-02 15:04:05"))
+	require.NoError(t, err)
+	results = db.QueryLatencies("main", []LatencyRequest{
+		{TableName: "latency", TimestampColumn: "time"},
+		{TableName: `quote"d`, TimestampColumn: `ti"me`, BoundedBy: time.Now()},
+		{TableName: "empty", TimestampColumn: "_data_timestamp", BoundedBy: time.Now()},
+	})
+	assert.True(t, results["latency"].LatencyHrs < 0)
+	assert.Equal(t, future.Truncate(time.Second), results["latency"].UnboundedMaxTimestamp)
+	assert.Equal(t, latency, results[`quote"d`].LatencyHrs)
+	assert.Equal(t, past.Truncate(time.Second), results[`quote"d`].UnboundedMaxTimestamp)
+	assert.False(t, results["empty"].HasRows)
+	bounded, err := db.queryLatencyBatch("main", []LatencyRequest{
+		{TableName: "latency", TimestampColumn: "time", BoundedBy: time.Now()},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, latency, bounded["latency"].LatencyHrs)
+	assert.Equal(t, past.Truncate(time.Second), bounded["latency"].MaxTimestamp)
+	assert.Equal(t, future.Truncate(time.Second), bounded["latency"].UnboundedMaxTimestamp)
+}
+
+func TestSQLiteQueryGroupLatencies(t *testing.T) {
+	db := setupSQLite(t)
+	request := LatencyRequest{TableName: "latency", TimestampColumn: "time", GroupBy: "id"}
+
+	groups, err := db.QueryGroupLatencies("main", request)
+	assert.NoError(t, err)
+	assert.Empty(t, groups)
+
+	now := time.Now().UTC()
+	for _, row := range []struct {
+		id  interface{}
+		age time.Duration
+	}{{1, time.Hour}, {1, 30 * time.Hour}, {2, 50 * time.Hour}, {nil, 10 * time.Hour}, {3, -24 * time.Hour}} {
+		_, err = db.session.Exec(`INSERT INTO latency (id, "time") VALUES (?, ?)`,
+			row.id, now.Add(-row.age).Format("2006-01-02 15:04:05"))
+		require.NoError(t, err)
+	}
+
+	t.Log("Testing that each group's latency is its own most recent timestamp, null groups included")
+	groups, err = db.QueryGroupLatencies("main", request)
+	assert.NoError(t, err)
+	assert.Len(t, groups, 4)
+	assert.Equal(t, int64(1), groups["1"].LatencyHrs)
+	assert.Equal(t, int64(50), groups["2"].LatencyHrs)
+	assert.Equal(t, int64(10), groups[NullGroup].LatencyHrs)
+	assert.True(t, groups["3"].HasRows)
+
+	t.Log("Testing that filters and bounds apply to every group")
+	request.Filter = &Filter{Column: "id", Operator: config.FilterIn, Values: []string{"1", "3"}}
+	request.BoundedBy = now
+	groups, err = db.QueryGroupLatencies("main", request)
+	assert.NoError(t, err)
+	assert.Len(t, groups, 2)
+	assert.False(t, groups["3"].HasRows)
+	assert.Equal(t, now.Add(24*time.Hour).Truncate(time.Second), groups["3"].UnboundedMaxTimestamp)
+
+	t.Log("Testing that a missing table is an error")
+	request.TableName = "missing"
+	_, err = db.QueryGroupLatencies("main", request)
+	assert.Error(t, err)
+}
+
+func TestSQLiteQueryColumnStats(t *testing.T) {
+	db := setupSQLite(t)
+	now := time.Now().UTC()
+	requests := []ColumnStatsRequest{
+		{Column: "id", AllowedValues: []string{"1", "2"}},
+		{Column: "updated_at"},
+	}
+
+	stats, err := db.QueryColumnStats("main", "latency", "time", now.Add(-24*time.Hour), requests)
+	assert.NoError(t, err)
+	assert.Equal(t, []ColumnStats{{Column: "id"}, {Column: "updated_at"}}, stats)
+	assert.Equal(t, float64(0), stats[0].NullFraction())
+
+	for _, row := range []struct {
+		id  interface{}
+		age time.Duration
+	}{{1, time.Hour}, {1, 2 * time.Hour}, {3, 3 * time.Hour}, {nil, 4 * time.Hour}, {4, 48 * time.Hour}} {
+		_, err = db.session.Exec(`INSERT INTO latency (id, "time") VALUES (?, ?)`,
+			row.id, now.Add(-row.age).Format("2006-01-02 15:04:05"))
+		require.NoError(t, err)
+	}
+
+	t.Log("Testing that only rows within the window are profiled")
+	stats, err = db.QueryColumnStats("main", "latency", "time", now.Add(-24*time.Hour), requests)
+	assert.NoError(t, err)
+	assert.Equal(t, []ColumnStats{
+		{Column: "id", Rows: 4, Nulls: 1, Distinct: 2, Disallowed: 1},
+		{Column: "updated_at", Rows: 4, Nulls: 4},
+	}, stats)
+	assert.Equal(t, 0.25, stats[0].NullFraction())
+	assert.Equal(t, float64(1), stats[1].NullFraction())
+
+	t.Log("Testing that a missing table is an error")
+	_, err = db.QueryColumnStats("main", "missing", "time", now, requests)
+	assert.Error(t, err)
+}
+
+func TestSQLiteQueryRecentTimestamps(t *testing.T) {
+	db := setupSQLite(t)
+	hour := time.Now().UTC().Truncate(time.Hour)
+
+	for _, ts := range []time.Time{
+		hour.Add(-30 * time.Hour),
+		hour.Add(-6*time.Hour + 10*time.Minute),
+		hour.Add(-6*time.Hour + 40*time.Minute),
+		hour.Add(-2 * time.Hour),
+		hour.Add(5 * time.Minute),
+	} {
+		_, err := db.session.Exec(`INSERT INTO latency (id, "time") VALUES (1, ?)`, ts.Format("2006-01-02 15:04:05"))
+		require.NoError(t, err)
+	}
+
+	t.Log("Testing that distinct hours since the given time are returned, most recent first")
+	hours, err := db.QueryRecentTimestamps("time", "main", "latency", nil, hour.Add(-24*time.Hour), 10)
+	require.NoError(t, err)
+	assert.Equal(t, []time.Time{hour, hour.Add(-2 * time.Hour), hour.Add(-6 * time.Hour)}, hours)
+
+	t.Log("Testing that the number of hours is limited")
+	hours, err = db.QueryRecentTimestamps("time", "main", "latency", nil, hour.Add(-48*time.Hour), 2)
+	require.NoError(t, err)
+	assert.Equal(t, []time.Time{hour, hour.Add(-2 * time.Hour)}, hours)
+
+	t.Log("Testing that only rows matching the filter are counted")
+	_, err = db.session.Exec(`UPDATE latency SET id = 2 WHERE "time" < ?`, hour.Add(-time.Hour).Format("2006-01-02 15:04:05"))
+	require.NoError(t, err)
+	hours, err = db.QueryRecentTimestamps("time", "main", "latency",
+		&Filter{Column: "id", Operator: config.FilterEquals, Values: []string{"2"}}, hour.Add(-24*time.Hour), 10)
+	require.NoError(t, err)
+	assert.Equal(t, []time.Time{hour.Add(-2 * time.Hour), hour.Add(-6 * time.Hour)}, hours)
+
+	t.Log("Testing that empty tables have no hours")
+	hours, err = db.QueryRecentTimestamps("_data_timestamp", "main", "empty", nil, hour.Add(-48*time.Hour), 2)
+	require.NoError(t, err)
+	assert.Empty(t, hours)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// dependencyGraph maps every checked table, as schema.table, to the checked
+// tables it depends on. Schema dependencies are expanded to every checked
+// table in the schema, and dependencies that aren't checked are dropped.
+func dependencyGraph(checks Checks) map[string][]string {
+	graph := make(map[string][]string)
+	for schemaName, tableChecks := range checks {
+		for tableName, check := range tableChecks {
+			fullTableName := schemaName + "." + tableName
+			seen := map[string]bool{fullTableName: true}
+			for _, dependency := range check.DependsOn {
+				var upstreams []string
+				if upstreamSchema, upstreamTable, hasTable := strings.Cut(dependency, "."); hasTable {
+					if _, ok := checks[upstreamSchema][upstreamTable]; ok {
+						upstreams = append(upstreams, dependency)
+					}
+				} else {
+					for upstreamTable := range checks[upstreamSchema] {
+						upstreams = append(upstreams, upstreamSchema+"."+upstreamTable)
+					}
+				}
+
+				for _, upstream := range upstreams {
+					if !seen[upstream] {
+						seen[upstream] = true
+						graph[fullTableName] = append(graph[fullTableName], upstream)
+					}
+				}
+			}
+			sort.Strings(graph[fullTableName])
+		}
+	}
+	return graph
+}
+
+// staleChains returns, for every stale table with a stale upstream, the
+// chain of stale tables from the root cause down to the table. A root cause
+// is a stale table none of whose upstreams are stale. Tables that are only
+// stale upstream of each other (a cycle) have no root cause, so are left out.
+func staleChains(graph map[string][]string, stale map[string]bool) map[string][]string {
+	chains := make(map[string][]string)
+	for table := range stale {
+		if chain := staleChain(table, graph, stale, make(map[string]bool)); chain != nil {
+			chains[table] = chain
+		}
+	}
+	return chains
+}
+
+// staleChain searches the stale upstreams of table for a root cause,
+// returning the chain from it to table, or nil if there is none
+func staleChain(table string, graph map[string][]string, stale map[string]bool, visiting map[string]bool) []string {
+	visiting[table] = true
+	defer delete(visiting, table)
+
+	for _, upstream := range graph[table] {
+		if !stale[upstream] || visiting[upstream] {
+			continue
+		}
+		if !hasStaleUpstream(upstream, graph, stale) {
+			return []string{upstream, table}
+		}
+		if chain := staleChain(upstream, graph, stale, visiting); chain != nil {
+			return append(chain, table)
+		}
+	}
+	return nil
+}
+
+// hasStaleUpstream reports whether any of table's direct upstreams are stale
+func hasStaleUpstream(table string, graph map[string][]string, stale map[string]bool) bool {
+	for _, upstream := range graph[table] {
+		if stale[upstream] {
+			return true
+		}
+	}
+	return false
+}
+
+// downstreamOf inverts chains, listing the suppressed tables of each root cause
+func downstreamOf(chains map[string][]string) map[string][]string {
+	downstream := make(map[string][]string)
+	for table, chain := range chains {
+		downstream[chain[0]] = append(downstream[chain[0]], table)
+	}
+	for _, tables := range downstream {
+		sort.Strings(tables)
+	}
+	return downstream
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Clever/analytics-monitor/config"
+	"github.com/Clever/analytics-monitor/db"
+	"github.com/Clever/analytics-monitor/drift"
+	l "github.com/Clever/analytics-monitor/logger"
+	"github.com/Clever/analytics-monitor/store"
+)
+
+// performSchemaDriftChecks snapshots the columns of every checked table
+// and, if a state store is configured, logs how they changed since the
+// previous snapshot. Checks whose timestamp column no longer exists are
+// logged and dropped from checks, rather than failing their latency query.
+// Returns an error for each such check, unless it's silenced.
+func performSchemaDriftChecks(client db.Client, checks Checks) []error {
+	var missingErrors []error
+	clusterName := client.GetClusterName()
+
+	for schemaName, tableChecks := range checks {
+		columns, err := client.QueryColumns(schemaName)
+		if err != nil {
+			l.GetKVLogger().ErrorD("schema-drift-check-failed", l.M{
+				"schema": schemaName,
+				"error":  err.Error(),
+			})
+			continue
+		}
+
+		// Only monitored tables are snapshotted
+		snapshot := make(map[string][]db.Column)
+		for tableName := range tableChecks {
+			if tableColumns, ok := columns[tableName]; ok {
+				snapshot[tableName] = tableColumns
+			}
+		}
+		logSchemaDrift(clusterName, schemaName, snapshot, tableChecks)
+
+		for tableName, check := range tableChecks {
+			timestampColumn := check.Latency.TimestampColumn
+			if !check.Latency.ChecksDataTimestamp() || timestampColumn == "" {
+				continue
+			}
+			tableColumns, ok := snapshot[tableName]
+			if !ok {
+				continue
+			}
+
+			fullTableName := fmt.Sprintf("%s.%s.%s", clusterName, schemaName, tableName)
+			details := checkDetails(schemaName, tableName, check.Ownership)
+			if drift.HasColumn(tableColumns, timestampColumn) {
+				logger.CheckTimestampColumnEvent(0, fullTableName, timestampColumn, details)
+				continue
+			}
+
+			missingValue, status := 1, store.StatusError
+			if silence, ok := silenceFor(clusterName, schemaName, tableName); ok {
+				details.Silenced = silence.Reason
+				missingValue, status = 0, store.StatusSilenced
+			} else {
+				missingErrors = append(missingErrors, fmt.Errorf("timestamp column %s of %s.%s no longer exists",
+					timestampColumn, schemaName, tableName))
+			}
+			logger.CheckTimestampColumnEvent(missingValue, fullTableName, timestampColumn, details)
+			recordResult(store.Result{
+				Cluster:   clusterName,
+				Schema:    schemaName,
+				Table:     tableName,
+				Check:     string(db.CheckLatency),
+				Threshold: check.Latency.Threshold,
+				Status:    status,
+			})
+
+			if check.Latency.ChecksLastWrite() {
+				// The last write check doesn't need the timestamp column
+				check.Latency.FreshnessMode = config.FreshnessModeLastWrite
+				tableChecks[tableName] = check
+			} else {
+				delete(tableChecks, tableName)
+			}
+		}
+	}
+
+	return missingErrors
+}
+
+// logSchemaDrift logs the changes in a schema's snapshot since the one in
+// the state store, then replaces it. Without a state store there is
+// nothing to compare against.
+func logSchemaDrift(clusterName, schemaName string, snapshot map[string][]db.Column, tableChecks map[string]config.TableCheck) {
+	if history == nil {
+		return
+	}
+
+	previous, err := history.Columns(clusterName, schemaName)
+	if err != nil {
+		l.GetKVLogger().ErrorD("schema-snapshot-failed", l.M{
+			"schema": schemaName,
+			"error":  err.Error(),
+		})
+		return
+	}
+	for _, change := range drift.Diff(previous, snapshot) {
+		fullTableName := fmt.Sprintf("%s.%s.%s", clusterName, schemaName, change.Table)
+		details := checkDetails(schemaName, change.Table, tableChecks[change.Table].Ownership)
+		logger.SchemaDriftEvent(fullTableName, change.Column, change.Kind, change.PreviousType, change.Type, details)
+	}
+
+	if err := history.SaveColumns(clusterName, schemaName, snapshot); err != nil {
+		l.GetKVLogger().ErrorD("schema-snapshot-failed", l.M{
+			"schema": schemaName,
+			"error":  err.Error(),
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package drift
+
+import (
+	"sort"
+
+	"github.com/Clever/analytics-monitor/db"
+)
+
+// Kinds of change to a table's columns
+const (
+	KindAdded   = "added"
+	KindRemoved = "removed"
+	KindRetyped = "retyped"
+)
+
+// Change is a column added to, removed from or retyped in a table.
+// PreviousType is empty for added columns, and Type for removed ones.
+type Change struct {
+	Table        string
+	Column       string
+	Kind         string
+	PreviousType string
+	Type         string
+}
+
+// Diff compares the columns of each table against a previous snapshot.
+// Tables missing from either snapshot are new or dropped, rather than
+// changed, so are skipped. Changes are ordered by table, then by column
+// in the order they're declared, removed columns last.
+func Diff(previous, current map[string][]db.Column) []Change {
+	var tables []string
+	for table := range current {
+		if _, ok := previous[table]; ok {
+			tables = append(tables, table)
+		}
+	}
+	sort.Strings(tables)
+
+	var changes []Change
+	for _, table := range tables {
+		previousTypes := make(map[string]string)
+		for _, column := range previous[table] {
+			previousTypes[column.Name] = column.Type
+		}
+		currentTypes := make(map[string]string)
+		for _, column := range current[table] {
+			currentTypes[column.Name] = column.Type
+			previousType, existed := previousTypes[column.Name]
+			switch {
+			case !existed:
+				changes = append(changes, Change{Table: table, Column: column.Name, Kind: KindAdded, Type: column.Type})
+			case previousType != column.Type:
+				changes = append(changes, Change{Table: table, Column: column.Name, Kind: KindRetyped,
+					PreviousType: previousType, Type: column.Type})
+			}
+		}
+		for _, column := range previous[table] {
+			if _, exists := currentTypes[column.Name]; !exists {
+				changes = append(changes, Change{Table: table, Column: column.Name, Kind: KindRemoved,
+					PreviousType: column.Type})
+			}
+		}
+	}
+	return changes
+}
+
+// HasColumn reports whether a column with the given name is in columns
+func HasColumn(columns []db.Column, name string) bool {
+	for _, column := range columns {
+		if column.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+package drift
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Clever/analytics-monitor/db"
+)
+
+func TestDiff(t *testing.T) {
+	previous := map[string][]db.Column{
+		"districts": {{Name: "id", Type: "integer"}, {Name: "name", Type: "text"}, {Name: "updated_at", Type: "timestamp"}},
+		"schools":   {{Name: "id", Type: "integer"}},
+		"dropped":   {{Name: "id", Type: "integer"}},
+	}
+	current := map[string][]db.Column{
+		"districts": {{Name: "id", Type: "bigint"}, {Name: "updated_at", Type: "timestamp"}, {Name: "deleted_at", Type: "timestamp"}},
+		"schools":   {{Name: "id", Type: "integer"}},
+		"created":   {{Name: "id", Type: "integer"}},
+	}
+
+	t.Logf("Testing that added, removed and retyped columns are found")
+	assert.Equal(t, []Change{
+		{Table: "districts", Column: "id", Kind: KindRetyped, PreviousType: "integer", Type: "bigint"},
+		{Table: "districts", Column: "deleted_at", Kind: KindAdded, Type: "timestamp"},
+		{Table: "districts", Column: "name", Kind: KindRemoved, PreviousType: "text"},
+	}, Diff(previous, current))
+
+	t.Logf("Testing that nothing changes without a previous snapshot")
+	assert.Empty(t, Diff(nil, current))
+}
+
+func TestHasColumn(t *testing.T) {
+	columns := []db.Column{{Name: "id", Type: "integer"}, {Name: "updated_at", Type: "timestamp"}}
+	assert.True(t, HasColumn(columns, "updated_at"))
+	assert.False(t, HasColumn(columns, "_data_timestamp"))
+	assert.False(t, HasColumn(nil, "updated_at"))
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Clever/analytics-monitor/baseline"
+	"github.com/Clever/analytics-monitor/config"
+	l "github.com/Clever/analytics-monitor/logger"
+	"github.com/Clever/analytics-monitor/store"
+)
+
+// checkRun identifies a run of the checks and collects its results
+// and the baselines of thresholds learned for it, by schema.table.
+// silences are those that haven't ended by the start of the run.
+type checkRun struct {
+	id        string
+	startedAt time.Time
+	results   []store.Result
+	baselines map[string]baseline.Baseline
+	silences  []config.Silence
+}
+
+// newCheckRun starts a run, identified by its start time and a random suffix
+func newCheckRun() *checkRun {
+	now := time.Now().UTC()
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return &checkRun{
+		id:        now.Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix),
+		startedAt: now,
+		baselines: make(map[string]baseline.Baseline),
+	}
+}
+
+// recordResult adds the result of a check to the current run
+func recordResult(result store.Result) {
+	if currentRun == nil {
+		return
+	}
+	result.RunID = currentRun.id
+	result.CheckedAt = currentRun.startedAt
+	currentRun.results = append(currentRun.results, result)
+}
+
+// checkStatus returns the status of a check that observed data, or not,
+// and whether it breached its threshold. Checks without data are only
+// breaches if they say so.
+func checkStatus(hasData bool, breached bool) store.Status {
+	switch {
+	case !hasData && !breached:
+		return store.StatusSkipped
+	case !hasData:
+		return store.StatusNoData
+	case breached:
+		return store.StatusBreach
+	}
+	return store.StatusOK
+}
+
+// writeHistory writes the current run's results to the history store,
+// if one is configured. Failures are logged but don't fail the run.
+func writeHistory() {
+	if history == nil || currentRun == nil || len(currentRun.results) == 0 {
+		return
+	}
+	if err := history.RecordResults(currentRun.results); err != nil {
+		l.GetKVLogger().ErrorD("history-write-failed", l.M{
+			"run_id": currentRun.id,
+			"error":  err.Error(),
+		})
+	}
+}
+
+// historyCommand prints the stored check results of a table over a time range
+func historyCommand(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("history", flag.ContinueOnError)
+	historyURL := flags.String("history", os.Getenv("HISTORY_URL"),
+		"store for check history, a postgres:// URL or sqlite:<path> (env HISTORY_URL)")
+	table := flags.String("table", "", "schema.table, or the s3:// path of an object check")
+	cluster := flags.String("cluster", os.Getenv("CLUSTER_NAME"), "only show results from this cluster (env CLUSTER_NAME)")
+	from := flags.String("from", "168h", "start of the range, as an RFC 3339 time, a date or a duration ago")
+	to := flags.String("to", "", "end of the range, in the same formats as -from. Defaults to now")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *historyURL == "" {
+		return fmt.Errorf("a history store is required (-history or HISTORY_URL)")
+	}
+
+	query := store.ResultsQuery{Cluster: *cluster}
+	var err error
+	query.Schema, query.Table, err = splitTableName(*table)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if query.From, err = parseTimeFlag(*from, now); err != nil {
+		return err
+	}
+	if query.To, err = parseTimeFlag(*to, now); err != nil {
+		return err
+	}
+
+	s, err := store.Open(*historyURL)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	results, err := s.Results(query)
+	if err != nil {
+		return err
+	}
+	return printResults(out, results)
+}
+
+// splitTableName splits schema.table. Object check paths have no schema.
+func splitTableName(name string) (string, string, error) {
+	if strings.HasPrefix(name, "s3://") {
+		return "", name, nil
+	}
+	parts := strings.SplitN(name, ".", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("expected a schema.table name, got %q", name)
+	}
+	return parts[0], parts[1], nil
+}
+
+// parseTimeFlag parses an RFC 3339 time, a YYYY-MM-DD date or a duration
+// before now. An empty value is the zero time.
+func parseTimeFlag(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	if ago, err := time.ParseDuration(value); err == nil {
+		return now.Add(-ago), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: expected an RFC 3339 time, a date or a duration", value)
+}
+
+// printResults writes results as a table
+func printResults(out io.Writer, results []store.Result) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "CHECKED_AT\tCLUSTER\tCHECK\tSTATUS\tLATENCY\tTHRESHOLD\tRUN_ID")
+	for _, result := range results {
+		latency := "-"
+		if result.HasLatency {
+			latency = fmt.Sprintf("%dh", result.LatencyHrs)
+		}
+		check := result.Check
+		if result.Group != "" {
+			check = fmt.Sprintf("%s[%s]", result.Check, result.Group)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", result.CheckedAt.Format(time.RFC3339),
+			result.Cluster, check, result.Status, latency, result.Threshold, result.RunID)
+	}
+	return w.Flush()
+}
@@ -1,6 +1,9 @@
 package logger
 
 import (
+	"fmt"
+	"strings"
+
 	kvLogger "gopkg.in/Clever/kayvee-go.v6/logger"
 )
 
@@ -8,8 +11,81 @@ import (
 // out the specific log functions we use here
 type Logger interface {
 	JobFinishedEvent(payload string, didSucceed bool)
-	CheckLatencyEvent(latencyErrValue int, fullTableName, reportedLatency, threshold string)
+	CheckLatencyEvent(latencyErrValue int, fullTableName, reportedLatency, threshold string, details Details)
+	CheckLastWriteEvent(lastWriteErrValue int, fullTableName, reportedLastWrite, threshold string, details Details)
 	CheckLoadErrorEvent(loadErrValue int, loadErrors string)
+	CheckCoverageEvent(cluster, gap string, items []string)
+	SchemaDriftEvent(fullTableName, column, change, previousType, currentType string, details Details)
+	CheckTimestampColumnEvent(missingValue int, fullTableName, timestampColumn string, details Details)
+	CheckFutureTimestampEvent(futureValue int, fullTableName, maxTimestamp, tolerance string, details Details)
+	CheckNullFractionEvent(nullValue int, fullTableName, column, nullFraction, maxNullFraction string, details Details)
+	CheckDistinctCountEvent(distinctValue int, fullTableName, column, distinctCount, minDistinct string, details Details)
+	CheckAllowedValuesEvent(disallowedValue int, fullTableName, column, disallowedRows string, allowedValues []string, details Details)
+}
+
+// Ownership identifies who is responsible for a checked table, so that
+// alerts can be routed to them
+type Ownership struct {
+	Owner      string
+	Team       string
+	RunbookURL string
+}
+
+// addTo adds the non-empty ownership fields to the dimensions of an event
+func (o Ownership) addTo(data M) M {
+	if o.Owner != "" {
+		data["owner"] = o.Owner
+	}
+	if o.Team != "" {
+		data["team"] = o.Team
+	}
+	if o.RunbookURL != "" {
+		data["runbook_url"] = o.RunbookURL
+	}
+	return data
+}
+
+// Details are the optional dimensions of a check event
+type Details struct {
+	Ownership
+	// Baseline is the learned update cadence of a table with an automatic
+	// threshold, and BaselineSource what it was learned from
+	Baseline       string
+	BaselineSource string
+	// DependencyChain is set when a breach is suppressed because an
+	// upstream table is stale. It lists the stale tables from the root
+	// cause down to this table.
+	DependencyChain []string
+	// Downstream lists the breaching tables suppressed because of this one
+	Downstream []string
+	// Silenced is the reason of the silence a breach was silenced by
+	Silenced string
+	// Group is the value of the group_by column checked, if the
+	// check is grouped
+	Group string
+}
+
+// addTo adds the details that are set to the dimensions of an event
+func (d Details) addTo(data M) M {
+	d.Ownership.addTo(data)
+	if d.Baseline != "" {
+		data["baseline"] = d.Baseline
+		data["baseline_source"] = d.BaselineSource
+	}
+	if len(d.DependencyChain) > 0 {
+		data["suppressed"] = fmt.Sprintf("upstream %s stale", d.DependencyChain[0])
+		data["dependency_chain"] = strings.Join(d.DependencyChain, " -> ")
+	}
+	if len(d.Downstream) > 0 {
+		data["downstream"] = strings.Join(d.Downstream, ", ")
+	}
+	if d.Silenced != "" {
+		data["silenced"] = d.Silenced
+	}
+	if d.Group != "" {
+		data["group"] = d.Group
+	}
+	return data
 }
 
 // M is an alias for map[string]interface{} to make log lines less painful to write.
@@ -27,14 +103,38 @@ const (
 	// checkLatency refers to latency check results
 	checkLatency = "check-latency"
 
+	// checkLastWrite refers to last write (system table) freshness results
+	checkLastWrite = "check-last-write"
+
 	// checkLoadErrors refers to STL Load Errors results
 	checkLoadErrors = "check-load-errors"
+
+	// checkCoverage refers to gaps in what latency checks cover
+	checkCoverage = "check-coverage"
+
+	// schemaDrift refers to columns added to, removed from or retyped in a table
+	schemaDrift = "schema-drift"
+
+	// checkTimestampColumn refers to whether a table's timestamp column exists
+	checkTimestampColumn = "check-timestamp-column"
+
+	// checkFutureTimestamp refers to timestamps too far in the future
+	checkFutureTimestamp = "check-future-timestamp"
+
+	// checkNullFraction refers to the fraction of a column's recent rows that are null
+	checkNullFraction = "check-null-fraction"
+
+	// checkDistinctCount refers to the number of distinct values in a column's recent rows
+	checkDistinctCount = "check-distinct-count"
+
+	// checkAllowedValues refers to a column's recent rows with values it isn't allowed
+	checkAllowedValues = "check-allowed-values"
 )
 
 var defaultLog logger
 
 func init() {
-	defaultLog = logger{log: kvLogger.New("analytics-monitor")}
+	defaultLog = logger{log: newKayveeLogger("analytics-monitor")}
 }
 
 // SetGlobalRouting installs a new log router with the input config
@@ -67,12 +167,22 @@ func (l *logger) JobFinishedEvent(payload string, didSucceed bool) {
 
 // CheckLatencyEvent logs the results of a latency check
 // to be log routed to SignalFx
-func (l *logger) CheckLatencyEvent(latencyErrValue int, fullTableName, reportedLatency, threshold string) {
-	l.log.GaugeIntD(checkLatency, latencyErrValue, M{
+func (l *logger) CheckLatencyEvent(latencyErrValue int, fullTableName, reportedLatency, threshold string, details Details) {
+	l.log.GaugeIntD(checkLatency, latencyErrValue, details.addTo(M{
 		"table":             fullTableName,
 		"latency":           reportedLatency,
 		"latency_threshold": threshold,
-	})
+	}))
+}
+
+// CheckLastWriteEvent logs the time since a table last
+// received rows, to be log routed to SignalFx
+func (l *logger) CheckLastWriteEvent(lastWriteErrValue int, fullTableName, reportedLastWrite, threshold string, details Details) {
+	l.log.GaugeIntD(checkLastWrite, lastWriteErrValue, details.addTo(M{
+		"table":             fullTableName,
+		"last_write":        reportedLastWrite,
+		"latency_threshold": threshold,
+	}))
 }
 
 // CheckLoadErrorEvent logs the results of a load error
@@ -83,3 +193,83 @@ func (l *logger) CheckLoadErrorEvent(loadErrValue int, loadErrors string) {
 		"errors": loadErrors,
 	})
 }
+
+// CheckCoverageEvent logs the number of schemas or tables in
+// a gap of a cluster's coverage, to be log routed to SignalFx
+func (l *logger) CheckCoverageEvent(cluster, gap string, items []string) {
+	l.log.GaugeIntD(checkCoverage, len(items), M{
+		"cluster": cluster,
+		"gap":     gap,
+		"items":   strings.Join(items, ", "),
+	})
+}
+
+// SchemaDriftEvent logs a column added to, removed from or retyped in a
+// table since its previous snapshot. Types are only logged if known.
+func (l *logger) SchemaDriftEvent(fullTableName, column, change, previousType, currentType string, details Details) {
+	data := M{
+		"table":  fullTableName,
+		"column": column,
+		"change": change,
+	}
+	if previousType != "" {
+		data["previous_type"] = previousType
+	}
+	if currentType != "" {
+		data["type"] = currentType
+	}
+	l.log.GaugeIntD(schemaDrift, 1, details.addTo(data))
+}
+
+// CheckTimestampColumnEvent logs whether the timestamp column of a
+// latency check is missing from its table, to be log routed to SignalFx
+func (l *logger) CheckTimestampColumnEvent(missingValue int, fullTableName, timestampColumn string, details Details) {
+	l.log.GaugeIntD(checkTimestampColumn, missingValue, details.addTo(M{
+		"table":            fullTableName,
+		"timestamp_column": timestampColumn,
+	}))
+}
+
+// CheckFutureTimestampEvent logs whether the most recent timestamp of a
+// table is further in the future than its tolerance, to be log routed
+// to SignalFx
+func (l *logger) CheckFutureTimestampEvent(futureValue int, fullTableName, maxTimestamp, tolerance string, details Details) {
+	l.log.GaugeIntD(checkFutureTimestamp, futureValue, details.addTo(M{
+		"table":            fullTableName,
+		"max_timestamp":    maxTimestamp,
+		"future_tolerance": tolerance,
+	}))
+}
+
+// CheckNullFractionEvent logs whether the fraction of a column's recent
+// rows that are null exceeds its maximum, to be log routed to SignalFx
+func (l *logger) CheckNullFractionEvent(nullValue int, fullTableName, column, nullFraction, maxNullFraction string, details Details) {
+	l.log.GaugeIntD(checkNullFraction, nullValue, details.addTo(M{
+		"table":             fullTableName,
+		"column":            column,
+		"null_fraction":     nullFraction,
+		"max_null_fraction": maxNullFraction,
+	}))
+}
+
+// CheckDistinctCountEvent logs whether a column's recent rows have fewer
+// distinct values than its minimum, to be log routed to SignalFx
+func (l *logger) CheckDistinctCountEvent(distinctValue int, fullTableName, column, distinctCount, minDistinct string, details Details) {
+	l.log.GaugeIntD(checkDistinctCount, distinctValue, details.addTo(M{
+		"table":          fullTableName,
+		"column":         column,
+		"distinct_count": distinctCount,
+		"min_distinct":   minDistinct,
+	}))
+}
+
+// CheckAllowedValuesEvent logs whether any of a column's recent rows have a
+// value it isn't allowed, to be log routed to SignalFx
+func (l *logger) CheckAllowedValuesEvent(disallowedValue int, fullTableName, column, disallowedRows string, allowedValues []string, details Details) {
+	l.log.GaugeIntD(checkAllowedValues, disallowedValue, details.addTo(M{
+		"table":           fullTableName,
+		"column":          column,
+		"disallowed_rows": disallowedRows,
+		"allowed_values":  strings.Join(allowedValues, ", "),
+	}))
+}
@@ -85,7 +85,48 @@ func TestCheckLatency(t *testing.T) {
 		mocklog := kvLogger.NewMockCountLogger("analytics-monitor")
 		defaultLog.log = mocklog // Overrides package level logger
 
-		defaultLog.CheckLatencyEvent(test.errValue, test.tableName, test.latency, test.latencyThreshold)
+		defaultLog.CheckLatencyEvent(test.errValue, test.tableName, test.latency, test.latencyThreshold, Details{})
+		counts := mocklog.RuleCounts()
+
+		assert.Equal(counts[test.rule], 1)
+	}
+}
+
+// TestCheckLastWrite verifies that CheckLastWriteEvent
+// log routes to the 'check-last-write' rule
+func TestCheckLastWrite(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		rule      string
+		errValue  int
+		tableName string
+		lastWrite string
+		threshold string
+	}{
+		{
+			rule:      "check-last-write",
+			errValue:  0,
+			tableName: "mongo.districts",
+			lastWrite: "1h",
+			threshold: "3h",
+		},
+		{
+			rule:      "check-last-write",
+			errValue:  1,
+			tableName: "mongo.districts",
+			lastWrite: "N/A - no recent writes",
+			threshold: "3h",
+		},
+	}
+
+	for _, test := range tests {
+		t.Logf("Routing rule %s", test.rule)
+
+		mocklog := kvLogger.NewMockCountLogger("analytics-monitor")
+		defaultLog.log = mocklog // Overrides package level logger
+
+		defaultLog.CheckLastWriteEvent(test.errValue, test.tableName, test.lastWrite, test.threshold, Details{})
 		counts := mocklog.RuleCounts()
 
 		assert.Equal(counts[test.rule], 1)
@@ -127,3 +168,129 @@ func TestCheckLoadError(t *testing.T) {
 		assert.Equal(counts[test.rule], 1)
 	}
 }
+
+// TestCheckCoverage verifies that CheckCoverageEvent
+// log routes to the 'check-coverage' rule
+func TestCheckCoverage(t *testing.T) {
+	assert := assert.New(t)
+
+	mocklog := kvLogger.NewMockCountLogger("analytics-monitor")
+	defaultLog.log = mocklog // Overrides package level logger
+
+	defaultLog.CheckCoverageEvent("mockClusterName", "no_timestamp_column", []string{"mongo.lookup"})
+	defaultLog.CheckCoverageEvent("mockClusterName", "omitted", nil)
+	counts := mocklog.RuleCounts()
+
+	assert.Equal(2, counts["check-coverage"])
+}
+
+// TestSchemaDrift verifies that SchemaDriftEvent and
+// CheckTimestampColumnEvent log route to their rules
+func TestSchemaDrift(t *testing.T) {
+	assert := assert.New(t)
+
+	mocklog := kvLogger.NewMockCountLogger("analytics-monitor")
+	defaultLog.log = mocklog // Overrides package level logger
+
+	defaultLog.SchemaDriftEvent("mongo.districts", "id", "retyped", "integer", "bigint", Details{})
+	defaultLog.SchemaDriftEvent("mongo.districts", "name", "removed", "text", "", Details{})
+	defaultLog.CheckTimestampColumnEvent(1, "mongo.districts", "updated_at", Details{})
+	counts := mocklog.RuleCounts()
+
+	assert.Equal(2, counts["schema-drift"])
+	assert.Equal(1, counts["check-timestamp-column"])
+}
+
+// TestCheckFutureTimestamp verifies that CheckFutureTimestampEvent
+// log routes to the 'check-future-timestamp' rule
+func TestCheckFutureTimestamp(t *testing.T) {
+	assert := assert.New(t)
+
+	mocklog := kvLogger.NewMockCountLogger("analytics-monitor")
+	defaultLog.log = mocklog // Overrides package level logger
+
+	defaultLog.CheckFutureTimestampEvent(1, "mongo.districts", "2099-01-01T00:00:00Z", "1h", Details{})
+	counts := mocklog.RuleCounts()
+
+	assert.Equal(1, counts["check-future-timestamp"])
+}
+
+// TestColumnQuality verifies that the column quality events log route
+// to their rules
+func TestColumnQuality(t *testing.T) {
+	assert := assert.New(t)
+
+	mocklog := kvLogger.NewMockCountLogger("analytics-monitor")
+	defaultLog.log = mocklog // Overrides package level logger
+
+	defaultLog.CheckNullFractionEvent(1, "mongo.districts", "name", "0.75", "0.1", Details{})
+	defaultLog.CheckDistinctCountEvent(0, "mongo.districts", "state", "3", "2", Details{})
+	defaultLog.CheckAllowedValuesEvent(1, "mongo.districts", "state", "12", []string{"active", "archived"}, Details{})
+	counts := mocklog.RuleCounts()
+
+	assert.Equal(1, counts["check-null-fraction"])
+	assert.Equal(1, counts["check-distinct-count"])
+	assert.Equal(1, counts["check-allowed-values"])
+}
+
+// TestOwnershipDimensions verifies that only the ownership
+// fields that are set are added to check events
+func TestOwnershipDimensions(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(M{"table": "mongo.districts"}, Ownership{}.addTo(M{"table": "mongo.districts"}))
+	assert.Equal(M{
+		"table":       "mongo.districts",
+		"team":        "data-eng",
+		"runbook_url": "https://runbooks/districts",
+	}, Ownership{Team: "data-eng", RunbookURL: "https://runbooks/districts"}.addTo(M{"table": "mongo.districts"}))
+}
+
+// TestDetailsDimensions verifies that a learned baseline
+// is added to check events alongside ownership
+func TestDetailsDimensions(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(M{
+		"table":           "mongo.districts",
+		"owner":           "sam",
+		"baseline":        "6h0m0s",
+		"baseline_source": "history",
+	}, Details{
+		Ownership:      Ownership{Owner: "sam"},
+		Baseline:       "6h0m0s",
+		BaselineSource: "history",
+	}.addTo(M{"table": "mongo.districts"}))
+}
+
+// TestDependencyDimensions verifies that suppressed breaches name their
+// root cause and dependency chain, and root causes their downstream tables
+func TestDependencyDimensions(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(M{
+		"table":            "reports.usage",
+		"suppressed":       "upstream mongo.districts stale",
+		"dependency_chain": "mongo.districts -> derived.district_stats -> reports.usage",
+	}, Details{
+		DependencyChain: []string{"mongo.districts", "derived.district_stats", "reports.usage"},
+	}.addTo(M{"table": "reports.usage"}))
+	assert.Equal(M{
+		"table":      "mongo.districts",
+		"downstream": "derived.district_stats, reports.usage",
+	}, Details{
+		Downstream: []string{"derived.district_stats", "reports.usage"},
+	}.addTo(M{"table": "mongo.districts"}))
+}
+
+// TestSilencedDimensions verifies that silenced breaches give the silence's reason
+func TestSilencedDimensions(t *testing.T) {
+	assert.Equal(t, M{"table": "mongo.districts", "silenced": "districts backfill"},
+		Details{Silenced: "districts backfill"}.addTo(M{"table": "mongo.districts"}))
+}
+
+// TestGroupDimensions verifies that grouped checks give the group checked
+func TestGroupDimensions(t *testing.T) {
+	assert.Equal(t, M{"table": "mongo.events", "group": "sis"},
+		Details{Group: "sis"}.addTo(M{"table": "mongo.events"}))
+}
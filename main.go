@@ -2,88 +2,157 @@ package main
 
 import (
 	"encoding/json"
-	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
-	"path"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/kardianos/osext"
-
 	"github.com/Clever/analytics-monitor/config"
 	"github.com/Clever/analytics-monitor/db"
 	l "github.com/Clever/analytics-monitor/logger"
+	"github.com/Clever/analytics-monitor/objectstore"
+	"github.com/Clever/analytics-monitor/store"
 )
 
 var (
-	latencyConfigPath    string
-	logger               l.Logger
-	globalDefaultLatency string
+	logger l.Logger = l.GetLogger()
+
+	// jobPayload identifies the run in job-finished events
+	jobPayload string
+
+	// history stores check results, if a history store is configured
+	history *store.Store
+	// currentRun collects the results of the run in progress
+	currentRun *checkRun
+
+	// commands are subcommands, selected by the first argument.
+	// Anything else (such as a JSON payload) runs the checks.
+	commands = map[string]func(args []string, out io.Writer) error{
+		"coverage": coverageCommand,
+		"history":  historyCommand,
+		"report":   reportCommand,
+		"silence":  silenceCommand,
+	}
 )
 
 // Checks stores table checks in a nested map,
 // indexed by schema name and then table name
 type Checks map[string]map[string]config.TableCheck
 
-func init() {
-	logger = l.GetLogger()
+func main() {
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			if err := command(os.Args[2:], os.Stdout); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
 
-	// kvconfig.yml must live in the same directory as
-	// the executable file in order for log routing to work
-	dir, err := osext.ExecutableFolder()
+	opts, err := parseOptions(os.Args[1:])
 	if err != nil {
 		log.Fatal(err)
 	}
-	err = l.SetGlobalRouting(path.Join(dir, "kvconfig.yml"))
+
+	// Log routing must be set up before anything is logged
+	// in order for alerts to reach SignalFx
+	err = l.SetGlobalRouting(opts.kvconfigPath)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	latencyConfigPath = path.Join(dir, "config/example_config.json")
-	globalDefaultLatency = "24h"
+	if err := run(opts); err != nil {
+		log.Fatal(err)
+	}
 }
 
-func main() {
-	flag.Parse()
-	config.Parse()
-
-	defer logger.JobFinishedEvent(strings.Join(os.Args[1:], " "), true)
-
-	postgresConn, err := db.NewPostgresClient()
-	fatalIfErr(err, "postgres-failed-init")
-
-	configChecks := config.ParseChecks(latencyConfigPath)
+// run performs every configured check, once or, if opts.interval is set,
+// repeatedly until interrupted. It returns an error if a single run
+// couldn't complete a check, after logging the job as failed.
+func run(opts options) error {
+	configChecks := config.ParseChecks(opts.checksConfigPath)
+	fatalIfErr(config.Validate(configChecks), "invalid-checks-config")
+	config.Parse(configChecks.Type)
+
+	client, err := db.NewClient(configChecks.Type, opts.clusterName)
+	fatalIfErr(err, "client-failed-init")
+	defer client.Close()
+	// Fail fast on unreachable clusters and bad credentials,
+	// rather than when the first check queries the cluster
+	fatalIfErr(client.Ping(), "db-connect-failed")
+
+	if opts.historyURL != "" {
+		history, err = store.Open(opts.historyURL)
+		fatalIfErr(err, "history-failed-init")
+		defer func() {
+			history.Close()
+			history = nil
+		}()
+	}
 
-	postgresChecks := buildLatencyChecks(configChecks.PostgresChecks, postgresConn)
-	queryLatencyErrors := performLatencyChecks(postgresConn, postgresChecks)
+	if opts.interval > 0 {
+		return runForever(client, newCheckSet(opts.checksConfigPath, configChecks), opts)
+	}
+	return runChecks(client, configChecks, opts)
+}
 
-	performLoadErrorsCheck(postgresConn)
+// runChecks performs every check in configChecks once, logging the
+// job as finished. It returns an error if any check couldn't complete.
+func runChecks(client db.Client, configChecks config.Config, opts options) error {
+	jobPayload = strings.Join(opts.args, " ")
+	currentRun = newCheckRun()
+	currentRun.silences = loadSilences(configChecks, currentRun.startedAt)
+	defer writeHistory()
+
+	postgresChecks := buildLatencyChecks(configChecks.PostgresChecks, client, opts.defaultLatency)
+	missingColumnErrors := performSchemaDriftChecks(client, postgresChecks)
+	learnThresholds(client, postgresChecks, opts.defaultLatency)
+	queryLatencyErrors := performLatencyChecks(client, postgresChecks)
+	queryLatencyErrors = append(queryLatencyErrors, performColumnQualityChecks(client, postgresChecks)...)
+
+	if len(configChecks.ObjectChecks) > 0 {
+		objectClient, err := objectstore.NewS3Client()
+		fatalIfErr(err, "s3-failed-init")
+		queryLatencyErrors = append(queryLatencyErrors,
+			performObjectFreshnessChecks(objectClient, configChecks.ObjectChecks)...)
+	}
 
-	if len(queryLatencyErrors) > 0 {
-		var errStrs []string
-		for _, latencyErr := range queryLatencyErrors {
-			l.GetKVLogger().CriticalD("query-latency-error", l.M{"errors": latencyErr.Error()})
-			errStrs = append(errStrs, latencyErr.Error())
-		}
+	performLoadErrorsCheck(client)
+	performCoverageCheck(client, configChecks.PostgresChecks)
 
-		logger.JobFinishedEvent(strings.Join(os.Args[1:], " "), false)
-		log.Fatalf("Encountered fatal error querying for latency: %s", strings.Join(errStrs, ","))
+	var errStrs []string
+	for _, missingErr := range missingColumnErrors {
+		l.GetKVLogger().CriticalD("missing-timestamp-column", l.M{"error": missingErr.Error()})
+		errStrs = append(errStrs, missingErr.Error())
+	}
+	for _, latencyErr := range queryLatencyErrors {
+		l.GetKVLogger().CriticalD("query-latency-error", l.M{"errors": latencyErr.Error()})
+		errStrs = append(errStrs, latencyErr.Error())
+	}
+	if len(errStrs) > 0 {
+		logger.JobFinishedEvent(jobPayload, false)
+		return fmt.Errorf("Encountered fatal error checking latency: %s", strings.Join(errStrs, ","))
 	}
+
+	logger.JobFinishedEvent(jobPayload, true)
+	return nil
 }
 
-// fatalIfErr logs a critical error. Assumes logger is initialized
+// fatalIfErr logs a critical error and the job as failed, then panics.
+// Long-running monitors recover, failing only the run. Assumes logger is
+// initialized
 func fatalIfErr(err error, title string) {
 	if err != nil {
-		logger.JobFinishedEvent(strings.Join(os.Args[1:], " "), false)
+		logger.JobFinishedEvent(jobPayload, false)
 		l.GetKVLogger().CriticalD(title, l.M{"error": err.Error()})
 		panic(fmt.Sprintf("Encountered fatal error: %s", err.Error()))
 	}
 }
 
-// buildLatencyChecks constructs the latency checks for a given postgres instance
+// buildLatencyChecks constructs the latency checks for a given cluster
 // Each check can either be declared explicitly (by specifying table latency
 // in schemaConfigs), or implicitly (by falling back on the default latency
 // values specified at the schema level).
@@ -91,20 +160,18 @@ func fatalIfErr(err error, title string) {
 // Returns: a map of checks for each cluster.
 // Each map of checks is indexed by cluster name, then table name.
 // Each check (see: config.TableCheck) contains:
-// A.) Latency threshold as a duration string
+// A.) Latency threshold as a duration string, or auto
 // B.) Name of the timestamp column
-func buildLatencyChecks(schemaConfigs []config.SchemaConfig, postgresClient db.PostgresClient) Checks {
+func buildLatencyChecks(schemaConfigs []config.SchemaConfig, client db.Client, globalDefaultLatency string) Checks {
 	checks := make(Checks)
 
 	for _, schemaConfig := range schemaConfigs {
 		schemaName := schemaConfig.SchemaName
+		defaultBoundToNow := schemaConfig.DefaultBoundToNow
 		checks[schemaName] = make(map[string]config.TableCheck)
 
-		tableMetadata, err := postgresClient.QueryTableMetadata(schemaName)
-		if err != nil {
-			l.GetKVLogger().CriticalD("query-table-metadata-error", l.M{"error": err.Error()})
-			panic("Unable to query table metadata")
-		}
+		tableMetadata, err := client.QueryTableMetadata(schemaName)
+		fatalIfErr(err, "query-table-metadata-error")
 
 		for tableName, metadata := range tableMetadata {
 			// Use inferred timestamp column if not specified in schema default
@@ -120,24 +187,65 @@ func buildLatencyChecks(schemaConfigs []config.SchemaConfig, postgresClient db.P
 			}
 
 			checks[schemaName][tableName] = config.TableCheck{
+				Ownership: schemaConfig.Ownership,
 				TableName: tableName,
 				Latency: config.LatencyInfo{
 					TimestampColumn: timestampColumn,
 					Threshold:       defaultThreshold,
+					FreshnessMode:   schemaConfig.DefaultFreshnessMode,
+					Auto:            schemaConfig.DefaultAuto,
+					FutureTolerance: schemaConfig.DefaultFutureTolerance,
+					BoundToNow:      &defaultBoundToNow,
 				},
+				DependsOn: schemaConfig.DependsOn,
 			}
 		}
 
 		// Override per-schema thresholds if specified in config
+		var columns map[string][]db.Column
 		for _, configCheck := range schemaConfig.Checks {
 			tableName := configCheck.TableName
-			if _, ok := checks[schemaName][configCheck.TableName]; ok {
+			_, ok := checks[schemaName][tableName]
+			if !ok && configCheck.Latency.TimestampColumn != "" {
+				// Tables whose only timestamp column was dropped have no
+				// metadata, but are still checked so the missing column
+				// is reported
+				if columns == nil {
+					columns, err = client.QueryColumns(schemaName)
+					fatalIfErr(err, "query-columns-error")
+				}
+				_, ok = columns[tableName]
+			}
+			if ok {
+				// Use schema default freshness mode if not specified for the table
+				freshnessMode := configCheck.Latency.FreshnessMode
+				if freshnessMode == "" {
+					freshnessMode = schemaConfig.DefaultFreshnessMode
+				}
+				futureTolerance := configCheck.Latency.FutureTolerance
+				if futureTolerance == "" {
+					futureTolerance = schemaConfig.DefaultFutureTolerance
+				}
+				boundToNow := configCheck.Latency.BoundToNow
+				if boundToNow == nil {
+					boundToNow = &defaultBoundToNow
+				}
+
 				checks[schemaName][tableName] = config.TableCheck{
+					Ownership: configCheck.Ownership.Inherit(schemaConfig.Ownership),
 					TableName: tableName,
 					Latency: config.LatencyInfo{
 						TimestampColumn: configCheck.Latency.TimestampColumn,
 						Threshold:       configCheck.Latency.Threshold,
+						FreshnessMode:   freshnessMode,
+						Auto:            configCheck.Latency.Auto.Inherit(schemaConfig.DefaultAuto),
+						FutureTolerance: futureTolerance,
+						BoundToNow:      boundToNow,
+						Filter:          configCheck.Latency.Filter,
+						GroupBy:         configCheck.Latency.GroupBy,
 					},
+					DependsOn: append(append([]string(nil), schemaConfig.DependsOn...), configCheck.DependsOn...),
+					Quality:   configCheck.Quality,
 				}
 			} else {
 				l.GetKVLogger().WarnD("missing-table-in-db", l.M{
@@ -146,6 +254,13 @@ func buildLatencyChecks(schemaConfigs []config.SchemaConfig, postgresClient db.P
 			}
 		}
 
+		for tableName, check := range checks[schemaName] {
+			if !config.ValidFreshnessMode(check.Latency.FreshnessMode) {
+				fatalIfErr(fmt.Errorf("%s.%s: unknown freshness mode %q",
+					schemaName, tableName, check.Latency.FreshnessMode), "invalid-freshness-mode")
+			}
+		}
+
 		// Finally, omit latency checks for specified tables
 		for _, tableToOmit := range schemaConfig.TablesToOmit {
 			if _, ok := checks[schemaName][tableToOmit]; ok {
@@ -163,8 +278,28 @@ func buildLatencyChecks(schemaConfigs []config.SchemaConfig, postgresClient db.P
 	return checks
 }
 
-func performLoadErrorsCheck(postgresClient db.PostgresClient) {
-	loadErrors, err := postgresClient.QuerySTLLoadErrors()
+// skipUnsupportedCheck logs and returns true if the client's
+// dialect can't run the given type of check
+func skipUnsupportedCheck(client db.Client, check db.CheckType) bool {
+	dialect := client.Dialect()
+	if dialect.Supports(check) {
+		return false
+	}
+
+	l.GetKVLogger().InfoD("check-unsupported", l.M{
+		"cluster": client.GetClusterName(),
+		"dialect": dialect.Name(),
+		"check":   string(check),
+	})
+	return true
+}
+
+func performLoadErrorsCheck(client db.Client) {
+	if skipUnsupportedCheck(client, db.CheckLoadErrors) {
+		return
+	}
+
+	loadErrors, err := client.QuerySTLLoadErrors()
 	if err != nil {
 		log.Printf("Error with client performing load error check: %v.\n", err)
 	} else {
@@ -181,36 +316,390 @@ func performLoadErrorsCheck(postgresClient db.PostgresClient) {
 	}
 }
 
-func performLatencyChecks(postgresClient db.PostgresClient, checks Checks) []error {
+// latencyOutcome is the result of a table's latency or last write check,
+// or of one group of a grouped check, before it's logged
+type latencyOutcome struct {
+	schemaName string
+	tableName  string
+	// group is the value of the group_by column, if the check is grouped
+	group    string
+	check    config.TableCheck
+	result   db.LatencyResult
+	breached bool
+	// future is set if the table's most recent timestamp is
+	// further in the future than its tolerance
+	future bool
+}
+
+// performLatencyChecks queries the latency of every check, one batch
+// per schema, and when tables in last write or both freshness modes were
+// last written, then logs the result of each against its threshold.
+// Breaches caused by a stale upstream table, by either measure, are
+// suppressed, so that only root causes alert. Timestamps further in the
+// future than a check's tolerance are reported separately, and ignored when
+// computing latency if the check is bounded to now. Grouped checks are
+// queried on their own and logged per group; a table is stale if any of
+// its groups is. Returns the errors of any queries that failed.
+func performLatencyChecks(client db.Client, checks Checks) []error {
+	clusterName := client.GetClusterName()
+	outcomes, queryErrors := queryLatencyOutcomes(client, checks)
+	lastWriteOutcomes, lastWriteErrors := queryLastWriteOutcomes(client, checks)
+	queryErrors = append(queryErrors, lastWriteErrors...)
+
+	stale := make(map[string]bool)
+	for _, outcome := range append(outcomes, lastWriteOutcomes...) {
+		if outcome.breached {
+			stale[outcome.schemaName+"."+outcome.tableName] = true
+		}
+	}
+	chains := staleChains(dependencyGraph(checks), stale)
+	downstream := downstreamOf(chains)
+
+	for _, outcome := range outcomes {
+		logLatencyOutcome(clusterName, outcome, chains, downstream)
+	}
+	for _, outcome := range lastWriteOutcomes {
+		logLastWriteOutcome(clusterName, outcome, chains, downstream)
+	}
+
+	return queryErrors
+}
+
+// queryLatencyOutcomes queries the latency of every check in data timestamp
+// or both freshness modes, returning the errors of any queries that failed
+func queryLatencyOutcomes(client db.Client, checks Checks) ([]latencyOutcome, []error) {
+	if skipUnsupportedCheck(client, db.CheckLatency) {
+		return nil, nil
+	}
+
 	var queryLatencyErrors []error
-	clusterName := postgresClient.GetClusterName()
+	var outcomes []latencyOutcome
+	clusterName := client.GetClusterName()
+	now := time.Now()
 
 	for schemaName, tableChecks := range checks {
+		thresholds := make(map[string]time.Duration)
+		tolerances := make(map[string]time.Duration)
+		var requests []db.LatencyRequest
 		for tableName, check := range tableChecks {
+			if !check.Latency.ChecksDataTimestamp() {
+				continue
+			}
 			threshold, err := time.ParseDuration(check.Latency.Threshold)
 			fatalIfErr(err, "parse-duration-error")
+			thresholds[tableName] = threshold
+			tolerance, err := check.Latency.FutureToleranceDuration()
+			fatalIfErr(err, "parse-duration-error")
+			tolerances[tableName] = tolerance
 
-			latencyHrs, hasRows, err := postgresClient.QueryLatency(check.Latency.TimestampColumn,
-				schemaName, tableName)
+			request := db.LatencyRequest{
+				TableName:       tableName,
+				TimestampColumn: check.Latency.TimestampColumn,
+				Filter:          dbFilter(check.Latency.Filter),
+				GroupBy:         check.Latency.GroupBy,
+			}
+			if check.Latency.IsBoundToNow() {
+				request.BoundedBy = now.Add(tolerance)
+			}
+			if !check.Latency.IsGrouped() {
+				requests = append(requests, request)
+				continue
+			}
+
+			groupResults, err := client.QueryGroupLatencies(schemaName, request)
+			if err != nil {
+				groupResults = map[string]db.LatencyResult{"": {Err: err}}
+			} else if len(groupResults) == 0 {
+				// Without rows there are no groups, but the table is still stale
+				groupResults = map[string]db.LatencyResult{"": {}}
+			}
+			for group, result := range groupResults {
+				outcome, err := newLatencyOutcome(clusterName, schemaName, tableName, check, group, result,
+					threshold, now.Add(tolerance))
+				if err != nil {
+					queryLatencyErrors = append(queryLatencyErrors, err)
+					continue
+				}
+				outcomes = append(outcomes, outcome)
+			}
+		}
+
+		results := client.QueryLatencies(schemaName, requests)
+		for tableName, check := range tableChecks {
+			result, ok := results[tableName]
+			if !ok {
+				continue
+			}
+			outcome, err := newLatencyOutcome(clusterName, schemaName, tableName, check, "", result,
+				thresholds[tableName], now.Add(tolerances[tableName]))
 			if err != nil {
 				queryLatencyErrors = append(queryLatencyErrors, err)
 				continue
 			}
+			outcomes = append(outcomes, outcome)
+		}
+	}
+
+	return outcomes, queryLatencyErrors
+}
+
+// dbFilter converts a check's filter for the db package, or returns nil
+// if it has none
+func dbFilter(filter *config.Filter) *db.Filter {
+	if filter == nil {
+		return nil
+	}
+	return &db.Filter{Column: filter.Column, Operator: filter.Operator, Values: filter.Values}
+}
+
+// logLatencyOutcome logs and records a latency outcome, and any future
+// timestamps it found
+func logLatencyOutcome(clusterName string, outcome latencyOutcome, chains, downstream map[string][]string) {
+	result := outcome.result
+	details := checkDetails(outcome.schemaName, outcome.tableName, outcome.check.Ownership)
+	details.Group = outcome.group
+	latencyErrValue, status := freshnessAlertValue(clusterName, outcome, chains, downstream, &details)
+
+	reportedLatency := fmt.Sprintf("%sh", strconv.FormatInt(result.LatencyHrs, 10))
+	if !result.HasRows {
+		reportedLatency = "N/A - no rows"
+	}
+
+	fullTableName := fmt.Sprintf("%s.%s.%s", clusterName, outcome.schemaName, outcome.tableName)
+	logger.CheckLatencyEvent(latencyErrValue, fullTableName, reportedLatency, outcome.check.Latency.Threshold, details)
+	if !result.UnboundedMaxTimestamp.IsZero() {
+		logFutureTimestamp(clusterName, outcome)
+	}
+	recordResult(store.Result{
+		Cluster:      clusterName,
+		Schema:       outcome.schemaName,
+		Table:        outcome.tableName,
+		Check:        string(db.CheckLatency),
+		Group:        outcome.group,
+		LatencyHrs:   result.LatencyHrs,
+		HasLatency:   result.HasRows,
+		MaxTimestamp: result.MaxTimestamp,
+		Threshold:    outcome.check.Latency.Threshold,
+		Status:       status,
+	})
+}
+
+// freshnessAlertValue is alertValue for latency and last write outcomes.
+// Breaches downstream of a stale table are suppressed, and root causes
+// list the stale tables downstream of them.
+func freshnessAlertValue(clusterName string, outcome latencyOutcome, chains, downstream map[string][]string,
+	details *l.Details) (int, store.Status) {
+	fullTableName := outcome.schemaName + "." + outcome.tableName
+	if chain, ok := chains[fullTableName]; ok && outcome.breached {
+		details.DependencyChain = chain
+		return 0, store.StatusSuppressed
+	}
+	value, status := alertValue(outcome.result.HasRows, outcome.breached, clusterName,
+		outcome.schemaName, outcome.tableName, details)
+	if value == 1 {
+		details.Downstream = downstream[fullTableName]
+	}
+	return value, status
+}
+
+// newLatencyOutcome compares the latency of a table, or of one group of it,
+// to its threshold and future tolerance. If the latency query failed, its
+// error is recorded and returned instead.
+func newLatencyOutcome(clusterName, schemaName, tableName string, check config.TableCheck, group string,
+	result db.LatencyResult, threshold time.Duration, futureBound time.Time) (latencyOutcome, error) {
+	if result.Err != nil {
+		recordResult(store.Result{
+			Cluster:   clusterName,
+			Schema:    schemaName,
+			Table:     tableName,
+			Check:     string(db.CheckLatency),
+			Group:     group,
+			Threshold: check.Latency.Threshold,
+			Status:    store.StatusError,
+		})
+		return latencyOutcome{}, result.Err
+	}
+
+	return latencyOutcome{
+		schemaName: schemaName,
+		tableName:  tableName,
+		group:      group,
+		check:      check,
+		result:     result,
+		breached:   !result.HasRows || float64(result.LatencyHrs) > threshold.Hours(),
+		future:     result.UnboundedMaxTimestamp.After(futureBound),
+	}, nil
+}
+
+// futureTimestampCheckType identifies future timestamp checks in check history
+const futureTimestampCheckType = "future_timestamp"
+
+// logFutureTimestamp logs and records whether a table's most recent
+// timestamp is further in the future than its tolerance. Silences apply,
+// but upstream tables being stale doesn't make bogus timestamps any less
+// bogus.
+func logFutureTimestamp(clusterName string, outcome latencyOutcome) {
+	details := checkDetails(outcome.schemaName, outcome.tableName, outcome.check.Ownership)
+	details.Group = outcome.group
+	futureValue, status := alertValue(true, outcome.future, clusterName, outcome.schemaName, outcome.tableName, &details)
+
+	tolerance := outcome.check.Latency.FutureTolerance
+	if tolerance == "" {
+		tolerance = config.DefaultFutureTolerance
+	}
+	fullTableName := fmt.Sprintf("%s.%s.%s", clusterName, outcome.schemaName, outcome.tableName)
+	logger.CheckFutureTimestampEvent(futureValue, fullTableName,
+		outcome.result.UnboundedMaxTimestamp.Format(time.RFC3339), tolerance, details)
+	recordResult(store.Result{
+		Cluster:      clusterName,
+		Schema:       outcome.schemaName,
+		Table:        outcome.tableName,
+		Check:        futureTimestampCheckType,
+		Group:        outcome.group,
+		MaxTimestamp: outcome.result.UnboundedMaxTimestamp,
+		Threshold:    tolerance,
+		Status:       status,
+	})
+}
+
+// queryLastWriteOutcomes queries, for every check in last write or both
+// freshness modes, the time since the table last received rows.
+// Each schema's system tables are only queried if one of its checks
+// needs them. Returns the errors of any queries that failed.
+func queryLastWriteOutcomes(client db.Client, checks Checks) ([]latencyOutcome, []error) {
+	if !anyChecksLastWrite(checks) || skipUnsupportedCheck(client, db.CheckLastWrite) {
+		return nil, nil
+	}
+
+	var queryErrors []error
+	var outcomes []latencyOutcome
+
+	for schemaName, tableChecks := range checks {
+		var lastWrites map[string]db.LatencyResult
+		for tableName, check := range tableChecks {
+			if !check.Latency.ChecksLastWrite() {
+				continue
+			}
 
-			latencyErrValue := 0
-			if !hasRows || float64(latencyHrs) > threshold.Hours() {
-				latencyErrValue = 1
+			threshold, err := time.ParseDuration(check.Latency.Threshold)
+			fatalIfErr(err, "parse-duration-error")
+
+			if lastWrites == nil {
+				lastWrites, err = client.QueryLastWrites(schemaName)
+				if err != nil {
+					queryErrors = append(queryErrors, err)
+					break
+				}
 			}
 
-			reportedLatency := fmt.Sprintf("%sh", strconv.FormatInt(latencyHrs, 10))
-			if !hasRows {
-				reportedLatency = "N/A - no rows"
+			// Tables missing from the results had no writes in the system
+			// tables' retention window, which is only stale if the
+			// threshold is shorter
+			result := lastWrites[tableName]
+			breached := float64(result.LatencyHrs) > threshold.Hours()
+			if !result.HasRows {
+				breached = threshold < db.LastWriteRetention
 			}
+			outcomes = append(outcomes, latencyOutcome{
+				schemaName: schemaName,
+				tableName:  tableName,
+				check:      check,
+				result:     result,
+				breached:   breached,
+			})
+		}
+	}
+
+	return outcomes, queryErrors
+}
 
-			fullTableName := fmt.Sprintf("%s.%s.%s", clusterName, schemaName, tableName)
-			logger.CheckLatencyEvent(latencyErrValue, fullTableName, reportedLatency, check.Latency.Threshold)
+// logLastWriteOutcome logs and records a last write outcome
+func logLastWriteOutcome(clusterName string, outcome latencyOutcome, chains, downstream map[string][]string) {
+	result := outcome.result
+	details := checkDetails(outcome.schemaName, outcome.tableName, outcome.check.Ownership)
+	lastWriteErrValue, status := freshnessAlertValue(clusterName, outcome, chains, downstream, &details)
+
+	reportedLastWrite := fmt.Sprintf("%sh", strconv.FormatInt(result.LatencyHrs, 10))
+	if !result.HasRows {
+		reportedLastWrite = "N/A - no recent writes"
+	}
+
+	fullTableName := fmt.Sprintf("%s.%s.%s", clusterName, outcome.schemaName, outcome.tableName)
+	logger.CheckLastWriteEvent(lastWriteErrValue, fullTableName, reportedLastWrite, outcome.check.Latency.Threshold, details)
+	recordResult(store.Result{
+		Cluster:    clusterName,
+		Schema:     outcome.schemaName,
+		Table:      outcome.tableName,
+		Check:      string(db.CheckLastWrite),
+		LatencyHrs: result.LatencyHrs,
+		HasLatency: result.HasRows,
+		Threshold:  outcome.check.Latency.Threshold,
+		Status:     status,
+	})
+}
+
+// anyChecksLastWrite reports whether any check uses the last write freshness mode
+func anyChecksLastWrite(checks Checks) bool {
+	for _, tableChecks := range checks {
+		for _, check := range tableChecks {
+			if check.Latency.ChecksLastWrite() {
+				return true
+			}
 		}
 	}
+	return false
+}
+
+// objectCheckType identifies object freshness checks in check history.
+// They aren't tied to a cluster or schema.
+const objectCheckType = "object"
+
+// performObjectFreshnessChecks checks the age of the newest object under each
+// configured prefix against its threshold. Results are logged like table
+// latency checks, with the s3:// path in place of the table name.
+// Returns the errors of any listings that failed.
+func performObjectFreshnessChecks(objectClient objectstore.Client, objectChecks []config.ObjectCheck) []error {
+	var listErrors []error
+
+	for _, check := range objectChecks {
+		threshold, err := time.ParseDuration(check.Threshold)
+		fatalIfErr(err, "parse-duration-error")
+
+		bucket, prefix, err := objectstore.ParsePath(check.Path)
+		fatalIfErr(err, "parse-object-path-error")
+
+		newest, hasObjects, err := objectClient.NewestObject(bucket, prefix)
+		if err != nil {
+			listErrors = append(listErrors, err)
+			recordResult(store.Result{
+				Table:     check.Path,
+				Check:     objectCheckType,
+				Threshold: check.Threshold,
+				Status:    store.StatusError,
+			})
+			continue
+		}
+
+		latencyHrs := int64(time.Since(newest).Hours())
+		details := l.Details{Ownership: l.Ownership(check.Ownership)}
+		breached := !hasObjects || float64(latencyHrs) > threshold.Hours()
+		latencyErrValue, status := alertValue(hasObjects, breached, "", "", check.Path, &details)
+
+		reportedLatency := fmt.Sprintf("%sh", strconv.FormatInt(latencyHrs, 10))
+		if !hasObjects {
+			reportedLatency = "N/A - no objects"
+		}
+
+		logger.CheckLatencyEvent(latencyErrValue, check.Path, reportedLatency, check.Threshold, details)
+		recordResult(store.Result{
+			Table:      check.Path,
+			Check:      objectCheckType,
+			LatencyHrs: latencyHrs,
+			HasLatency: hasObjects,
+			Threshold:  check.Threshold,
+			Status:     status,
+		})
+	}
 
-	return queryLatencyErrors
+	return listErrors
 }
@@ -1,37 +1,33 @@
 package main
 
 import (
+	"bytes"
+	"database/sql"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"log"
-	"path"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
-	"github.com/kardianos/osext"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/Clever/analytics-monitor/config"
 	"github.com/Clever/analytics-monitor/db"
+	l "github.com/Clever/analytics-monitor/logger"
+	"github.com/Clever/analytics-monitor/slo"
+	"github.com/Clever/analytics-monitor/store"
 )
 
-// Copy kvconfig.yml to exec dir to simulate main.init()
-// loading in a production Docker environment.
-// This syntax is used to run the setup before main.init()
-var _ = func() (_ struct{}) {
-	configContent, err := ioutil.ReadFile("kvconfig.yml")
+func init() {
+	err := l.SetGlobalRouting("kvconfig.yml")
 	if err != nil {
 		log.Fatal(err)
 	}
-	execDir, err := osext.ExecutableFolder()
-	if err != nil {
-		log.Fatal(err)
-	}
-	err = ioutil.WriteFile(path.Join(execDir, "kvconfig.yml"), configContent, 0777)
-	if err != nil {
-		log.Fatal(err)
-	}
-	return
-}()
+}
 
 type mockRedshiftClient struct {
 	latencyHrs    int64
@@ -39,29 +35,143 @@ type mockRedshiftClient struct {
 	queryErr      error
 	loadErrs      []db.LoadError
 	tableMetadata map[string]db.TableMetadata
+	lastWrites    map[string]db.LatencyResult
+	dialect       db.Dialect
+	// recentTimestamps are returned by QueryRecentTimestamps, which records
+	// the filter it's given, and maxTimestamp as the MaxTimestamp of every
+	// latency result
+	recentTimestamps []time.Time
+	recentFilter     *db.Filter
+	maxTimestamp     time.Time
+	// tables lists every table of each schema, with or without
+	// a timestamp column
+	tables map[string][]string
+	// columns are returned by QueryColumns for every schema
+	columns map[string][]db.Column
+	// futureTimestamp, if set, is the UnboundedMaxTimestamp of every
+	// latency result, and requests records every latency request
+	futureTimestamp time.Time
+	requests        []db.LatencyRequest
+	// groups are returned by QueryGroupLatencies for every grouped table
+	groups map[string]db.LatencyResult
+	// columnStats are returned by QueryColumnStats, by table
+	columnStats map[string][]db.ColumnStats
 }
 
 func (c *mockRedshiftClient) GetClusterName() string {
 	return "mockClusterName"
 }
 
+func (c *mockRedshiftClient) Dialect() db.Dialect {
+	if c.dialect == nil {
+		return db.Redshift
+	}
+	return c.dialect
+}
+
 func (c *mockRedshiftClient) QueryTableMetadata(schemaName string) (map[string]db.TableMetadata, error) {
 	return c.tableMetadata, c.queryErr
 }
 
+func (c *mockRedshiftClient) QueryTables(schemaName string) ([]string, error) {
+	return c.tables[schemaName], c.queryErr
+}
+
+func (c *mockRedshiftClient) QueryColumns(schemaName string) (map[string][]db.Column, error) {
+	return c.columns, c.queryErr
+}
+
+func (c *mockRedshiftClient) QuerySchemas() ([]string, error) {
+	var schemas []string
+	for schemaName := range c.tables {
+		schemas = append(schemas, schemaName)
+	}
+	return schemas, c.queryErr
+}
+
 func (c *mockRedshiftClient) QueryLatency(timestampColumn, schemaName, tableName string) (int64, bool, error) {
 	return c.latencyHrs, c.hasRows, c.queryErr
 }
 
+func (c *mockRedshiftClient) QueryLatencies(schemaName string, requests []db.LatencyRequest) map[string]db.LatencyResult {
+	results := make(map[string]db.LatencyResult)
+	for _, request := range requests {
+		c.requests = append(c.requests, request)
+		unboundedMaxTimestamp := c.maxTimestamp
+		if !c.futureTimestamp.IsZero() {
+			unboundedMaxTimestamp = c.futureTimestamp
+		}
+		results[request.TableName] = db.LatencyResult{
+			LatencyHrs:            c.latencyHrs,
+			HasRows:               c.hasRows,
+			MaxTimestamp:          c.maxTimestamp,
+			UnboundedMaxTimestamp: unboundedMaxTimestamp,
+			Err:                   c.queryErr,
+		}
+	}
+	return results
+}
+
+func (c *mockRedshiftClient) QueryGroupLatencies(schemaName string, request db.LatencyRequest) (map[string]db.LatencyResult, error) {
+	c.requests = append(c.requests, request)
+	return c.groups, c.queryErr
+}
+
+func (c *mockRedshiftClient) QueryColumnStats(schemaName, tableName, timestampColumn string, since time.Time,
+	requests []db.ColumnStatsRequest) ([]db.ColumnStats, error) {
+	return c.columnStats[tableName], c.queryErr
+}
+
+func (c *mockRedshiftClient) QueryRecentTimestamps(timestampColumn, schemaName, tableName string, filter *db.Filter,
+	since time.Time, limit int) ([]time.Time, error) {
+	c.recentFilter = filter
+	return c.recentTimestamps, c.queryErr
+}
+
+func (c *mockRedshiftClient) QueryLastWrites(schemaName string) (map[string]db.LatencyResult, error) {
+	return c.lastWrites, c.queryErr
+}
+
 func (c *mockRedshiftClient) QuerySTLLoadErrors() ([]db.LoadError, error) {
 	return c.loadErrs, c.queryErr
 }
 
+func (c *mockRedshiftClient) Ping() error {
+	return nil
+}
+
+func (c *mockRedshiftClient) Close() error {
+	return nil
+}
+
+type mockObjectClient struct {
+	newest     time.Time
+	hasObjects bool
+	listErr    error
+}
+
+func (c *mockObjectClient) NewestObject(bucket, prefix string) (time.Time, bool, error) {
+	return c.newest, c.hasObjects, c.listErr
+}
+
 type mockLogger struct {
 	assertions            *assert.Assertions
 	expectedLogValue      int
 	expectedLatencyReport string
 	expectedErrorsString  string
+	logCount              int
+	lastDetails           l.Details
+	// coverage records the items of each coverage gap logged
+	coverage map[string][]string
+	// drift records each schema drift event as "table column change",
+	// and missingTimestamps the value of each timestamp column event
+	drift             []string
+	missingTimestamps map[string]int
+	// futureTimestamps records the value of each future timestamp event
+	futureTimestamps map[string]int
+	// columnQuality records the value and observed metric of each column
+	// quality event as "value metric", by "table.column check"
+	columnQuality map[string]string
 }
 
 func (l *mockLogger) JobFinishedEvent(payload string, didSucceed bool) {
@@ -69,16 +179,212 @@ func (l *mockLogger) JobFinishedEvent(payload string, didSucceed bool) {
 	return
 }
 
-func (l *mockLogger) CheckLatencyEvent(latencyErrValue int, fullTableName, reportedLatency, threshold string) {
+func (l *mockLogger) CheckLatencyEvent(latencyErrValue int, fullTableName, reportedLatency, threshold string, details l.Details) {
+	l.logCount++
+	l.lastDetails = details
 	l.assertions.Equal(latencyErrValue, l.expectedLogValue, "Incorrect latency log value")
 	l.assertions.Equal(reportedLatency, l.expectedLatencyReport, "Mismatched latency report string")
 }
 
+func (l *mockLogger) CheckLastWriteEvent(lastWriteErrValue int, fullTableName, reportedLastWrite, threshold string, details l.Details) {
+	l.logCount++
+	l.assertions.Equal(lastWriteErrValue, l.expectedLogValue, "Incorrect last write log value")
+	l.assertions.Equal(reportedLastWrite, l.expectedLatencyReport, "Mismatched last write report string")
+}
+
 func (l *mockLogger) CheckLoadErrorEvent(loadErrValue int, loadErrors string) {
+	l.logCount++
 	l.assertions.Equal(loadErrValue, l.expectedLogValue, "Incorrect latency log value")
 	l.assertions.Equal(loadErrors, l.expectedErrorsString, "Mismatched load errors")
 }
 
+func (l *mockLogger) SchemaDriftEvent(fullTableName, column, change, previousType, currentType string, details l.Details) {
+	l.drift = append(l.drift, fmt.Sprintf("%s %s %s", fullTableName, column, change))
+}
+
+func (l *mockLogger) CheckTimestampColumnEvent(missingValue int, fullTableName, timestampColumn string, details l.Details) {
+	if l.missingTimestamps == nil {
+		l.missingTimestamps = make(map[string]int)
+	}
+	l.missingTimestamps[fullTableName] = missingValue
+}
+
+func (l *mockLogger) CheckFutureTimestampEvent(futureValue int, fullTableName, maxTimestamp, tolerance string, details l.Details) {
+	if l.futureTimestamps == nil {
+		l.futureTimestamps = make(map[string]int)
+	}
+	l.futureTimestamps[fullTableName] = futureValue
+}
+
+func (l *mockLogger) CheckNullFractionEvent(nullValue int, fullTableName, column, nullFraction, maxNullFraction string, details l.Details) {
+	l.recordColumnQuality(nullValue, fullTableName, column, "null_fraction", nullFraction)
+}
+
+func (l *mockLogger) CheckDistinctCountEvent(distinctValue int, fullTableName, column, distinctCount, minDistinct string, details l.Details) {
+	l.recordColumnQuality(distinctValue, fullTableName, column, "distinct_count", distinctCount)
+}
+
+func (l *mockLogger) CheckAllowedValuesEvent(disallowedValue int, fullTableName, column, disallowedRows string, allowedValues []string, details l.Details) {
+	l.recordColumnQuality(disallowedValue, fullTableName, column, "allowed_values", disallowedRows)
+}
+
+func (l *mockLogger) recordColumnQuality(value int, fullTableName, column, check, metric string) {
+	if l.columnQuality == nil {
+		l.columnQuality = make(map[string]string)
+	}
+	l.columnQuality[fmt.Sprintf("%s.%s %s", fullTableName, column, check)] = fmt.Sprintf("%d %s", value, metric)
+}
+
+func (l *mockLogger) CheckCoverageEvent(cluster, gap string, items []string) {
+	if l.coverage == nil {
+		l.coverage = make(map[string][]string)
+	}
+	l.coverage[gap] = items
+}
+
+// TestBuildLatencyChecks verifies that table checks inherit their ownership,
+// auto threshold settings, dependencies and future timestamp settings from
+// their schema
+func TestBuildLatencyChecks(t *testing.T) {
+	assertions := assert.New(t)
+	notBounded := false
+
+	client := &mockRedshiftClient{
+		tableMetadata: map[string]db.TableMetadata{
+			"districts": {TableName: "districts", TimestampColumn: "_data_timestamp"},
+			"schools":   {TableName: "schools", TimestampColumn: "_data_timestamp"},
+		},
+	}
+	schemaConfigs := []config.SchemaConfig{
+		{
+			Ownership:              config.Ownership{Team: "data-eng", RunbookURL: "https://runbooks/mongo"},
+			SchemaName:             "mongo",
+			DefaultAuto:            config.AutoThreshold{Multiplier: 3},
+			DependsOn:              []string{"raw"},
+			DefaultFutureTolerance: "30m",
+			DefaultBoundToNow:      true,
+			Checks: []config.TableCheck{
+				{
+					Ownership: config.Ownership{Owner: "sam", Team: "districts"},
+					TableName: "districts",
+					DependsOn: []string{"mongo.schools"},
+					Latency: config.LatencyInfo{
+						TimestampColumn: "updated_at",
+						Threshold:       config.ThresholdAuto,
+						Auto:            config.AutoThreshold{Percentile: 50},
+						BoundToNow:      &notBounded,
+						Filter:          &config.Filter{Column: "deleted", Operator: config.FilterEquals, Values: []string{"false"}},
+					},
+					Quality: config.QualityInfo{Columns: []config.ColumnCheck{{Column: "name", MinDistinct: 2}}},
+				},
+			},
+		},
+	}
+
+	checks := buildLatencyChecks(schemaConfigs, client, "24h")
+	assertions.Equal(
+		config.Ownership{Owner: "sam", Team: "districts", RunbookURL: "https://runbooks/mongo"},
+		checks["mongo"]["districts"].Ownership)
+	assertions.Equal(
+		config.Ownership{Team: "data-eng", RunbookURL: "https://runbooks/mongo"},
+		checks["mongo"]["schools"].Ownership)
+	assertions.Equal(config.AutoThreshold{Multiplier: 3, Percentile: 50}, checks["mongo"]["districts"].Latency.Auto)
+	assertions.Equal(config.AutoThreshold{Multiplier: 3}, checks["mongo"]["schools"].Latency.Auto)
+	assertions.Equal([]string{"raw", "mongo.schools"}, checks["mongo"]["districts"].DependsOn)
+	assertions.Equal([]string{"raw"}, checks["mongo"]["schools"].DependsOn)
+	assertions.Equal("30m", checks["mongo"]["districts"].Latency.FutureTolerance)
+	assertions.Equal("30m", checks["mongo"]["schools"].Latency.FutureTolerance)
+	assertions.False(checks["mongo"]["districts"].Latency.IsBoundToNow())
+	assertions.True(checks["mongo"]["schools"].Latency.IsBoundToNow())
+	assertions.Equal(&config.Filter{Column: "deleted", Operator: config.FilterEquals, Values: []string{"false"}},
+		checks["mongo"]["districts"].Latency.Filter)
+	assertions.Nil(checks["mongo"]["schools"].Latency.Filter)
+	assertions.Equal([]config.ColumnCheck{{Column: "name", MinDistinct: 2}}, checks["mongo"]["districts"].Quality.Columns)
+}
+
+// TestLearnThresholds verifies that auto thresholds are learned from
+// history, then from the table's load timestamps, then fall back to the default
+func TestLearnThresholds(t *testing.T) {
+	assertions := assert.New(t)
+
+	var err error
+	history, err = store.Open("sqlite:" + filepath.Join(t.TempDir(), "monitor.db"))
+	require.NoError(t, err)
+	currentRun = newCheckRun()
+	defer func() {
+		history.Close()
+		history = nil
+		currentRun = nil
+	}()
+
+	// districts was seen to update every 6 hours by previous runs
+	now := time.Now().UTC().Truncate(time.Hour)
+	var results []store.Result
+	for i := 1; i <= 24; i++ {
+		results = append(results, store.Result{
+			RunID:        fmt.Sprintf("run-%d", i),
+			CheckedAt:    now.Add(-time.Duration(i) * time.Hour),
+			Cluster:      "mockClusterName",
+			Schema:       "mongo",
+			Table:        "districts",
+			Check:        string(db.CheckLatency),
+			MaxTimestamp: now.Add(-time.Duration(i/6*6) * time.Hour),
+			Threshold:    "2h",
+			Status:       store.StatusOK,
+		})
+	}
+	require.NoError(t, history.RecordResults(results))
+
+	loadTimestamps := true
+	autoCheck := func(tableName string) config.TableCheck {
+		return config.TableCheck{
+			TableName: tableName,
+			Latency: config.LatencyInfo{
+				TimestampColumn: "_data_timestamp",
+				Threshold:       config.ThresholdAuto,
+				Auto:            config.AutoThreshold{LoadTimestamps: &loadTimestamps},
+			},
+		}
+	}
+	checks := Checks{"mongo": {
+		"districts": autoCheck("districts"),
+		"schools":   autoCheck("schools"),
+		"fixed":     {TableName: "fixed", Latency: config.LatencyInfo{Threshold: "3h"}},
+	}}
+	// schools has only been checked once, but is loaded every 3 hours
+	client := &mockRedshiftClient{recentTimestamps: []time.Time{
+		now, now.Add(-3 * time.Hour), now.Add(-6 * time.Hour), now.Add(-9 * time.Hour),
+	}}
+
+	t.Logf("Testing that cadences are learned from history, or else load timestamps")
+	learnThresholds(client, checks, "24h")
+	assertions.Equal("12h", checks["mongo"]["districts"].Latency.Threshold)
+	assertions.Equal("6h", checks["mongo"]["schools"].Latency.Threshold)
+	assertions.Equal("3h", checks["mongo"]["fixed"].Latency.Threshold)
+	assertions.Equal(l.Details{Baseline: "6h0m0s", BaselineSource: "history"}, checkDetails("mongo", "districts", config.Ownership{}))
+	assertions.Equal(l.Details{Baseline: "3h0m0s", BaselineSource: "timestamps"}, checkDetails("mongo", "schools", config.Ownership{}))
+	assertions.Equal(l.Details{}, checkDetails("mongo", "fixed", config.Ownership{}))
+
+	t.Logf("Testing that load timestamps are only counted on rows matching the filter")
+	filtered := autoCheck("schools")
+	filtered.Latency.Filter = &config.Filter{Column: "source", Operator: config.FilterNotEquals, Values: []string{"test"}}
+	learnThresholds(client, Checks{"mongo": {"schools": filtered}}, "24h")
+	assertions.Equal(&db.Filter{Column: "source", Operator: config.FilterNotEquals, Values: []string{"test"}},
+		client.recentFilter)
+
+	t.Logf("Testing that event timestamps aren't learned from")
+	events := autoCheck("schools")
+	events.Latency.Auto.LoadTimestamps = nil
+	checks = Checks{"mongo": {"schools": events}}
+	learnThresholds(client, checks, "24h")
+	assertions.Equal("24h", checks["mongo"]["schools"].Latency.Threshold)
+
+	t.Logf("Testing that tables without enough updates fall back to the default")
+	checks = Checks{"mongo": {"schools": autoCheck("schools")}}
+	learnThresholds(&mockRedshiftClient{recentTimestamps: []time.Time{now}}, checks, "24h")
+	assertions.Equal("24h", checks["mongo"]["schools"].Latency.Threshold)
+}
+
 // TestPerformLatencyChecks tests the performLatencyChecks
 // function, mocking out latency results and verifying
 // that the correct results are being logged
@@ -185,6 +491,194 @@ func TestPerformLatencyChecks(t *testing.T) {
 	}
 }
 
+// TestPerformLastWriteChecks tests the last write checks of
+// performLatencyChecks, mocking out system table results and
+// verifying that the correct results are being logged
+func TestPerformLastWriteChecks(t *testing.T) {
+	assertions := assert.New(t)
+
+	tests := []struct {
+		title string
+
+		// Mocks out the results of QueryLastWrites
+		lastWrites map[string]db.LatencyResult
+		queryErr   error
+
+		// Mocks out the config
+		threshold     string
+		freshnessMode string
+
+		// Specifies what we expect to log (or error)
+		expectedLogValue       int
+		expectedLatencyReport  string
+		expectedErrorsReturned bool
+	}{
+		{
+			title:                 "logs a success value (0) when the last write is within threshold",
+			lastWrites:            map[string]db.LatencyResult{"mockTableName": {LatencyHrs: 1, HasRows: true}},
+			threshold:             "2h",
+			freshnessMode:         config.FreshnessModeLastWrite,
+			expectedLogValue:      0,
+			expectedLatencyReport: "1h",
+		},
+		{
+			title:                 "logs a failure value (1) when the last write exceeds threshold",
+			lastWrites:            map[string]db.LatencyResult{"mockTableName": {LatencyHrs: 3, HasRows: true}},
+			threshold:             "2h",
+			freshnessMode:         config.FreshnessModeBoth,
+			expectedLogValue:      1,
+			expectedLatencyReport: "3h",
+		},
+		{
+			title:                 "logs a failure value (1) when the table has no recent writes",
+			lastWrites:            map[string]db.LatencyResult{},
+			threshold:             "2h",
+			freshnessMode:         config.FreshnessModeLastWrite,
+			expectedLogValue:      1,
+			expectedLatencyReport: "N/A - no recent writes",
+		},
+		{
+			title:                 "logs a success value (0) when the threshold is longer than the system tables keep writes",
+			lastWrites:            map[string]db.LatencyResult{},
+			threshold:             "168h",
+			freshnessMode:         config.FreshnessModeLastWrite,
+			expectedLogValue:      0,
+			expectedLatencyReport: "N/A - no recent writes",
+		},
+		{
+			title:                  "returns errors when the system table query errors out",
+			queryErr:               errors.New("permission denied for relation stl_insert"),
+			threshold:              "2h",
+			freshnessMode:          config.FreshnessModeLastWrite,
+			expectedErrorsReturned: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Logf("Testing that performLatencyChecks %s", test.title)
+
+		// Checks in both freshness modes also check latency, which
+		// is mocked to match the last write
+		mockRsClient := &mockRedshiftClient{
+			lastWrites: test.lastWrites,
+			queryErr:   test.queryErr,
+			latencyHrs: test.lastWrites["mockTableName"].LatencyHrs,
+			hasRows:    test.lastWrites["mockTableName"].HasRows,
+		}
+		mockLog := &mockLogger{
+			assertions:            assertions,
+			expectedLogValue:      test.expectedLogValue,
+			expectedLatencyReport: test.expectedLatencyReport,
+		}
+		logger = mockLog // Overrides package level logger
+
+		mockChecks := make(Checks)
+		mockChecks["mockSchemaName"] = make(map[string]config.TableCheck)
+		mockChecks["mockSchemaName"]["mockTableName"] = config.TableCheck{
+			TableName: "mockTableName",
+			Latency: config.LatencyInfo{
+				Threshold:     test.threshold,
+				FreshnessMode: test.freshnessMode,
+			},
+		}
+
+		errors := performLatencyChecks(mockRsClient, mockChecks)
+		assertions.Equal(test.expectedErrorsReturned, len(errors) > 0, "Unexpected errors returned")
+	}
+}
+
+// TestPerformObjectFreshnessChecks tests the performObjectFreshnessChecks
+// function, mocking out object listings and verifying
+// that the correct results are being logged
+func TestPerformObjectFreshnessChecks(t *testing.T) {
+	assertions := assert.New(t)
+
+	tests := []struct {
+		title string
+
+		// Mocks out the results of NewestObject
+		newest     time.Time
+		hasObjects bool
+		listErr    error
+
+		// Mocks out the config
+		path      string
+		threshold string
+
+		// Specifies what we expect to log (or error)
+		expectedLogValue       int
+		expectedLatencyReport  string
+		expectedPanic          bool
+		expectedErrorsReturned bool
+	}{
+		{
+			title:                 "logs a success value (0) when the newest object is within threshold",
+			newest:                time.Now().Add(-90 * time.Minute),
+			hasObjects:            true,
+			path:                  "s3://firehose-prod/events/",
+			threshold:             "2h",
+			expectedLogValue:      0,
+			expectedLatencyReport: "1h",
+		},
+		{
+			title:                 "logs a failure value (1) when the newest object exceeds threshold",
+			newest:                time.Now().Add(-3 * time.Hour),
+			hasObjects:            true,
+			path:                  "s3://firehose-prod/events/",
+			threshold:             "2h",
+			expectedLogValue:      1,
+			expectedLatencyReport: "3h",
+		},
+		{
+			title:                 "logs a failure value (1) when there are no objects",
+			path:                  "s3://firehose-prod/events/",
+			threshold:             "2h",
+			expectedLogValue:      1,
+			expectedLatencyReport: "N/A - no objects",
+		},
+		{
+			title:         "panics when the path isn't an s3 path",
+			path:          "firehose-prod/events/",
+			threshold:     "2h",
+			expectedPanic: true,
+		},
+		{
+			title:                  "returns errors when listing fails",
+			listErr:                errors.New("AccessDenied"),
+			path:                   "s3://firehose-prod/events/",
+			threshold:              "2h",
+			expectedErrorsReturned: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Logf("Testing that performObjectFreshnessChecks %s", test.title)
+
+		mockClient := &mockObjectClient{
+			newest:     test.newest,
+			hasObjects: test.hasObjects,
+			listErr:    test.listErr,
+		}
+		mockLog := &mockLogger{
+			assertions:            assertions,
+			expectedLogValue:      test.expectedLogValue,
+			expectedLatencyReport: test.expectedLatencyReport,
+		}
+		logger = mockLog // Overrides package level logger
+
+		objectChecks := []config.ObjectCheck{{Path: test.path, Threshold: test.threshold}}
+		if test.expectedPanic {
+			assert.Panics(t, func() {
+				performObjectFreshnessChecks(mockClient, objectChecks)
+			}, "Doesn't error when expected")
+			continue
+		}
+
+		errors := performObjectFreshnessChecks(mockClient, objectChecks)
+		assertions.Equal(test.expectedErrorsReturned, len(errors) > 0, "Unexpected errors returned")
+	}
+}
+
 // TestPerformLoadErrorsCheck tests the performLoadErrorsCheck
 // function, mocking out load error results and verifying
 // that the correct results are being logged
@@ -240,3 +734,871 @@ func TestPerformLoadErrorsCheck(t *testing.T) {
 		performLoadErrorsCheck(mockRsClient)
 	}
 }
+
+// recordingLogger records the value and details of every latency event,
+// by table and, for grouped checks, group as in "table[group]". Last
+// write events are recorded as "table last_write".
+type recordingLogger struct {
+	mockLogger
+	values  map[string]int
+	details map[string]l.Details
+}
+
+func (l *recordingLogger) CheckLatencyEvent(latencyErrValue int, fullTableName, reportedLatency, threshold string, details l.Details) {
+	if details.Group != "" {
+		fullTableName = fmt.Sprintf("%s[%s]", fullTableName, details.Group)
+	}
+	l.values[fullTableName] = latencyErrValue
+	l.details[fullTableName] = details
+}
+
+func (l *recordingLogger) CheckLastWriteEvent(lastWriteErrValue int, fullTableName, reportedLastWrite, threshold string, details l.Details) {
+	l.values[fullTableName+" last_write"] = lastWriteErrValue
+	l.details[fullTableName+" last_write"] = details
+}
+
+// TestStaleChains verifies that stale tables are traced back
+// to the root causes upstream of them
+func TestStaleChains(t *testing.T) {
+	assertions := assert.New(t)
+
+	checks := Checks{
+		"raw": {"districts": {}, "schools": {}},
+		"derived": {
+			"district_stats": {DependsOn: []string{"raw.districts", "raw.missing"}},
+			"school_stats":   {DependsOn: []string{"raw"}},
+		},
+		"reports": {"usage": {DependsOn: []string{"derived.district_stats", "derived"}}},
+		"loop":    {"a": {DependsOn: []string{"loop.b"}}, "b": {DependsOn: []string{"loop.a"}}},
+	}
+
+	t.Logf("Testing that schema dependencies expand to their tables, and unchecked tables are dropped")
+	graph := dependencyGraph(checks)
+	assertions.Equal(map[string][]string{
+		"derived.district_stats": {"raw.districts"},
+		"derived.school_stats":   {"raw.districts", "raw.schools"},
+		"reports.usage":          {"derived.district_stats", "derived.school_stats"},
+		"loop.a":                 {"loop.b"},
+		"loop.b":                 {"loop.a"},
+	}, graph)
+
+	t.Logf("Testing that chains lead from the root cause, through stale tables only")
+	chains := staleChains(graph, map[string]bool{
+		"raw.districts":          true,
+		"derived.district_stats": true,
+		"reports.usage":          true,
+		"derived.school_stats":   true,
+		"loop.a":                 true,
+		"loop.b":                 true,
+	})
+	assertions.Equal(map[string][]string{
+		"derived.district_stats": {"raw.districts", "derived.district_stats"},
+		"derived.school_stats":   {"raw.districts", "derived.school_stats"},
+		"reports.usage":          {"raw.districts", "derived.district_stats", "reports.usage"},
+	}, chains)
+	assertions.Equal(map[string][]string{
+		"raw.districts": {"derived.district_stats", "derived.school_stats", "reports.usage"},
+	}, downstreamOf(chains))
+
+	t.Logf("Testing that a stale table downstream of fresh ones is a root cause")
+	assertions.Empty(staleChains(graph, map[string]bool{"reports.usage": true}))
+}
+
+// TestPerformLatencyChecksSuppression verifies that breaches
+// downstream of a stale table, by latency or last write, don't alert
+func TestPerformLatencyChecksSuppression(t *testing.T) {
+	assertions := assert.New(t)
+	currentRun = newCheckRun()
+	defer func() { currentRun = nil }()
+
+	recorder := &recordingLogger{values: make(map[string]int), details: make(map[string]l.Details)}
+	logger = recorder
+	check := func(dependsOn ...string) config.TableCheck {
+		return config.TableCheck{Latency: config.LatencyInfo{Threshold: "2h"}, DependsOn: dependsOn}
+	}
+	checks := Checks{
+		"raw":     {"districts": check()},
+		"derived": {"district_stats": check("raw.districts")},
+	}
+
+	t.Logf("Testing that only the root cause alerts when every table breaches")
+	performLatencyChecks(&mockRedshiftClient{latencyHrs: 5, hasRows: true}, checks)
+	assertions.Equal(map[string]int{
+		"mockClusterName.raw.districts":          1,
+		"mockClusterName.derived.district_stats": 0,
+	}, recorder.values)
+	assertions.Equal([]string{"derived.district_stats"}, recorder.details["mockClusterName.raw.districts"].Downstream)
+	assertions.Equal([]string{"raw.districts", "derived.district_stats"},
+		recorder.details["mockClusterName.derived.district_stats"].DependencyChain)
+
+	statuses := make(map[string]store.Status)
+	for _, result := range currentRun.results {
+		statuses[result.Schema+"."+result.Table] = result.Status
+	}
+	assertions.Equal(map[string]store.Status{
+		"raw.districts":          store.StatusBreach,
+		"derived.district_stats": store.StatusSuppressed,
+	}, statuses)
+
+	t.Logf("Testing that stale last write tables are root causes, and suppressed downstream")
+	recorder = &recordingLogger{values: make(map[string]int), details: make(map[string]l.Details)}
+	logger = recorder
+	lastWrite := func(dependsOn ...string) config.TableCheck {
+		lastWriteCheck := check(dependsOn...)
+		lastWriteCheck.Latency.FreshnessMode = config.FreshnessModeLastWrite
+		return lastWriteCheck
+	}
+	checks = Checks{
+		"raw":     {"districts": lastWrite()},
+		"derived": {"district_stats": check("raw.districts")},
+		"reports": {"usage": lastWrite("derived.district_stats")},
+	}
+	client := &mockRedshiftClient{latencyHrs: 5, hasRows: true, lastWrites: map[string]db.LatencyResult{
+		"districts": {LatencyHrs: 5, HasRows: true},
+		"usage":     {LatencyHrs: 5, HasRows: true},
+	}}
+	assertions.Empty(performLatencyChecks(client, checks))
+	assertions.Equal(map[string]int{
+		"mockClusterName.raw.districts last_write": 1,
+		"mockClusterName.derived.district_stats":   0,
+		"mockClusterName.reports.usage last_write": 0,
+	}, recorder.values)
+	assertions.Equal([]string{"derived.district_stats", "reports.usage"},
+		recorder.details["mockClusterName.raw.districts last_write"].Downstream)
+	assertions.Equal([]string{"raw.districts", "derived.district_stats", "reports.usage"},
+		recorder.details["mockClusterName.reports.usage last_write"].DependencyChain)
+}
+
+// TestPerformLatencyChecksGroups verifies that grouped checks alert on
+// each stale group, and that filters are passed on to latency queries
+func TestPerformLatencyChecksGroups(t *testing.T) {
+	assertions := assert.New(t)
+	currentRun = newCheckRun()
+	defer func() { currentRun = nil }()
+
+	recorder := &recordingLogger{values: make(map[string]int), details: make(map[string]l.Details)}
+	logger = recorder
+	notTest := &config.Filter{Column: "source", Operator: config.FilterNotEquals, Values: []string{"test"}}
+	notDeleted := &config.Filter{Column: "deleted", Operator: config.FilterEquals, Values: []string{"false"}}
+	checks := Checks{"mongo": {
+		"events":  {Latency: config.LatencyInfo{Threshold: "2h", Filter: notTest, GroupBy: "source"}},
+		"schools": {Latency: config.LatencyInfo{Threshold: "2h", Filter: notDeleted}},
+		"event_stats": {
+			Latency:   config.LatencyInfo{Threshold: "2h"},
+			DependsOn: []string{"mongo.events"},
+		},
+	}}
+	client := &mockRedshiftClient{latencyHrs: 5, hasRows: true, groups: map[string]db.LatencyResult{
+		"sis": {LatencyHrs: 1, HasRows: true},
+		"api": {LatencyHrs: 5, HasRows: true},
+	}}
+
+	t.Logf("Testing that each stale group alerts, and makes its table stale")
+	assertions.Empty(performLatencyChecks(client, checks))
+	assertions.Equal(map[string]int{
+		"mockClusterName.mongo.events[api]": 1,
+		"mockClusterName.mongo.events[sis]": 0,
+		"mockClusterName.mongo.schools":     1,
+		"mockClusterName.mongo.event_stats": 0,
+	}, recorder.values)
+	assertions.Equal("api", recorder.details["mockClusterName.mongo.events[api]"].Group)
+	assertions.Equal([]string{"mongo.events", "mongo.event_stats"},
+		recorder.details["mockClusterName.mongo.event_stats"].DependencyChain)
+
+	statuses := make(map[string]store.Status)
+	for _, result := range currentRun.results {
+		statuses[result.Table+"["+result.Group+"]"] = result.Status
+	}
+	assertions.Equal(map[string]store.Status{
+		"events[api]":   store.StatusBreach,
+		"events[sis]":   store.StatusOK,
+		"schools[]":     store.StatusBreach,
+		"event_stats[]": store.StatusSuppressed,
+	}, statuses)
+
+	t.Logf("Testing that filters and groups are passed on to latency queries")
+	requests := make(map[string]db.LatencyRequest)
+	for _, request := range client.requests {
+		requests[request.TableName] = request
+	}
+	assertions.Equal("source", requests["events"].GroupBy)
+	assertions.Equal(&db.Filter{Column: "source", Operator: config.FilterNotEquals, Values: []string{"test"}},
+		requests["events"].Filter)
+	assertions.Equal(&db.Filter{Column: "deleted", Operator: config.FilterEquals, Values: []string{"false"}},
+		requests["schools"].Filter)
+	assertions.Nil(requests["event_stats"].Filter)
+
+	t.Logf("Testing that a grouped table without rows is stale")
+	recorder.values = make(map[string]int)
+	client.groups = nil
+	performLatencyChecks(client, Checks{"mongo": {"events": checks["mongo"]["events"]}})
+	assertions.Equal(map[string]int{"mockClusterName.mongo.events": 1}, recorder.values)
+
+	t.Logf("Testing that grouped query errors are returned")
+	client.queryErr = fmt.Errorf("relation does not exist")
+	assertions.Len(performLatencyChecks(client, Checks{"mongo": {"events": checks["mongo"]["events"]}}), 1)
+}
+
+// TestSilences verifies that silences added with the silence command
+// or in config stop matching breaches from alerting
+func TestSilences(t *testing.T) {
+	assertions := assert.New(t)
+	historyURL := "sqlite:" + filepath.Join(t.TempDir(), "monitor.db")
+
+	t.Logf("Testing that the silence command saves and lists silences")
+	var out bytes.Buffer
+	require.NoError(t, silenceCommand([]string{"-history", historyURL, "-schema", "mongo", "-table", "districts",
+		"-for", "2h", "-reason", "districts backfill"}, &out))
+	assertions.Contains(out.String(), "districts backfill")
+	assertions.Error(silenceCommand([]string{"-history", historyURL, "-schema", "mongo", "-reason", "no end"}, &out))
+	assertions.Error(silenceCommand([]string{"-history", historyURL, "-end", "2099-01-01", "-reason", "no scope"}, &out))
+	out.Reset()
+	require.NoError(t, silenceCommand([]string{"-history", historyURL, "-list"}, &out))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2, out.String())
+	assertions.Regexp(`-\s+mongo\s+districts\s+-\s+districts backfill`, lines[1])
+
+	var err error
+	history, err = store.Open(historyURL)
+	require.NoError(t, err)
+	currentRun = newCheckRun()
+	defer func() {
+		history.Close()
+		history = nil
+		currentRun = nil
+	}()
+	currentRun.silences = loadSilences(config.Config{Silences: []config.Silence{
+		{Pattern: "s3://firehose/*", End: "2099-01-01T00:00:00Z", Reason: "bucket move"},
+	}}, currentRun.startedAt)
+	assertions.Len(currentRun.silences, 2)
+
+	t.Logf("Testing that silenced breaches still run, but don't alert")
+	recorder := &recordingLogger{values: make(map[string]int), details: make(map[string]l.Details)}
+	logger = recorder
+	checks := Checks{"mongo": {
+		"districts": {Latency: config.LatencyInfo{Threshold: "2h"}},
+		"schools":   {Latency: config.LatencyInfo{Threshold: "2h"}},
+	}}
+	performLatencyChecks(&mockRedshiftClient{latencyHrs: 5, hasRows: true}, checks)
+	performObjectFreshnessChecks(&mockObjectClient{newest: time.Now().Add(-5 * time.Hour), hasObjects: true},
+		[]config.ObjectCheck{{Path: "s3://firehose/events", Threshold: "2h"}})
+	assertions.Equal(map[string]int{
+		"mockClusterName.mongo.districts": 0,
+		"mockClusterName.mongo.schools":   1,
+		"s3://firehose/events":            0,
+	}, recorder.values)
+	assertions.Equal("districts backfill", recorder.details["mockClusterName.mongo.districts"].Silenced)
+	assertions.Equal("bucket move", recorder.details["s3://firehose/events"].Silenced)
+
+	statuses := make(map[string]store.Status)
+	for _, result := range currentRun.results {
+		statuses[result.Table] = result.Status
+	}
+	assertions.Equal(map[string]store.Status{
+		"districts":            store.StatusSilenced,
+		"schools":              store.StatusBreach,
+		"s3://firehose/events": store.StatusSilenced,
+	}, statuses)
+}
+
+// TestSkipsUnsupportedChecks verifies that checks the cluster's
+// dialect doesn't support are skipped rather than failing
+func TestSkipsUnsupportedChecks(t *testing.T) {
+	assertions := assert.New(t)
+
+	mockPgClient := &mockRedshiftClient{
+		dialect:  db.Postgres,
+		queryErr: db.ErrUnsupportedCheck,
+	}
+	mockLog := &mockLogger{assertions: assertions}
+	logger = mockLog // Overrides package level logger
+
+	mockChecks := make(Checks)
+	mockChecks["mockSchemaName"] = map[string]config.TableCheck{
+		"mockTableName": {
+			TableName: "mockTableName",
+			Latency: config.LatencyInfo{
+				Threshold:     "2h",
+				FreshnessMode: config.FreshnessModeLastWrite,
+			},
+		},
+	}
+
+	performLoadErrorsCheck(mockPgClient)
+	errors := performLatencyChecks(mockPgClient, mockChecks)
+	assertions.Empty(errors, "Unsupported checks shouldn't return errors")
+	assertions.Equal(0, mockLog.logCount, "Unsupported checks shouldn't log results")
+}
+
+// TestParseOptions verifies that flags take precedence
+// over environment variables, which override defaults
+func TestParseOptions(t *testing.T) {
+	assertions := assert.New(t)
+
+	opts, err := parseOptions(nil)
+	assertions.NoError(err)
+	assertions.Equal("24h", opts.defaultLatency)
+	assertions.Equal("example_config.json", filepath.Base(opts.checksConfigPath))
+	assertions.Equal("kvconfig.yml", filepath.Base(opts.kvconfigPath))
+	assertions.Equal("", opts.clusterName)
+
+	t.Setenv("CHECKS_CONFIG_PATH", "/etc/checks")
+	t.Setenv("DEFAULT_LATENCY", "12h")
+	t.Setenv("CLUSTER_NAME", "redshift-dev")
+	opts, err = parseOptions([]string{"-default-latency", "6h", "-kvconfig", "kv.yml", "{\"payload\": 1}"})
+	assertions.NoError(err)
+	assertions.Equal("/etc/checks", opts.checksConfigPath)
+	assertions.Equal("kv.yml", opts.kvconfigPath)
+	assertions.Equal("6h", opts.defaultLatency)
+	assertions.Equal("redshift-dev", opts.clusterName)
+	assertions.Equal([]string{"{\"payload\": 1}"}, opts.args)
+
+	_, err = parseOptions([]string{"-default-latency", "1 day"})
+	assertions.Error(err)
+
+	t.Setenv("CHECK_INTERVAL", "5m")
+	opts, err = parseOptions(nil)
+	assertions.NoError(err)
+	assertions.Equal(5*time.Minute, opts.interval)
+	assertions.Equal(30*time.Second, opts.reloadInterval)
+
+	_, err = parseOptions([]string{"-interval", "1m", "-reload-interval", "0s"})
+	assertions.Error(err)
+}
+
+// TestRun runs every check end to end against a local SQLite cluster
+func TestRun(t *testing.T) {
+	assertions := assert.New(t)
+	dir := t.TempDir()
+
+	dbPath := filepath.Join(dir, "warehouse.db")
+	session, err := sql.Open("sqlite", dbPath)
+	require.NoError(t, err)
+	_, err = session.Exec(`CREATE TABLE events (id INTEGER, "time" TIMESTAMP)`)
+	require.NoError(t, err)
+	_, err = session.Exec(`INSERT INTO events VALUES (1, ?)`,
+		time.Now().UTC().Add(-90*time.Minute).Format("2006-01-02 15:04:05"))
+	require.NoError(t, err)
+	require.NoError(t, session.Close())
+
+	configPath := filepath.Join(dir, "checks.yml")
+	require.NoError(t, ioutil.WriteFile(configPath, []byte(`
+type: sqlite
+postgres-checks:
+  - schema: main
+`), 0644))
+	t.Setenv("SQLITE_PATH", dbPath)
+	historyURL := "sqlite:" + filepath.Join(dir, "monitor.db")
+
+	tests := []struct {
+		defaultLatency        string
+		expectedLogValue      int
+		expectedLatencyReport string
+	}{
+		{"2h", 0, "1h"},
+		{"30m", 1, "1h"},
+	}
+
+	for _, test := range tests {
+		t.Logf("Testing run with a default latency of %s", test.defaultLatency)
+
+		mockLog := &mockLogger{
+			assertions:            assertions,
+			expectedLogValue:      test.expectedLogValue,
+			expectedLatencyReport: test.expectedLatencyReport,
+		}
+		logger = mockLog // Overrides package level logger
+
+		err = run(options{
+			checksConfigPath: configPath,
+			defaultLatency:   test.defaultLatency,
+			clusterName:      "local",
+			historyURL:       historyURL,
+		})
+		assertions.NoError(err)
+		assertions.Equal(1, mockLog.logCount, "Expected a single latency check")
+		assertions.Equal([]string{}, mockLog.coverage["no_timestamp_column"], "Expected every table to be covered")
+	}
+
+	t.Logf("Testing that each run's results were written to history")
+	var out bytes.Buffer
+	require.NoError(t, historyCommand([]string{"-history", historyURL, "-table", "main.events", "-cluster", "local"}, &out))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 5, out.String())
+	assertions.Regexp(`local\s+future_timestamp\s+ok\s+-\s+1h`, lines[1])
+	assertions.Regexp(`local\s+future_timestamp\s+ok\s+-\s+1h`, lines[2])
+	assertions.Regexp(`local\s+latency\s+ok\s+1h\s+2h`, lines[3])
+	assertions.Regexp(`local\s+latency\s+breach\s+1h\s+30m`, lines[4])
+
+	t.Logf("Testing that run fails before any checks if the cluster can't be reached")
+	t.Setenv("SQLITE_PATH", filepath.Join(dir, "missing", "warehouse.db"))
+	mockLog := &mockLogger{assertions: assertions}
+	logger = mockLog
+	assertions.Panics(func() {
+		run(options{checksConfigPath: configPath, defaultLatency: "2h"})
+	})
+	assertions.Equal(0, mockLog.logCount)
+}
+
+// TestRunRecovered verifies that a fatal error in a long-running
+// monitor fails the run rather than stopping the monitor
+func TestRunRecovered(t *testing.T) {
+	logger = &mockLogger{assertions: assert.New(t)}
+	defer func() { currentRun = nil }()
+
+	client := &mockRedshiftClient{queryErr: fmt.Errorf("connection reset")}
+	configChecks := config.Config{PostgresChecks: []config.SchemaConfig{{SchemaName: "mongo"}}}
+	err := runRecovered(client, configChecks, options{defaultLatency: "2h"})
+	assert.EqualError(t, err, "Encountered fatal error: connection reset")
+}
+
+// TestCheckSetReload verifies that reloads only swap in
+// valid configs, and report whether checks changed
+func TestCheckSetReload(t *testing.T) {
+	assertions := assert.New(t)
+	configPath := filepath.Join(t.TempDir(), "checks.yml")
+	writeConfig := func(content string) {
+		require.NoError(t, ioutil.WriteFile(configPath, []byte(content), 0644))
+	}
+
+	writeConfig("postgres-checks:\n  - schema: mongo\n    default_threshold: 24h\n")
+	initial, err := config.LoadChecks(configPath)
+	require.NoError(t, err)
+	checks := newCheckSet(configPath, initial)
+
+	t.Log("Testing that an unchanged config is a no-op")
+	changed, err := checks.reload()
+	assertions.NoError(err)
+	assertions.False(changed)
+
+	t.Log("Testing that a changed config is swapped in")
+	writeConfig("postgres-checks:\n  - schema: mongo\n    default_threshold: 2h\n")
+	changed, err = checks.reload()
+	assertions.NoError(err)
+	assertions.True(changed)
+	assertions.Equal("2h", checks.Load().PostgresChecks[0].DefaultThreshold)
+
+	t.Log("Testing that an invalid config is rejected")
+	writeConfig("postgres-checks:\n  - schema: mongo\n    default_threshold: 2 hours\n")
+	changed, err = checks.reload()
+	assertions.Error(err)
+	assertions.False(changed)
+	assertions.Equal("2h", checks.Load().PostgresChecks[0].DefaultThreshold)
+
+	t.Log("Testing that an unparseable config is rejected")
+	writeConfig("postgres-checks: [")
+	_, err = checks.reload()
+	assertions.Error(err)
+	assertions.Equal("2h", checks.Load().PostgresChecks[0].DefaultThreshold)
+
+	t.Log("Testing that changing the cluster type is rejected")
+	writeConfig("type: mysql\npostgres-checks:\n  - schema: mongo\n")
+	_, err = checks.reload()
+	assertions.Error(err)
+	assertions.Equal("", checks.Load().Type)
+}
+
+// TestHistoryFlags verifies parsing of the history command's table and times
+func TestHistoryFlags(t *testing.T) {
+	assertions := assert.New(t)
+
+	schema, table, err := splitTableName("mongo.districts")
+	assertions.NoError(err)
+	assertions.Equal("mongo", schema)
+	assertions.Equal("districts", table)
+
+	schema, table, err = splitTableName("s3://firehose/events/")
+	assertions.NoError(err)
+	assertions.Equal("", schema)
+	assertions.Equal("s3://firehose/events/", table)
+
+	_, _, err = splitTableName("districts")
+	assertions.Error(err)
+
+	now := time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value    string
+		expected time.Time
+	}{
+		{"", time.Time{}},
+		{"2024-03-01T08:00:00Z", time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)},
+		{"2024-03-01", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"48h", now.Add(-48 * time.Hour)},
+	}
+	for _, test := range tests {
+		t.Logf("Testing that %q is parsed as a time", test.value)
+		parsed, err := parseTimeFlag(test.value, now)
+		assertions.NoError(err)
+		assertions.Equal(test.expected, parsed)
+	}
+
+	_, err = parseTimeFlag("last tuesday", now)
+	assertions.Error(err)
+}
+
+// TestSLOReports verifies which tables are reported against which
+// objectives, and that only results in each table's window count
+func TestSLOReports(t *testing.T) {
+	assertions := assert.New(t)
+	now := time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)
+
+	s, err := store.Open("sqlite:" + filepath.Join(t.TempDir(), "monitor.db"))
+	require.NoError(t, err)
+	defer s.Close()
+
+	var results []store.Result
+	for i, table := range []string{"districts", "schools", "events", "snapshot"} {
+		for day := 1; day <= 10; day++ {
+			status := store.StatusOK
+			if day <= i {
+				status = store.StatusBreach
+			}
+			results = append(results, store.Result{
+				RunID:     fmt.Sprintf("run-%d", day),
+				CheckedAt: now.Add(-time.Duration(day) * 24 * time.Hour),
+				Cluster:   "prod",
+				Schema:    "mongo",
+				Table:     table,
+				Check:     "latency",
+				Threshold: "24h",
+				Status:    status,
+			})
+		}
+	}
+	require.NoError(t, s.RecordResults(results))
+
+	schemaConfigs := []config.SchemaConfig{
+		{
+			SchemaName:   "mongo",
+			DefaultSLO:   config.SLO{Target: 95, Window: "240h"},
+			TablesToOmit: []string{"snapshot"},
+			Checks: []config.TableCheck{
+				{TableName: "districts", SLO: config.SLO{Target: 99}},
+				{TableName: "schools", SLO: config.SLO{Window: "48h"}},
+			},
+		},
+		{SchemaName: "salesforce"},
+	}
+
+	reports, err := sloReports(s, schemaConfigs, "prod", now)
+	require.NoError(t, err)
+	slo.SortWorstFirst(reports)
+
+	summary := make(map[string]string)
+	for _, report := range reports {
+		summary[report.Table] = fmt.Sprintf("%v %s %d/%d", report.Target, report.Window, report.GoodRuns, report.Runs)
+	}
+	assertions.Equal(map[string]string{
+		// All runs ok, with the table's own target
+		"mongo.districts": "99 240h 10/10",
+		// The schema's target over the table's shorter window
+		"mongo.schools": "95 48h 1/2",
+		// Only in history, with the schema's objective
+		"mongo.events": "95 240h 8/10",
+	}, summary)
+	assertions.Equal("mongo.schools", reports[0].Table)
+}
+
+// TestPerformCoverageCheck verifies that every gap in coverage is
+// logged, and that failing to build the report doesn't panic
+func TestPerformCoverageCheck(t *testing.T) {
+	assertions := assert.New(t)
+
+	mockLog := &mockLogger{assertions: assertions}
+	logger = mockLog // Overrides package level logger
+	client := &mockRedshiftClient{
+		tables: map[string][]string{
+			"mongo":   {"districts", "lookup"},
+			"scratch": {"tmp"},
+		},
+		tableMetadata: map[string]db.TableMetadata{
+			"districts": {TableName: "districts", TimestampColumn: "_data_timestamp"},
+		},
+	}
+	schemaConfigs := []config.SchemaConfig{
+		{SchemaName: "mongo", Checks: []config.TableCheck{{TableName: "students"}}},
+	}
+
+	t.Logf("Testing that a coverage event is logged for each gap, even empty ones")
+	performCoverageCheck(client, schemaConfigs)
+	assertions.Equal(map[string][]string{
+		"unmonitored_schema":   {"scratch"},
+		"no_timestamp_column":  {"mongo.lookup"},
+		"omitted":              {},
+		"missing_from_cluster": {"mongo.students"},
+	}, mockLog.coverage)
+
+	t.Logf("Testing that query errors are logged instead of coverage events")
+	mockLog = &mockLogger{assertions: assertions}
+	logger = mockLog
+	client.queryErr = fmt.Errorf("connection reset")
+	performCoverageCheck(client, schemaConfigs)
+	assertions.Nil(mockLog.coverage)
+}
+
+// TestPerformSchemaDriftChecks verifies that column changes since the
+// previous run are logged, and that checks whose timestamp column was
+// dropped are reported and dropped rather than failing their query
+func TestPerformSchemaDriftChecks(t *testing.T) {
+	assertions := assert.New(t)
+
+	var err error
+	history, err = store.Open("sqlite:" + filepath.Join(t.TempDir(), "monitor.db"))
+	require.NoError(t, err)
+	currentRun = newCheckRun()
+	defer func() {
+		history.Close()
+		history = nil
+		currentRun = nil
+	}()
+
+	check := func(timestampColumn, freshnessMode string) config.TableCheck {
+		return config.TableCheck{Latency: config.LatencyInfo{
+			TimestampColumn: timestampColumn,
+			Threshold:       "2h",
+			FreshnessMode:   freshnessMode,
+		}}
+	}
+	newChecks := func() Checks {
+		return Checks{"mongo": {
+			"districts": check("updated_at", ""),
+			"schools":   check("updated_at", config.FreshnessModeBoth),
+			"sections":  check("_data_timestamp", ""),
+		}}
+	}
+	client := &mockRedshiftClient{columns: map[string][]db.Column{
+		"districts": {{Name: "id", Type: "integer"}, {Name: "updated_at", Type: "timestamp"}},
+		"schools":   {{Name: "id", Type: "integer"}, {Name: "updated_at", Type: "timestamp"}},
+		"sections":  {{Name: "_data_timestamp", Type: "timestamp"}},
+		"unchecked": {{Name: "id", Type: "integer"}},
+	}}
+
+	t.Logf("Testing that the first snapshot logs no drift")
+	mockLog := &mockLogger{assertions: assertions}
+	logger = mockLog // Overrides package level logger
+	assertions.Empty(performSchemaDriftChecks(client, newChecks()))
+	assertions.Empty(mockLog.drift)
+	assertions.Equal(map[string]int{
+		"mockClusterName.mongo.districts": 0,
+		"mockClusterName.mongo.schools":   0,
+		"mockClusterName.mongo.sections":  0,
+	}, mockLog.missingTimestamps)
+
+	t.Logf("Testing that added, removed and retyped columns of checked tables are logged")
+	client.columns = map[string][]db.Column{
+		"districts": {{Name: "id", Type: "bigint"}, {Name: "deleted_at", Type: "timestamp"}},
+		"schools":   {{Name: "id", Type: "integer"}},
+		"sections":  {{Name: "_data_timestamp", Type: "timestamp"}},
+		"unchecked": {{Name: "id", Type: "bigint"}},
+	}
+	mockLog = &mockLogger{assertions: assertions}
+	logger = mockLog
+	checks := newChecks()
+	errs := performSchemaDriftChecks(client, checks)
+	assertions.Equal([]string{
+		"mockClusterName.mongo.districts id retyped",
+		"mockClusterName.mongo.districts deleted_at added",
+		"mockClusterName.mongo.districts updated_at removed",
+		"mockClusterName.mongo.schools updated_at removed",
+	}, mockLog.drift)
+
+	t.Logf("Testing that missing timestamp columns are reported, and their latency checks dropped")
+	assertions.Equal(map[string]int{
+		"mockClusterName.mongo.districts": 1,
+		"mockClusterName.mongo.schools":   1,
+		"mockClusterName.mongo.sections":  0,
+	}, mockLog.missingTimestamps)
+	assertions.Len(errs, 2)
+	assertions.Contains(fmt.Sprint(errs), "timestamp column updated_at of mongo.districts no longer exists")
+	assertions.NotContains(checks["mongo"], "districts")
+	assertions.Equal(config.FreshnessModeLastWrite, checks["mongo"]["schools"].Latency.FreshnessMode)
+	assertions.Contains(checks["mongo"], "sections")
+
+	t.Logf("Testing that a silenced table's missing timestamp column doesn't alert or fail the run")
+	currentRun.silences = []config.Silence{{Schema: "mongo", End: "2099-01-01T00:00:00Z", Reason: "migration"}}
+	mockLog = &mockLogger{assertions: assertions}
+	logger = mockLog
+	assertions.Empty(performSchemaDriftChecks(client, newChecks()))
+	assertions.Empty(mockLog.drift)
+	assertions.Equal(0, mockLog.missingTimestamps["mockClusterName.mongo.districts"])
+	currentRun.silences = nil
+
+	t.Logf("Testing that a table whose only timestamp column was dropped is still built and reported")
+	client = &mockRedshiftClient{
+		tableMetadata: map[string]db.TableMetadata{
+			"sections": {TableName: "sections", TimestampColumn: "_data_timestamp"},
+		},
+		columns: map[string][]db.Column{
+			"districts": {{Name: "id", Type: "integer"}},
+			"sections":  {{Name: "_data_timestamp", Type: "timestamp"}},
+		},
+	}
+	schemaConfigs := []config.SchemaConfig{{
+		SchemaName: "mongo",
+		Checks: []config.TableCheck{
+			{TableName: "districts", Latency: config.LatencyInfo{TimestampColumn: "_data_timestamp", Threshold: "2h"}},
+			{TableName: "gone", Latency: config.LatencyInfo{TimestampColumn: "_data_timestamp", Threshold: "2h"}},
+		},
+	}}
+	checks = buildLatencyChecks(schemaConfigs, client, "24h")
+	assertions.Contains(checks["mongo"], "districts")
+	assertions.NotContains(checks["mongo"], "gone")
+	mockLog = &mockLogger{assertions: assertions}
+	logger = mockLog
+	errs = performSchemaDriftChecks(client, checks)
+	assertions.Equal(1, mockLog.missingTimestamps["mockClusterName.mongo.districts"])
+	require.Len(t, errs, 1)
+	assertions.Contains(errs[0].Error(), "timestamp column _data_timestamp of mongo.districts no longer exists")
+}
+
+// TestPerformLatencyChecksFutureTimestamps verifies that timestamps further
+// in the future than their tolerance are reported, and that bounded checks
+// ask for latency up to the tolerance
+func TestPerformLatencyChecksFutureTimestamps(t *testing.T) {
+	assertions := assert.New(t)
+	currentRun = newCheckRun()
+	defer func() { currentRun = nil }()
+
+	now := time.Now().UTC()
+	bounded := true
+	checks := Checks{"mongo": {
+		"bounded": {Latency: config.LatencyInfo{Threshold: "2h", BoundToNow: &bounded}},
+		"lenient": {Latency: config.LatencyInfo{Threshold: "2h", FutureTolerance: "48h"}},
+	}}
+	client := &mockRedshiftClient{
+		latencyHrs:      1,
+		hasRows:         true,
+		maxTimestamp:    now.Add(-time.Hour),
+		futureTimestamp: now.Add(24 * time.Hour),
+	}
+
+	t.Logf("Testing that timestamps beyond the tolerance are reported")
+	mockLog := &mockLogger{assertions: assertions, expectedLatencyReport: "1h"}
+	logger = mockLog // Overrides package level logger
+	assertions.Empty(performLatencyChecks(client, checks))
+	assertions.Equal(map[string]int{
+		"mockClusterName.mongo.bounded": 1,
+		"mockClusterName.mongo.lenient": 0,
+	}, mockLog.futureTimestamps)
+
+	t.Logf("Testing that future timestamp checks are recorded in history")
+	statuses := make(map[string]store.Status)
+	for _, result := range currentRun.results {
+		if result.Check == futureTimestampCheckType {
+			statuses[result.Table] = result.Status
+			assertions.Equal(client.futureTimestamp, result.MaxTimestamp)
+		}
+	}
+	assertions.Equal(map[string]store.Status{
+		"bounded": store.StatusBreach,
+		"lenient": store.StatusOK,
+	}, statuses)
+
+	t.Logf("Testing that only bounded checks are bounded, by now plus their tolerance")
+	bounds := make(map[string]time.Time)
+	for _, request := range client.requests {
+		bounds[request.TableName] = request.BoundedBy
+	}
+	assertions.WithinDuration(now.Add(time.Hour), bounds["bounded"], time.Minute)
+	assertions.True(bounds["lenient"].IsZero())
+
+	t.Logf("Testing that timestamps within the tolerance aren't reported")
+	client.futureTimestamp = now.Add(30 * time.Minute)
+	mockLog = &mockLogger{assertions: assertions, expectedLatencyReport: "1h"}
+	logger = mockLog
+	assertions.Empty(performLatencyChecks(client, checks))
+	assertions.Equal(0, mockLog.futureTimestamps["mockClusterName.mongo.bounded"])
+
+	t.Logf("Testing that tables without rows aren't reported")
+	client.hasRows, client.maxTimestamp, client.futureTimestamp = false, time.Time{}, time.Time{}
+	mockLog = &mockLogger{assertions: assertions, expectedLogValue: 1, expectedLatencyReport: "N/A - no rows"}
+	logger = mockLog
+	assertions.Empty(performLatencyChecks(client, checks))
+	assertions.Empty(mockLog.futureTimestamps)
+}
+
+// TestPerformColumnQualityChecks verifies that column checks alert on
+// columns that are too often null, have too few distinct values or
+// have values they aren't allowed
+func TestPerformColumnQualityChecks(t *testing.T) {
+	assertions := assert.New(t)
+	currentRun = newCheckRun()
+	defer func() { currentRun = nil }()
+
+	maxNulls := 0.1
+	checks := Checks{"mongo": {
+		"districts": {
+			TableName: "districts",
+			Latency:   config.LatencyInfo{TimestampColumn: "updated_at", Threshold: "2h"},
+			Quality: config.QualityInfo{Columns: []config.ColumnCheck{
+				{Column: "name", MaxNullFraction: &maxNulls, MinDistinct: 2},
+				{Column: "state", MinDistinct: 2, AllowedValues: []string{"active", "archived"}},
+			}},
+		},
+		"schools": {TableName: "schools", Latency: config.LatencyInfo{TimestampColumn: "updated_at", Threshold: "2h"}},
+	}}
+	client := &mockRedshiftClient{columnStats: map[string][]db.ColumnStats{"districts": {
+		{Column: "name", Rows: 100, Nulls: 50, Distinct: 40},
+		{Column: "state", Rows: 100, Nulls: 0, Distinct: 3, Disallowed: 7},
+	}}}
+
+	t.Logf("Testing that each check of each column is logged with its observed metric")
+	mockLog := &mockLogger{assertions: assertions}
+	logger = mockLog // Overrides package level logger
+	assertions.Empty(performColumnQualityChecks(client, checks))
+	assertions.Equal(map[string]string{
+		"mockClusterName.mongo.districts.name null_fraction":   "1 0.5000",
+		"mockClusterName.mongo.districts.name distinct_count":  "0 40",
+		"mockClusterName.mongo.districts.state distinct_count": "0 3",
+		"mockClusterName.mongo.districts.state allowed_values": "1 7",
+	}, mockLog.columnQuality)
+
+	t.Logf("Testing that each check is recorded in history, by column")
+	statuses := func() map[string]store.Status {
+		recorded := make(map[string]store.Status)
+		for _, result := range currentRun.results {
+			recorded[result.Group+" "+result.Check] = result.Status
+		}
+		currentRun.results = nil
+		return recorded
+	}
+	assertions.Equal(map[string]store.Status{
+		"name null_fraction":   store.StatusBreach,
+		"name distinct_count":  store.StatusOK,
+		"state distinct_count": store.StatusOK,
+		"state allowed_values": store.StatusBreach,
+	}, statuses())
+
+	t.Logf("Testing that silenced breaches are recorded as silenced, without alerting")
+	currentRun.silences = []config.Silence{{Schema: "mongo", Table: "districts", End: "2099-01-01T00:00:00Z", Reason: "backfill"}}
+	mockLog = &mockLogger{assertions: assertions}
+	logger = mockLog
+	assertions.Empty(performColumnQualityChecks(client, checks))
+	assertions.Equal("0 0.5000", mockLog.columnQuality["mockClusterName.mongo.districts.name null_fraction"])
+	assertions.Equal(store.StatusSilenced, statuses()["name null_fraction"])
+	currentRun.silences = nil
+
+	t.Logf("Testing that tables without rows in their window don't breach, and are recorded as skipped")
+	client.columnStats["districts"] = []db.ColumnStats{{Column: "name"}, {Column: "state"}}
+	mockLog = &mockLogger{assertions: assertions}
+	logger = mockLog
+	assertions.Empty(performColumnQualityChecks(client, checks))
+	for check, logged := range mockLog.columnQuality {
+		assertions.Equal("0 "+noRowsInWindow, logged, check)
+	}
+	for check, status := range statuses() {
+		assertions.Equal(store.StatusSkipped, status, check)
+	}
+
+	t.Logf("Testing that query errors are returned, and recorded for each check")
+	client.queryErr = fmt.Errorf("column does not exist")
+	assertions.Len(performColumnQualityChecks(client, checks), 1)
+	recorded := statuses()
+	assertions.Len(recorded, 4)
+	for check, status := range recorded {
+		assertions.Equal(store.StatusError, status, check)
+	}
+
+	t.Logf("Testing that nothing is queried without column checks")
+	assertions.Empty(performColumnQualityChecks(client, Checks{"mongo": {"schools": checks["mongo"]["schools"]}}))
+}
@@ -0,0 +1,104 @@
+package objectstore
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+
+	"github.com/Clever/analytics-monitor/config"
+	l "github.com/Clever/analytics-monitor/logger"
+)
+
+// Client exposes an interface for inspecting objects in an object store.
+type Client interface {
+	NewestObject(bucket, prefix string) (time.Time, bool, error)
+}
+
+// s3Client provides a default implementation of Client
+// for S3 and S3-compatible stores such as MinIO.
+type s3Client struct {
+	api s3iface.S3API
+}
+
+// S3Settings configures the S3 endpoint to connect to.
+// Credentials are read from the default AWS credential chain.
+type S3Settings struct {
+	// Endpoint overrides the AWS endpoint, e.g. http://localhost:9000
+	// for a local MinIO. Empty uses AWS S3.
+	Endpoint string
+	Region   string
+}
+
+// newS3Client creates an S3 client.
+func newS3Client(settings S3Settings) (Client, error) {
+	awsConfig := aws.NewConfig().WithRegion(settings.Region)
+	if settings.Endpoint != "" {
+		// S3-compatible stores generally don't support virtual-host style buckets
+		awsConfig = awsConfig.WithEndpoint(settings.Endpoint).WithS3ForcePathStyle(true)
+	}
+
+	l.GetKVLogger().InfoD("New-s3-client", l.M{
+		"endpoint": settings.Endpoint,
+		"region":   settings.Region,
+	})
+	sess, err := session.NewSession(awsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3Client{s3.New(sess)}, nil
+}
+
+// NewS3Client initializes an S3 client
+func NewS3Client() (Client, error) {
+	return newS3Client(S3Settings{
+		Endpoint: config.S3Endpoint,
+		Region:   config.S3Region,
+	})
+}
+
+// NewestObject returns the most recent LastModified time of the objects
+// under prefix in bucket, and whether there are any objects at all.
+// Every page of results is listed, so prefixes should be kept narrow.
+func (c *s3Client) NewestObject(bucket, prefix string) (time.Time, bool, error) {
+	var newest time.Time
+	found := false
+
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	}
+	err := c.api.ListObjectsV2Pages(input, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, object := range page.Contents {
+			if object.LastModified == nil {
+				continue
+			}
+			if !found || object.LastModified.After(newest) {
+				newest = *object.LastModified
+				found = true
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("Error listing s3://%s/%s: %s", bucket, prefix, err)
+	}
+
+	return newest, found, nil
+}
+
+// ParsePath splits an s3://bucket/prefix path into its bucket and prefix.
+// Keys are taken as written, so '#', '?' and '%' are kept in the prefix.
+func ParsePath(path string) (string, string, error) {
+	rest, ok := strings.CutPrefix(path, "s3://")
+	bucket, prefix, _ := strings.Cut(rest, "/")
+	if !ok || bucket == "" {
+		return "", "", fmt.Errorf("Expected an s3://bucket/prefix path, got %q", path)
+	}
+	return bucket, prefix, nil
+}
@@ -0,0 +1,111 @@
+package objectstore
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 serves ListObjectsV2 for a single bucket, two objects per page,
+// standing in for a local MinIO
+func fakeS3(t *testing.T, bucket string, objects map[string]time.Time, order []string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/"+bucket {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `<Error><Code>NoSuchBucket</Code><Message>missing</Message></Error>`)
+			return
+		}
+		assert.Equal(t, "2", r.URL.Query().Get("list-type"))
+		prefix := r.URL.Query().Get("prefix")
+
+		var keys []string
+		for _, key := range order {
+			if len(key) >= len(prefix) && key[:len(prefix)] == prefix {
+				keys = append(keys, key)
+			}
+		}
+
+		start := 0
+		if token := r.URL.Query().Get("continuation-token"); token != "" {
+			fmt.Sscanf(token, "%d", &start)
+		}
+		end := start + 2
+		truncated := end < len(keys)
+		if !truncated {
+			end = len(keys)
+		}
+
+		fmt.Fprintf(w, `<ListBucketResult><Name>%s</Name><Prefix>%s</Prefix><IsTruncated>%v</IsTruncated>`,
+			bucket, prefix, truncated)
+		if truncated {
+			fmt.Fprintf(w, `<NextContinuationToken>%d</NextContinuationToken>`, end)
+		}
+		for _, key := range keys[start:end] {
+			fmt.Fprintf(w, `<Contents><Key>%s</Key><LastModified>%s</LastModified><Size>1</Size></Contents>`,
+				key, objects[key].UTC().Format(time.RFC3339))
+		}
+		fmt.Fprint(w, `</ListBucketResult>`)
+	}))
+}
+
+func TestNewestObject(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "minio")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "minio123")
+
+	now := time.Now().UTC().Truncate(time.Second)
+	objects := map[string]time.Time{
+		"events/a.json.gz": now.Add(-5 * time.Hour),
+		"events/b.json.gz": now.Add(-1 * time.Hour),
+		"events/c.json.gz": now.Add(-3 * time.Hour),
+		"other/d.json.gz":  now,
+	}
+	order := []string{"events/a.json.gz", "events/b.json.gz", "events/c.json.gz", "other/d.json.gz"}
+	server := fakeS3(t, "firehose", objects, order)
+	defer server.Close()
+
+	client, err := newS3Client(S3Settings{Endpoint: server.URL, Region: "us-west-2"})
+	require.NoError(t, err)
+
+	t.Log("Testing that the newest object is found across pages")
+	newest, found, err := client.NewestObject("firehose", "events/")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, now.Add(-1*time.Hour), newest.UTC())
+
+	t.Log("Testing that an empty prefix reports no objects")
+	_, found, err = client.NewestObject("firehose", "missing/")
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	t.Log("Testing that listing errors are returned")
+	_, _, err = client.NewestObject("nope", "events/")
+	assert.Error(t, err)
+}
+
+func TestParsePath(t *testing.T) {
+	bucket, prefix, err := ParsePath("s3://firehose-prod/github-events/")
+	assert.NoError(t, err)
+	assert.Equal(t, "firehose-prod", bucket)
+	assert.Equal(t, "github-events/", prefix)
+
+	bucket, prefix, err = ParsePath("s3://firehose-prod")
+	assert.NoError(t, err)
+	assert.Equal(t, "firehose-prod", bucket)
+	assert.Equal(t, "", prefix)
+
+	t.Log("Testing that '#', '?' and '%' are kept in the prefix")
+	bucket, prefix, err = ParsePath("s3://firehose-prod/events#1/run?id=2/100%/")
+	assert.NoError(t, err)
+	assert.Equal(t, "firehose-prod", bucket)
+	assert.Equal(t, "events#1/run?id=2/100%/", prefix)
+
+	_, _, err = ParsePath("https://firehose-prod/github-events")
+	assert.Error(t, err)
+	_, _, err = ParsePath("s3:///github-events")
+	assert.Error(t, err)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"github.com/kardianos/osext"
+)
+
+// options configures a run of analytics-monitor. Each option can be
+// set with a flag, or with an environment variable when the flag is
+// omitted, falling back to the defaults used in our Docker image.
+type options struct {
+	// checksConfigPath is a checks config file or directory
+	checksConfigPath string
+	// kvconfigPath is the kayvee log routing config
+	kvconfigPath string
+	// defaultLatency is the threshold for tables whose schema has no default
+	defaultLatency string
+	// clusterName overrides the name of the cluster in emitted metrics
+	clusterName string
+	// interval runs the checks repeatedly as a long-lived process.
+	// Zero runs them once.
+	interval time.Duration
+	// reloadInterval is how often a long-lived process
+	// checks the checks config for changes
+	reloadInterval time.Duration
+	// historyURL is the store check results are written to,
+	// a postgres:// URL or sqlite:<path>. Empty disables history.
+	historyURL string
+
+	// args are the remaining arguments after flags
+	args []string
+}
+
+// parseOptions parses command line flags and environment variables.
+// By default config files are read relative to the executable,
+// where the Dockerfile puts them.
+func parseOptions(args []string) (options, error) {
+	dir, err := osext.ExecutableFolder()
+	if err != nil {
+		return options{}, err
+	}
+
+	var opts options
+	flags := flag.NewFlagSet("analytics-monitor", flag.ContinueOnError)
+	flags.StringVar(&opts.checksConfigPath, "config", defaultChecksConfigPath(dir),
+		"checks config file or directory (env CHECKS_CONFIG_PATH)")
+	flags.StringVar(&opts.kvconfigPath, "kvconfig",
+		envOrDefault("KVCONFIG_PATH", path.Join(dir, "kvconfig.yml")),
+		"kayvee log routing config (env KVCONFIG_PATH)")
+	flags.StringVar(&opts.defaultLatency, "default-latency",
+		envOrDefault("DEFAULT_LATENCY", "24h"),
+		"latency threshold for schemas without a default_threshold (env DEFAULT_LATENCY)")
+	flags.StringVar(&opts.clusterName, "cluster",
+		os.Getenv("CLUSTER_NAME"),
+		"cluster name reported in metrics, defaults to one derived from the backend (env CLUSTER_NAME)")
+
+	flags.StringVar(&opts.historyURL, "history",
+		os.Getenv("HISTORY_URL"),
+		"store for check history, a postgres:// URL or sqlite:<path> (env HISTORY_URL)")
+
+	interval, err := durationEnvOrDefault("CHECK_INTERVAL", 0)
+	if err != nil {
+		return options{}, err
+	}
+	flags.DurationVar(&opts.interval, "interval", interval,
+		"run checks repeatedly at this interval instead of once (env CHECK_INTERVAL)")
+	reloadInterval, err := durationEnvOrDefault("CONFIG_RELOAD_INTERVAL", 30*time.Second)
+	if err != nil {
+		return options{}, err
+	}
+	flags.DurationVar(&opts.reloadInterval, "reload-interval", reloadInterval,
+		"how often to reload the checks config when running repeatedly (env CONFIG_RELOAD_INTERVAL)")
+
+	if err := flags.Parse(args); err != nil {
+		return options{}, err
+	}
+	if _, err := time.ParseDuration(opts.defaultLatency); err != nil {
+		return options{}, fmt.Errorf("invalid default latency %q: %s", opts.defaultLatency, err)
+	}
+	if opts.interval > 0 && opts.reloadInterval <= 0 {
+		return options{}, fmt.Errorf("reload interval must be positive, got %s", opts.reloadInterval)
+	}
+	opts.args = flags.Args()
+
+	return opts, nil
+}
+
+// defaultChecksConfigPath returns the checks config path set in the
+// environment, or the example config next to the executable in dir
+func defaultChecksConfigPath(dir string) string {
+	return envOrDefault("CHECKS_CONFIG_PATH", path.Join(dir, "config/example_config.json"))
+}
+
+// envOrDefault returns the value of an environment variable, or defaultValue if it is unset
+func envOrDefault(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// durationEnvOrDefault parses a duration from an environment variable,
+// or returns defaultValue if it is unset
+func durationEnvOrDefault(key string, defaultValue time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %s", key, value, err)
+	}
+	return duration, nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Clever/analytics-monitor/config"
+	"github.com/Clever/analytics-monitor/db"
+	l "github.com/Clever/analytics-monitor/logger"
+	"github.com/Clever/analytics-monitor/store"
+)
+
+// Column quality checks are identified in check history by these types,
+// with the column checked as the result's group
+const (
+	nullFractionCheckType  = "null_fraction"
+	distinctCountCheckType = "distinct_count"
+	allowedValuesCheckType = "allowed_values"
+)
+
+// noRowsInWindow is reported as the observed metric of column
+// checks on tables without rows in their quality window
+const noRowsInWindow = "N/A - no rows in window"
+
+// performColumnQualityChecks profiles the columns of every table with
+// column checks over its recent rows, with one query per table, and logs
+// each check against its limit. Tables without rows in their window don't
+// breach, since their latency checks already alert. Returns the errors of
+// any queries that failed.
+func performColumnQualityChecks(client db.Client, checks Checks) []error {
+	if !anyChecksColumns(checks) || skipUnsupportedCheck(client, db.CheckColumnQuality) {
+		return nil
+	}
+
+	var queryErrors []error
+	clusterName := client.GetClusterName()
+	now := time.Now()
+
+	for schemaName, tableChecks := range checks {
+		for tableName, check := range tableChecks {
+			if len(check.Quality.Columns) == 0 {
+				continue
+			}
+			if check.Latency.TimestampColumn == "" {
+				l.GetKVLogger().WarnD("column-quality-skipped", l.M{
+					"table":  fmt.Sprintf("%s.%s", schemaName, tableName),
+					"reason": "no timestamp column to window rows by",
+				})
+				continue
+			}
+
+			window, err := check.Quality.WindowDuration()
+			fatalIfErr(err, "parse-duration-error")
+			requests := make([]db.ColumnStatsRequest, len(check.Quality.Columns))
+			for i, column := range check.Quality.Columns {
+				requests[i] = db.ColumnStatsRequest{Column: column.Column, AllowedValues: column.AllowedValues}
+			}
+
+			stats, err := client.QueryColumnStats(schemaName, tableName, check.Latency.TimestampColumn,
+				now.Add(-window), requests)
+			if err != nil {
+				queryErrors = append(queryErrors, err)
+				for _, column := range check.Quality.Columns {
+					for checkType, threshold := range columnThresholds(column) {
+						recordColumnResult(clusterName, schemaName, tableName, column, checkType, threshold, store.StatusError)
+					}
+				}
+				continue
+			}
+			for i, column := range check.Quality.Columns {
+				logColumnQuality(clusterName, schemaName, tableName, check, column, stats[i])
+			}
+		}
+	}
+
+	return queryErrors
+}
+
+// logColumnQuality logs and records each check of a column against its profile
+func logColumnQuality(clusterName, schemaName, tableName string, check config.TableCheck,
+	column config.ColumnCheck, stats db.ColumnStats) {
+	fullTableName := fmt.Sprintf("%s.%s.%s", clusterName, schemaName, tableName)
+	thresholds := columnThresholds(column)
+	hasData := stats.Rows > 0
+	observed := func(metric string) string {
+		if !hasData {
+			return noRowsInWindow
+		}
+		return metric
+	}
+
+	if threshold, ok := thresholds[nullFractionCheckType]; ok {
+		details := checkDetails(schemaName, tableName, check.Ownership)
+		breached := hasData && stats.NullFraction() > *column.MaxNullFraction
+		value, status := alertValue(hasData, breached, clusterName, schemaName, tableName, &details)
+		logger.CheckNullFractionEvent(value, fullTableName, column.Column,
+			observed(strconv.FormatFloat(stats.NullFraction(), 'f', 4, 64)), threshold, details)
+		recordColumnResult(clusterName, schemaName, tableName, column, nullFractionCheckType, threshold, status)
+	}
+	if threshold, ok := thresholds[distinctCountCheckType]; ok {
+		details := checkDetails(schemaName, tableName, check.Ownership)
+		breached := hasData && stats.Distinct < column.MinDistinct
+		value, status := alertValue(hasData, breached, clusterName, schemaName, tableName, &details)
+		logger.CheckDistinctCountEvent(value, fullTableName, column.Column,
+			observed(strconv.FormatInt(stats.Distinct, 10)), threshold, details)
+		recordColumnResult(clusterName, schemaName, tableName, column, distinctCountCheckType, threshold, status)
+	}
+	if threshold, ok := thresholds[allowedValuesCheckType]; ok {
+		details := checkDetails(schemaName, tableName, check.Ownership)
+		value, status := alertValue(hasData, stats.Disallowed > 0, clusterName, schemaName, tableName, &details)
+		logger.CheckAllowedValuesEvent(value, fullTableName, column.Column,
+			observed(strconv.FormatInt(stats.Disallowed, 10)), column.AllowedValues, details)
+		recordColumnResult(clusterName, schemaName, tableName, column, allowedValuesCheckType, threshold, status)
+	}
+}
+
+// columnThresholds returns the limit of each check configured
+// for a column, formatted for events and history, by check type
+func columnThresholds(column config.ColumnCheck) map[string]string {
+	thresholds := make(map[string]string)
+	if column.MaxNullFraction != nil {
+		thresholds[nullFractionCheckType] = strconv.FormatFloat(*column.MaxNullFraction, 'f', -1, 64)
+	}
+	if column.MinDistinct > 0 {
+		thresholds[distinctCountCheckType] = strconv.FormatInt(column.MinDistinct, 10)
+	}
+	if len(column.AllowedValues) > 0 {
+		thresholds[allowedValuesCheckType] = strings.Join(column.AllowedValues, ", ")
+	}
+	return thresholds
+}
+
+// recordColumnResult records the outcome of a column check in check history
+func recordColumnResult(clusterName, schemaName, tableName string, column config.ColumnCheck,
+	checkType, threshold string, status store.Status) {
+	recordResult(store.Result{
+		Cluster:   clusterName,
+		Schema:    schemaName,
+		Table:     tableName,
+		Check:     checkType,
+		Group:     column.Column,
+		Threshold: threshold,
+		Status:    status,
+	})
+}
+
+// anyChecksColumns reports whether any check has column checks
+func anyChecksColumns(checks Checks) bool {
+	for _, tableChecks := range checks {
+		for _, check := range tableChecks {
+			if len(check.Quality.Columns) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/Clever/analytics-monitor/config"
+	"github.com/Clever/analytics-monitor/db"
+	l "github.com/Clever/analytics-monitor/logger"
+)
+
+// checkSet holds the checks config in use by a long-running monitor.
+// Reloads swap in a new config atomically, so a run in progress keeps
+// the config it started with.
+type checkSet struct {
+	path    string
+	current atomic.Value // config.Config
+}
+
+// newCheckSet returns a checkSet reloading from path, starting with initial
+func newCheckSet(path string, initial config.Config) *checkSet {
+	set := &checkSet{path: path}
+	set.current.Store(initial)
+	return set
+}
+
+// Load returns the checks config currently in use
+func (s *checkSet) Load() config.Config {
+	return s.current.Load().(config.Config)
+}
+
+// reload re-reads the checks config and swaps it in if it validates.
+// Changing the cluster type requires a restart, since the database client
+// is tied to it. Returns whether any checks changed.
+func (s *checkSet) reload() (bool, error) {
+	next, err := config.LoadChecks(s.path)
+	if err == nil {
+		err = config.Validate(next)
+	}
+	current := s.Load()
+	if err == nil && next.Type != current.Type {
+		err = fmt.Errorf("changing type from %q to %q requires a restart", current.Type, next.Type)
+	}
+	if err != nil {
+		l.GetKVLogger().ErrorD("checks-reload-failed", l.M{
+			"path":  s.path,
+			"error": err.Error(),
+		})
+		return false, err
+	}
+
+	diff := config.Diff(current, next)
+	if diff.Empty() {
+		return false, nil
+	}
+
+	s.current.Store(next)
+	l.GetKVLogger().InfoD("checks-reloaded", l.M{
+		"path":    s.path,
+		"added":   diff.Added,
+		"removed": diff.Removed,
+		"changed": diff.Changed,
+	})
+	return true, nil
+}
+
+// runRecovered runs the checks once, returning a fatal error as the run's
+// error rather than panicking, since fatalIfErr has already logged the job
+// as failed
+func runRecovered(client db.Client, configChecks config.Config, opts options) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("%v", recovered)
+		}
+	}()
+	return runChecks(client, configChecks, opts)
+}
+
+// runForever runs the checks every opts.interval until interrupted.
+// The checks config is reloaded every opts.reloadInterval and on SIGHUP.
+// Failed runs are logged but don't stop the monitor.
+func runForever(client db.Client, checks *checkSet, opts options) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	runTicker := time.NewTicker(opts.interval)
+	defer runTicker.Stop()
+	reloadTicker := time.NewTicker(opts.reloadInterval)
+	defer reloadTicker.Stop()
+
+	runRecovered(client, checks.Load(), opts)
+	for {
+		select {
+		case <-runTicker.C:
+			runRecovered(client, checks.Load(), opts)
+		case <-reloadTicker.C:
+			checks.reload()
+		case sig := <-signals:
+			if sig == syscall.SIGHUP {
+				checks.reload()
+				continue
+			}
+			l.GetKVLogger().InfoD("shutting-down", l.M{"signal": sig.String()})
+			return nil
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/kardianos/osext"
+
+	"github.com/Clever/analytics-monitor/config"
+	"github.com/Clever/analytics-monitor/slo"
+	"github.com/Clever/analytics-monitor/store"
+)
+
+// reportCommand prints each table's compliance with its SLO, computed from
+// check history, worst offenders first
+func reportCommand(args []string, out io.Writer) error {
+	dir, err := osext.ExecutableFolder()
+	if err != nil {
+		return err
+	}
+
+	flags := flag.NewFlagSet("report", flag.ContinueOnError)
+	checksConfigPath := flags.String("config", defaultChecksConfigPath(dir),
+		"checks config file or directory (env CHECKS_CONFIG_PATH)")
+	historyURL := flags.String("history", os.Getenv("HISTORY_URL"),
+		"store for check history, a postgres:// URL or sqlite:<path> (env HISTORY_URL)")
+	cluster := flags.String("cluster", os.Getenv("CLUSTER_NAME"), "only count results from this cluster (env CLUSTER_NAME)")
+	format := flags.String("format", slo.FormatText, "output format: text, json or csv")
+	top := flags.Int("top", 0, "only show this many of the worst offenders, or all tables if 0")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *historyURL == "" {
+		return fmt.Errorf("a history store is required (-history or HISTORY_URL)")
+	}
+
+	checks, err := config.LoadChecks(*checksConfigPath)
+	if err != nil {
+		return err
+	}
+	if err := config.Validate(checks); err != nil {
+		return err
+	}
+
+	s, err := store.Open(*historyURL)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	reports, err := sloReports(s, checks.PostgresChecks, *cluster, time.Now())
+	if err != nil {
+		return err
+	}
+	slo.SortWorstFirst(reports)
+	if *top > 0 && len(reports) > *top {
+		reports = reports[:*top]
+	}
+	return slo.Write(out, *format, reports)
+}
+
+// sloReports evaluates the SLO of every table that has one. Tables inherit
+// their schema's default_slo, which applies to every table in the schema's
+// history that isn't omitted.
+func sloReports(s *store.Store, schemaConfigs []config.SchemaConfig, cluster string, now time.Time) ([]slo.Report, error) {
+	var reports []slo.Report
+	for _, schemaConfig := range schemaConfigs {
+		objectives := make(map[string]config.SLO)
+		for _, check := range schemaConfig.Checks {
+			if objective := check.SLO.Inherit(schemaConfig.DefaultSLO); objective.IsSet() {
+				objectives[check.TableName] = objective
+			}
+		}
+		if len(objectives) == 0 && !schemaConfig.DefaultSLO.IsSet() {
+			continue
+		}
+
+		// Fetch the schema's history over the longest window at once
+		longest, err := schemaConfig.DefaultSLO.WindowDuration()
+		if err != nil {
+			return nil, err
+		}
+		for _, objective := range objectives {
+			window, err := objective.WindowDuration()
+			if err != nil {
+				return nil, err
+			}
+			if window > longest {
+				longest = window
+			}
+		}
+		results, err := s.Results(store.ResultsQuery{
+			Cluster: cluster,
+			Schema:  schemaConfig.SchemaName,
+			From:    now.Add(-longest),
+			To:      now,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		byTable := make(map[string][]store.Result)
+		for _, result := range results {
+			byTable[result.Table] = append(byTable[result.Table], result)
+		}
+		if schemaConfig.DefaultSLO.IsSet() {
+			for tableName := range byTable {
+				if _, ok := objectives[tableName]; !ok {
+					objectives[tableName] = schemaConfig.DefaultSLO
+				}
+			}
+		}
+		for _, tableName := range schemaConfig.TablesToOmit {
+			delete(objectives, tableName)
+		}
+
+		for tableName, objective := range objectives {
+			window, err := objective.WindowDuration()
+			if err != nil {
+				return nil, err
+			}
+			var inWindow []store.Result
+			for _, result := range byTable[tableName] {
+				if !result.CheckedAt.Before(now.Add(-window)) {
+					inWindow = append(inWindow, result)
+				}
+			}
+			reports = append(reports, slo.Evaluate(schemaConfig.SchemaName+"."+tableName, objective, inWindow))
+		}
+	}
+	return reports, nil
+}
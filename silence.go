@@ -0,0 +1,161 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Clever/analytics-monitor/config"
+	l "github.com/Clever/analytics-monitor/logger"
+	"github.com/Clever/analytics-monitor/store"
+)
+
+// silenceCommand saves a silence to the state store, or lists the
+// silences that haven't ended
+func silenceCommand(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("silence", flag.ContinueOnError)
+	historyURL := flags.String("history", os.Getenv("HISTORY_URL"),
+		"state store, a postgres:// URL or sqlite:<path> (env HISTORY_URL)")
+	list := flags.Bool("list", false, "list the silences that haven't ended instead of adding one")
+	var silence config.Silence
+	flags.StringVar(&silence.Cluster, "cluster", "", "only silence checks of this cluster")
+	flags.StringVar(&silence.Schema, "schema", "", "only silence tables of this schema")
+	flags.StringVar(&silence.Table, "table", "", "only silence this table of -schema")
+	flags.StringVar(&silence.Pattern, "pattern", "", "only silence schema.table names or object paths matching this glob")
+	flags.StringVar(&silence.Reason, "reason", "", "why checks are silenced, e.g. a migration ticket")
+	start := flags.String("start", "", "start of the silence, as an RFC 3339 time or a date. Defaults to now")
+	end := flags.String("end", "", "end of the silence, as an RFC 3339 time or a date")
+	duration := flags.Duration("for", 0, "length of the silence, instead of -end")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *historyURL == "" {
+		return fmt.Errorf("a state store is required (-history or HISTORY_URL)")
+	}
+
+	now := time.Now().UTC()
+	if *list {
+		s, err := store.Open(*historyURL)
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		silences, err := s.Silences(now)
+		if err != nil {
+			return err
+		}
+		return printSilences(out, silences)
+	}
+
+	startTime, endTime, err := silenceWindow(*start, *end, *duration, now)
+	if err != nil {
+		return err
+	}
+	silence.Start = startTime.Format(time.RFC3339)
+	silence.End = endTime.Format(time.RFC3339)
+	if err := config.ValidateSilence(silence); err != nil {
+		return err
+	}
+
+	s, err := store.Open(*historyURL)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	if err := s.AddSilence(silence); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "Silenced until %s: %s\n", silence.End, silence.Reason)
+	return err
+}
+
+// silenceWindow parses the start and end of a silence. Exactly one of
+// end and duration must be set.
+func silenceWindow(start, end string, duration time.Duration, now time.Time) (time.Time, time.Time, error) {
+	startTime := now
+	if start != "" {
+		var err error
+		if startTime, err = parseTimeFlag(start, now); err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+	switch {
+	case end != "" && duration != 0:
+		return time.Time{}, time.Time{}, fmt.Errorf("only one of -end and -for can be set")
+	case duration != 0:
+		return startTime, startTime.Add(duration), nil
+	case end != "":
+		endTime, err := parseTimeFlag(end, now)
+		return startTime, endTime, err
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("a silence needs an -end or a -for duration")
+}
+
+// printSilences writes silences as a table
+func printSilences(out io.Writer, silences []config.Silence) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "START\tEND\tCLUSTER\tSCHEMA\tTABLE\tPATTERN\tREASON")
+	for _, silence := range silences {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", orDash(silence.Start), silence.End,
+			orDash(silence.Cluster), orDash(silence.Schema), orDash(silence.Table), orDash(silence.Pattern),
+			silence.Reason)
+	}
+	return w.Flush()
+}
+
+// orDash returns value, or "-" if it's empty
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// loadSilences returns the silences of the config and, if a state store is
+// configured, those added with the silence command. Failing to read the
+// store is logged, and only the config's silences are used.
+func loadSilences(configChecks config.Config, now time.Time) []config.Silence {
+	silences := append([]config.Silence(nil), configChecks.Silences...)
+	if history == nil {
+		return silences
+	}
+	stored, err := history.Silences(now)
+	if err != nil {
+		l.GetKVLogger().ErrorD("silences-read-failed", l.M{"error": err.Error()})
+		return silences
+	}
+	return append(silences, stored...)
+}
+
+// silenceFor returns the silence, if any, covering a check in the current
+// run. Object checks have no schema, and their path as the table.
+func silenceFor(cluster, schema, table string) (config.Silence, bool) {
+	if currentRun == nil {
+		return config.Silence{}, false
+	}
+	for _, silence := range currentRun.silences {
+		if silence.Matches(cluster, schema, table, currentRun.startedAt) {
+			return silence, true
+		}
+	}
+	return config.Silence{}, false
+}
+
+// alertValue returns the event value and status of a check that observed
+// data, or not, and whether it breached its threshold. Breaches covered by
+// a silence don't alert, and add the silence's reason to details.
+func alertValue(hasData, breached bool, cluster, schema, table string, details *l.Details) (int, store.Status) {
+	if !breached {
+		return 0, checkStatus(hasData, false)
+	}
+	if silence, ok := silenceFor(cluster, schema, table); ok {
+		details.Silenced = silence.Reason
+		return 0, store.StatusSilenced
+	}
+	return 1, checkStatus(hasData, true)
+}
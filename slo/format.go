@@ -0,0 +1,92 @@
+package slo
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+)
+
+// Formats that reports can be written in
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+)
+
+// Write writes reports in the given format
+func Write(out io.Writer, format string, reports []Report) error {
+	switch format {
+	case FormatText:
+		return writeText(out, reports)
+	case FormatJSON:
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		if reports == nil {
+			reports = []Report{}
+		}
+		return encoder.Encode(reports)
+	case FormatCSV:
+		return writeCSV(out, reports)
+	}
+	return fmt.Errorf("unknown report format %q: expected text, json or csv", format)
+}
+
+// writeText writes reports as a table, followed by a summary
+func writeText(out io.Writer, reports []Report) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TABLE\tTARGET\tWINDOW\tRUNS\tCOMPLIANCE\tBUDGET_REMAINING\tMET")
+	missed := 0
+	for _, report := range reports {
+		if !report.Met {
+			missed++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\t%t\n", report.Table, percentText(&report.Target),
+			report.Window, report.Runs, percentText(report.Compliance),
+			percentText(report.ErrorBudgetRemaining), report.Met)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(out, "\n%d of %d tables missed their SLO\n", missed, len(reports))
+	return err
+}
+
+// writeCSV writes reports with a header row
+func writeCSV(out io.Writer, reports []Report) error {
+	w := csv.NewWriter(out)
+	w.Write([]string{"table", "target", "window", "runs", "good_runs", "compliance", "error_budget_remaining", "met"})
+	for _, report := range reports {
+		w.Write([]string{
+			report.Table,
+			formatPercent(&report.Target),
+			report.Window,
+			strconv.Itoa(report.Runs),
+			strconv.Itoa(report.GoodRuns),
+			formatPercent(report.Compliance),
+			formatPercent(report.ErrorBudgetRemaining),
+			strconv.FormatBool(report.Met),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// formatPercent formats a percentage to two decimal places,
+// or "-" if there is none
+func formatPercent(percent *float64) string {
+	if percent == nil {
+		return "-"
+	}
+	return strconv.FormatFloat(*percent, 'f', 2, 64)
+}
+
+// percentText formats a percentage for display, e.g. "99.50%"
+func percentText(percent *float64) string {
+	if percent == nil {
+		return "-"
+	}
+	return formatPercent(percent) + "%"
+}
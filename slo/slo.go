@@ -0,0 +1,99 @@
+package slo
+
+import (
+	"sort"
+
+	"github.com/Clever/analytics-monitor/config"
+	"github.com/Clever/analytics-monitor/store"
+)
+
+// Report is a table's compliance with its freshness SLO over its window.
+// Compliance is the percentage of counted runs in which every check of
+// the table was ok. Runs whose checks all errored or were silenced
+// aren't counted.
+// Compliance and ErrorBudgetRemaining are nil if no runs were counted.
+type Report struct {
+	Table  string  `json:"table"`
+	Target float64 `json:"target"`
+	Window string  `json:"window"`
+
+	Runs     int `json:"runs"`
+	GoodRuns int `json:"good_runs"`
+
+	Compliance *float64 `json:"compliance"`
+	// ErrorBudgetRemaining is the percentage of the (100 - Target)%
+	// budget of bad runs left. It is negative once the budget is spent.
+	ErrorBudgetRemaining *float64 `json:"error_budget_remaining"`
+	Met                  bool     `json:"met"`
+}
+
+// Evaluate computes the compliance of a table from its check results
+func Evaluate(table string, objective config.SLO, results []store.Result) Report {
+	window := objective.Window
+	if window == "" {
+		window = config.DefaultSLOWindow
+	}
+	report := Report{Table: table, Target: objective.Target, Window: window}
+
+	// A run is good only if every check of the table in it was ok
+	good := make(map[string]bool)
+	var runs []string
+	for _, result := range results {
+		counted, ok := countResult(result.Status)
+		if !counted {
+			continue
+		}
+		if _, seen := good[result.RunID]; !seen {
+			good[result.RunID] = true
+			runs = append(runs, result.RunID)
+		}
+		good[result.RunID] = good[result.RunID] && ok
+	}
+
+	report.Runs = len(runs)
+	for _, run := range runs {
+		if good[run] {
+			report.GoodRuns++
+		}
+	}
+	if report.Runs == 0 {
+		return report
+	}
+
+	compliance := 100 * float64(report.GoodRuns) / float64(report.Runs)
+	budget := 100 - objective.Target
+	remaining := 100 * (1 - (100-compliance)/budget)
+	report.Compliance = &compliance
+	report.ErrorBudgetRemaining = &remaining
+	report.Met = compliance >= objective.Target
+	return report
+}
+
+// countResult reports whether a result counts towards compliance,
+// and if so whether it was good. Errors, checks with nothing to check
+// and planned maintenance (silenced breaches) aren't counted.
+func countResult(status store.Status) (bool, bool) {
+	switch status {
+	case store.StatusOK:
+		return true, true
+	case store.StatusError, store.StatusSkipped, store.StatusSilenced:
+		return false, false
+	}
+	return true, false
+}
+
+// SortWorstFirst orders reports by error budget remaining, so the worst
+// offenders come first. Reports without runs come last.
+func SortWorstFirst(reports []Report) {
+	sort.SliceStable(reports, func(i, j int) bool {
+		a, b := reports[i].ErrorBudgetRemaining, reports[j].ErrorBudgetRemaining
+		switch {
+		case a == nil && b == nil:
+		case a == nil || b == nil:
+			return a != nil
+		case *a != *b:
+			return *a < *b
+		}
+		return reports[i].Table < reports[j].Table
+	})
+}
@@ -0,0 +1,141 @@
+package slo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Clever/analytics-monitor/config"
+	"github.com/Clever/analytics-monitor/store"
+)
+
+// runResults returns one latency result per status, each in its own run
+func runResults(statuses ...store.Status) []store.Result {
+	results := make([]store.Result, len(statuses))
+	for i, status := range statuses {
+		results[i] = store.Result{RunID: fmt.Sprintf("run-%d", i), Check: "latency", Status: status}
+	}
+	return results
+}
+
+func TestEvaluate(t *testing.T) {
+	objective := config.SLO{Target: 90}
+
+	t.Logf("Testing that compliance is the percentage of good runs")
+	statuses := []store.Status{store.StatusBreach}
+	for i := 0; i < 19; i++ {
+		statuses = append(statuses, store.StatusOK)
+	}
+	report := Evaluate("mongo.districts", objective, runResults(statuses...))
+	assert.Equal(t, "720h", report.Window)
+	assert.Equal(t, 20, report.Runs)
+	assert.Equal(t, 19, report.GoodRuns)
+	assert.InDelta(t, 95, *report.Compliance, 0.001)
+	assert.InDelta(t, 50, *report.ErrorBudgetRemaining, 0.001)
+	assert.True(t, report.Met)
+
+	t.Logf("Testing that errored runs aren't counted, and missing data is bad")
+	report = Evaluate("mongo.districts", objective, runResults(
+		store.StatusOK, store.StatusError, store.StatusNoData, store.StatusOK))
+	assert.Equal(t, 3, report.Runs)
+	assert.InDelta(t, 66.667, *report.Compliance, 0.001)
+	assert.InDelta(t, -233.333, *report.ErrorBudgetRemaining, 0.001)
+	assert.False(t, report.Met)
+
+	t.Logf("Testing that silenced breaches aren't counted")
+	report = Evaluate("mongo.districts", objective, runResults(store.StatusOK, store.StatusSilenced))
+	assert.Equal(t, 1, report.Runs)
+	assert.Equal(t, 1, report.GoodRuns)
+
+	t.Logf("Testing that suppressed breaches are still bad")
+	report = Evaluate("mongo.districts", objective, runResults(store.StatusOK, store.StatusSuppressed))
+	assert.Equal(t, 2, report.Runs)
+	assert.Equal(t, 1, report.GoodRuns)
+
+	t.Logf("Testing that checks with nothing to check aren't counted")
+	results := []store.Result{
+		{RunID: "run-1", Check: "latency", Status: store.StatusOK},
+		{RunID: "run-1", Check: "null_fraction", Status: store.StatusSkipped},
+		{RunID: "run-2", Check: "null_fraction", Status: store.StatusSkipped},
+	}
+	report = Evaluate("mongo.districts", objective, results)
+	assert.Equal(t, 1, report.Runs)
+	assert.Equal(t, 1, report.GoodRuns)
+
+	t.Logf("Testing that a run is only good if every check in it was ok")
+	results = []store.Result{
+		{RunID: "run-1", Check: "latency", Status: store.StatusOK},
+		{RunID: "run-1", Check: "last_write", Status: store.StatusBreach},
+		{RunID: "run-2", Check: "latency", Status: store.StatusOK},
+		{RunID: "run-2", Check: "last_write", Status: store.StatusOK},
+	}
+	report = Evaluate("mongo.districts", objective, results)
+	assert.Equal(t, 2, report.Runs)
+	assert.Equal(t, 1, report.GoodRuns)
+
+	t.Logf("Testing that tables without runs have no compliance")
+	report = Evaluate("mongo.districts", objective, nil)
+	assert.Nil(t, report.Compliance)
+	assert.False(t, report.Met)
+}
+
+func TestSortWorstFirst(t *testing.T) {
+	percent := func(p float64) *float64 { return &p }
+	reports := []Report{
+		{Table: "mongo.none"},
+		{Table: "mongo.fine", ErrorBudgetRemaining: percent(80)},
+		{Table: "mongo.worst", ErrorBudgetRemaining: percent(-50)},
+		{Table: "mongo.bad", ErrorBudgetRemaining: percent(10)},
+		{Table: "mongo.empty"},
+	}
+	SortWorstFirst(reports)
+
+	var tables []string
+	for _, report := range reports {
+		tables = append(tables, report.Table)
+	}
+	assert.Equal(t, []string{"mongo.worst", "mongo.bad", "mongo.fine", "mongo.empty", "mongo.none"}, tables)
+}
+
+func TestWrite(t *testing.T) {
+	reports := []Report{
+		Evaluate("mongo.districts", config.SLO{Target: 99.5, Window: "2160h"},
+			runResults(store.StatusOK, store.StatusBreach)),
+		Evaluate("mongo.schools", config.SLO{Target: 95}, nil),
+	}
+
+	t.Logf("Testing text output")
+	var out bytes.Buffer
+	require.NoError(t, Write(&out, FormatText, reports))
+	lines := strings.Split(out.String(), "\n")
+	assert.Regexp(t, `^mongo.districts\s+99.50%\s+2160h\s+2\s+50.00%\s+-9900.00%\s+false`, lines[1])
+	assert.Regexp(t, `^mongo.schools\s+95.00%\s+720h\s+0\s+-\s+-\s+false`, lines[2])
+	assert.Contains(t, out.String(), "2 of 2 tables missed their SLO")
+
+	t.Logf("Testing JSON output")
+	out.Reset()
+	require.NoError(t, Write(&out, FormatJSON, reports))
+	var decoded []map[string]interface{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+	require.Len(t, decoded, 2)
+	assert.Equal(t, 50.0, decoded[0]["compliance"])
+	assert.Nil(t, decoded[1]["compliance"])
+
+	t.Logf("Testing CSV output")
+	out.Reset()
+	require.NoError(t, Write(&out, FormatCSV, reports))
+	assert.Equal(t, strings.Join([]string{
+		"table,target,window,runs,good_runs,compliance,error_budget_remaining,met",
+		"mongo.districts,99.50,2160h,2,1,50.00,-9900.00,false",
+		"mongo.schools,95.00,720h,0,0,-,-,false",
+		"",
+	}, "\n"), out.String())
+
+	t.Logf("Testing that unknown formats are rejected")
+	assert.Error(t, Write(&out, "xml", reports))
+}
@@ -0,0 +1,63 @@
+package store
+
+import (
+	"github.com/Clever/analytics-monitor/db"
+)
+
+// Columns returns the most recent snapshot of the columns of each table
+// of a schema, indexed by table name. Tables never snapshotted are missing.
+func (s *Store) Columns(cluster, schema string) (map[string][]db.Column, error) {
+	rows, err := s.session.Query(`
+		SELECT table_name, column_name, data_type
+		FROM monitor_column_snapshots
+		WHERE cluster = $1 AND schema_name = $2
+		ORDER BY table_name, position
+	`, cluster, schema)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make(map[string][]db.Column)
+	for rows.Next() {
+		var table string
+		var column db.Column
+		if err := rows.Scan(&table, &column.Name, &column.Type); err != nil {
+			return nil, err
+		}
+		columns[table] = append(columns[table], column)
+	}
+	return columns, rows.Err()
+}
+
+// SaveColumns replaces the snapshot of each given table of a schema.
+// Snapshots of other tables are left as they are.
+func (s *Store) SaveColumns(cluster, schema string, columns map[string][]db.Column) error {
+	tx, err := s.session.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	snapshotAt := s.now().Unix()
+	for table, tableColumns := range columns {
+		_, err := tx.Exec(`
+			DELETE FROM monitor_column_snapshots
+			WHERE cluster = $1 AND schema_name = $2 AND table_name = $3
+		`, cluster, schema, table)
+		if err != nil {
+			return err
+		}
+		for position, column := range tableColumns {
+			_, err := tx.Exec(`
+				INSERT INTO monitor_column_snapshots
+					(cluster, schema_name, table_name, position, column_name, data_type, snapshot_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7)
+			`, cluster, schema, table, position, column.Name, column.Type, snapshotAt)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,87 @@
+package store
+
+import (
+	"database/sql"
+)
+
+// migrations create and evolve the store's tables. Each is applied once,
+// in order, and recorded in monitor_migrations by its index + 1. Only ever
+// append to this list: applied migrations must not change.
+var migrations = []string{
+	`CREATE TABLE monitor_check_results (
+		run_id      TEXT NOT NULL,
+		checked_at  BIGINT NOT NULL,
+		cluster     TEXT NOT NULL,
+		schema_name TEXT NOT NULL,
+		table_name  TEXT NOT NULL,
+		check_type  TEXT NOT NULL,
+		latency_hrs BIGINT,
+		threshold   TEXT NOT NULL,
+		status      TEXT NOT NULL
+	)`,
+	`CREATE INDEX monitor_check_results_table_idx
+		ON monitor_check_results (schema_name, table_name, checked_at)`,
+	`ALTER TABLE monitor_check_results ADD COLUMN max_timestamp BIGINT`,
+	`CREATE TABLE monitor_silences (
+		cluster     TEXT NOT NULL,
+		schema_name TEXT NOT NULL,
+		table_name  TEXT NOT NULL,
+		pattern     TEXT NOT NULL,
+		starts_at   BIGINT,
+		ends_at     BIGINT NOT NULL,
+		reason      TEXT NOT NULL,
+		created_at  BIGINT NOT NULL
+	)`,
+	`CREATE TABLE monitor_column_snapshots (
+		cluster     TEXT NOT NULL,
+		schema_name TEXT NOT NULL,
+		table_name  TEXT NOT NULL,
+		position    INTEGER NOT NULL,
+		column_name TEXT NOT NULL,
+		data_type   TEXT NOT NULL,
+		snapshot_at BIGINT NOT NULL
+	)`,
+	`ALTER TABLE monitor_check_results ADD COLUMN group_value TEXT`,
+}
+
+// migrate applies pending migrations, each in its own transaction
+func (s *Store) migrate() error {
+	_, err := s.session.Exec(`CREATE TABLE IF NOT EXISTS monitor_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at BIGINT NOT NULL
+	)`)
+	if err != nil {
+		return err
+	}
+
+	var applied sql.NullInt64
+	if err := s.session.QueryRow(`SELECT MAX(version) FROM monitor_migrations`).Scan(&applied); err != nil {
+		return err
+	}
+
+	for i := int(applied.Int64); i < len(migrations); i++ {
+		if err := s.applyMigration(i+1, migrations[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyMigration runs a migration and records its version
+func (s *Store) applyMigration(version int, statement string) error {
+	tx, err := s.session.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(statement); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO monitor_migrations (version, applied_at) VALUES ($1, $2)`,
+		version, s.now().Unix())
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
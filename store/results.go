@@ -0,0 +1,144 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Status is the outcome of a check
+type Status string
+
+const (
+	// StatusOK means the check met its threshold
+	StatusOK Status = "ok"
+	// StatusBreach means the check exceeded its threshold
+	StatusBreach Status = "breach"
+	// StatusNoData means there were no rows (or writes, or objects) to
+	// check, which is a breach
+	StatusNoData Status = "no_data"
+	// StatusSkipped means there was nothing to check, such as rows in the
+	// window of a column check, but that isn't a breach, so it didn't alert
+	StatusSkipped Status = "skipped"
+	// StatusError means the check couldn't be completed
+	StatusError Status = "error"
+	// StatusSuppressed means the check exceeded its threshold because
+	// a table upstream of it is stale, so it didn't alert
+	StatusSuppressed Status = "suppressed"
+	// StatusSilenced means the check exceeded its threshold during a
+	// silence, so it didn't alert
+	StatusSilenced Status = "silenced"
+)
+
+// Result is the outcome of a single check in a run
+type Result struct {
+	RunID     string
+	CheckedAt time.Time
+	Cluster   string
+	Schema    string
+	// Table is the table name, or the path of object checks
+	Table string
+	// Check is the kind of check, e.g. "latency" or "last_write"
+	Check string
+	// Group is the part of the table checked: the value of the group_by
+	// column of grouped latency checks, which record a result per group,
+	// or the column of column checks. Empty if the whole table is checked.
+	Group string
+	// LatencyHrs is the observed latency, if HasLatency
+	LatencyHrs int64
+	HasLatency bool
+	// MaxTimestamp is the most recent timestamp observed by latency
+	// checks, or zero. Automatic thresholds learn from its changes.
+	MaxTimestamp time.Time
+	Threshold    string
+	Status       Status
+}
+
+// RecordResults writes the results of a run
+func (s *Store) RecordResults(results []Result) error {
+	tx, err := s.session.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO monitor_check_results
+			(run_id, checked_at, cluster, schema_name, table_name, check_type, group_value, latency_hrs, max_timestamp, threshold, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, result := range results {
+		latency := sql.NullInt64{Int64: result.LatencyHrs, Valid: result.HasLatency}
+		var maxTimestamp sql.NullInt64
+		if !result.MaxTimestamp.IsZero() {
+			maxTimestamp = sql.NullInt64{Int64: result.MaxTimestamp.Unix(), Valid: true}
+		}
+		group := sql.NullString{String: result.Group, Valid: result.Group != ""}
+		_, err := stmt.Exec(result.RunID, result.CheckedAt.Unix(), result.Cluster, result.Schema,
+			result.Table, result.Check, group, latency, maxTimestamp, result.Threshold, string(result.Status))
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ResultsQuery selects the results of a table's checks. An empty Cluster
+// matches every cluster, an empty Table every table in the schema, and
+// zero From or To times leave the range open.
+type ResultsQuery struct {
+	Cluster string
+	Schema  string
+	Table   string
+	From    time.Time
+	To      time.Time
+}
+
+// Results returns the results matching query, oldest first
+func (s *Store) Results(query ResultsQuery) ([]Result, error) {
+	to := query.To
+	if to.IsZero() {
+		to = s.now()
+	}
+
+	rows, err := s.session.Query(`
+		SELECT run_id, checked_at, cluster, schema_name, table_name, check_type, group_value, latency_hrs, max_timestamp, threshold, status
+		FROM monitor_check_results
+		WHERE schema_name = $1 AND ($2 = '' OR table_name = $2)
+		AND ($3 = '' OR cluster = $3)
+		AND checked_at >= $4 AND checked_at <= $5
+		ORDER BY checked_at, table_name, check_type, group_value
+	`, query.Schema, query.Table, query.Cluster, query.From.Unix(), to.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []Result
+	for rows.Next() {
+		var result Result
+		var checkedAt int64
+		var group sql.NullString
+		var latency, maxTimestamp sql.NullInt64
+		var status string
+		err := rows.Scan(&result.RunID, &checkedAt, &result.Cluster, &result.Schema, &result.Table,
+			&result.Check, &group, &latency, &maxTimestamp, &result.Threshold, &status)
+		if err != nil {
+			return nil, err
+		}
+		result.CheckedAt = time.Unix(checkedAt, 0).UTC()
+		result.Group = group.String
+		result.LatencyHrs = latency.Int64
+		result.HasLatency = latency.Valid
+		if maxTimestamp.Valid {
+			result.MaxTimestamp = time.Unix(maxTimestamp.Int64, 0).UTC()
+		}
+		result.Status = Status(status)
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
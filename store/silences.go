@@ -0,0 +1,61 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/Clever/analytics-monitor/config"
+)
+
+// AddSilence saves a silence, which should already be validated
+func (s *Store) AddSilence(silence config.Silence) error {
+	start, end, err := silence.Window()
+	if err != nil {
+		return err
+	}
+	var startsAt sql.NullInt64
+	if !start.IsZero() {
+		startsAt = sql.NullInt64{Int64: start.Unix(), Valid: true}
+	}
+
+	_, err = s.session.Exec(`
+		INSERT INTO monitor_silences
+			(cluster, schema_name, table_name, pattern, starts_at, ends_at, reason, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, silence.Cluster, silence.Schema, silence.Table, silence.Pattern, startsAt, end.Unix(),
+		silence.Reason, s.now().Unix())
+	return err
+}
+
+// Silences returns the silences that haven't ended by the given time,
+// soonest to end first
+func (s *Store) Silences(at time.Time) ([]config.Silence, error) {
+	rows, err := s.session.Query(`
+		SELECT cluster, schema_name, table_name, pattern, starts_at, ends_at, reason
+		FROM monitor_silences
+		WHERE ends_at > $1
+		ORDER BY ends_at, created_at
+	`, at.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var silences []config.Silence
+	for rows.Next() {
+		var silence config.Silence
+		var startsAt sql.NullInt64
+		var endsAt int64
+		err := rows.Scan(&silence.Cluster, &silence.Schema, &silence.Table, &silence.Pattern,
+			&startsAt, &endsAt, &silence.Reason)
+		if err != nil {
+			return nil, err
+		}
+		if startsAt.Valid {
+			silence.Start = time.Unix(startsAt.Int64, 0).UTC().Format(time.RFC3339)
+		}
+		silence.End = time.Unix(endsAt, 0).UTC().Format(time.RFC3339)
+		silences = append(silences, silence)
+	}
+	return silences, rows.Err()
+}
@@ -0,0 +1,68 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	// Use our own version of the postgres library so we get keep-alive support.
+	// See https://github.com/Clever/pq/pull/1
+	_ "github.com/Clever/pq"
+	// Pure Go SQLite driver, for a local store
+	_ "modernc.org/sqlite"
+
+	l "github.com/Clever/analytics-monitor/logger"
+)
+
+// Store persists analytics-monitor's own state, such as check history,
+// silences and column snapshots, in monitor-owned tables of a Postgres or SQLite database. Its
+// queries are written to run unchanged on both.
+type Store struct {
+	session *sql.DB
+	now     func() time.Time
+}
+
+// Open connects to the store at url, which is either a postgres:// URL
+// or sqlite:<path>, and applies any pending migrations
+func Open(url string) (*Store, error) {
+	driver, dataSource, err := parseURL(url)
+	if err != nil {
+		return nil, err
+	}
+
+	l.GetKVLogger().InfoD("open-store", l.M{"url": url, "driver": driver})
+	session, err := sql.Open(driver, dataSource)
+	if err != nil {
+		return nil, err
+	}
+	if driver == "sqlite" {
+		// SQLite only allows a single writer
+		session.SetMaxOpenConns(1)
+	}
+
+	s := &Store{session: session, now: time.Now}
+	if err := s.migrate(); err != nil {
+		session.Close()
+		return nil, fmt.Errorf("migrating store: %s", err)
+	}
+	return s, nil
+}
+
+// parseURL returns the database/sql driver and data source of a store URL
+func parseURL(url string) (string, string, error) {
+	switch {
+	case strings.HasPrefix(url, "postgres://"), strings.HasPrefix(url, "postgresql://"):
+		return "postgres", url, nil
+	case strings.HasPrefix(url, "sqlite://"):
+		return "sqlite", strings.TrimPrefix(url, "sqlite://"), nil
+	case strings.HasPrefix(url, "sqlite:"):
+		return "sqlite", strings.TrimPrefix(url, "sqlite:"), nil
+	}
+	return "", "", fmt.Errorf("unsupported store URL %q: expected postgres:// or sqlite:<path>", l.RedactString(url))
+}
+
+// Close releases the store's connections
+func (s *Store) Close() error {
+	return s.session.Close()
+}
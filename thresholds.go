@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Clever/analytics-monitor/baseline"
+	"github.com/Clever/analytics-monitor/config"
+	"github.com/Clever/analytics-monitor/db"
+	l "github.com/Clever/analytics-monitor/logger"
+	"github.com/Clever/analytics-monitor/store"
+)
+
+// learnThresholds replaces every auto threshold in checks with one learned
+// from the table's update cadence. Cadences are learned from the most recent
+// timestamps in check history, or else from the table's own timestamps if
+// they're load times.
+// Tables whose cadence can't be learned fall back to globalDefaultLatency.
+func learnThresholds(client db.Client, checks Checks, globalDefaultLatency string) {
+	now := time.Now()
+	for schemaName, tableChecks := range checks {
+		for tableName, check := range tableChecks {
+			if !check.Latency.IsAuto() {
+				continue
+			}
+
+			fullTableName := fmt.Sprintf("%s.%s", schemaName, tableName)
+			learned, err := learnBaseline(client, schemaName, check, now)
+			if err != nil {
+				l.GetKVLogger().WarnD("auto-threshold-unavailable", l.M{
+					"table":    fullTableName,
+					"fallback": globalDefaultLatency,
+					"error":    err.Error(),
+				})
+				check.Latency.Threshold = globalDefaultLatency
+			} else {
+				check.Latency.Threshold = fmt.Sprintf("%dh", int64(learned.Threshold.Hours()))
+				if currentRun != nil {
+					currentRun.baselines[fullTableName] = learned
+				}
+			}
+			tableChecks[tableName] = check
+		}
+	}
+}
+
+// learnBaseline learns the update cadence of a table, preferring check
+// history over scanning the table's timestamps. Only timestamps set on
+// load are scanned, since the hours in which events happened say nothing
+// of how often the table is loaded.
+func learnBaseline(client db.Client, schemaName string, check config.TableCheck, now time.Time) (baseline.Baseline, error) {
+	lookback, err := check.Latency.Auto.LookbackDuration()
+	if err != nil {
+		return baseline.Baseline{}, err
+	}
+	since := now.Add(-lookback)
+
+	if history != nil {
+		results, err := history.Results(store.ResultsQuery{
+			Cluster: client.GetClusterName(),
+			Schema:  schemaName,
+			Table:   check.TableName,
+			From:    since,
+		})
+		if err != nil {
+			return baseline.Baseline{}, err
+		}
+		var observations []time.Time
+		for _, result := range results {
+			// Groups update at their own cadence, so only ungrouped results are learned from
+			if result.Check == string(db.CheckLatency) && result.Group == "" && !result.MaxTimestamp.IsZero() {
+				observations = append(observations, result.MaxTimestamp)
+			}
+		}
+		learned, err := baseline.Learn(observations, baseline.SourceHistory, check.Latency.Auto)
+		if err != baseline.ErrTooFewSamples {
+			return learned, err
+		}
+	}
+
+	if check.Latency.TimestampColumn == "" || !check.Latency.Auto.HasLoadTimestamps() {
+		return baseline.Baseline{}, baseline.ErrTooFewSamples
+	}
+	// Hourly tables have at most one distinct hour per hour of lookback
+	hours, err := client.QueryRecentTimestamps(check.Latency.TimestampColumn, schemaName, check.TableName,
+		dbFilter(check.Latency.Filter), since, int(lookback.Hours()))
+	if err != nil {
+		return baseline.Baseline{}, err
+	}
+	return baseline.Learn(hours, baseline.SourceTimestamps, check.Latency.Auto)
+}
+
+// checkDetails returns the event dimensions of a table's check: its
+// ownership and, if its threshold was learned, its baseline
+func checkDetails(schemaName, tableName string, owner config.Ownership) l.Details {
+	details := l.Details{Ownership: l.Ownership(owner)}
+	if currentRun == nil {
+		return details
+	}
+	if learned, ok := currentRun.baselines[schemaName+"."+tableName]; ok {
+		details.Baseline = learned.Cadence.Round(time.Minute).String()
+		details.BaselineSource = learned.Source
+	}
+	return details
+}